@@ -35,6 +35,91 @@ func Test_validateConfig(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name: "failedWithInvalidFormatDefaultsFormat",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				FormatDefaults:  types.FormatDefaults{types.TypeGIF: {Quality: 80}},
+				Projects:        []config.Project{{ID: "id", Hostname: "hostname", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{}}}},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "failedWithInvalidFormatDefaultsQuality",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				Projects: []config.Project{{ID: "id", Hostname: "hostname", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{
+					FormatDefaults: types.FormatDefaults{types.TypeWEBP: {Quality: 101}},
+				}}}},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "failedWithInvalidProjectMetadata",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				Projects:        []config.Project{{ID: "id", Hostname: "hostname", Metadata: "gps", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{}}}},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "failedWithInvalidWatermark",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				Projects: []config.Project{{ID: "id", Hostname: "hostname", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{
+					Watermark: &config.WatermarkConfig{Source: "watermark.png", Position: "middle"},
+				}}}},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "failedWithInvalidTextMaxLength",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				Text:            config.TextConfig{Enabled: true, MaxLength: -1},
+				Projects:        []config.Project{{ID: "id", Hostname: "hostname", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{}}}},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "failedWithInvalidMaxDpr",
+			cfg: &config.Config{
+				PidPath:         "/var/run/go-media-resizer/server.pid",
+				HTTP:            config.HTTPConfig{Listen: "127.0.0.1:8080"},
+				AcceptTypeFiles: []string{types.TypeText},
+				ResizeTypeFiles: []string{types.TypePNG},
+				BufferPoolSize:  config.DefaultBufferPoolSize,
+				SourceLimit:     config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+				MaxDpr:          0.5,
+				Projects:        []config.Project{{ID: "id", Hostname: "hostname", Storage: config.StorageConfig{Type: "fake"}, Endpoints: []config.Endpoint{{}}}},
+			},
+			wantErr: assert.Error,
+		},
 		{
 			name: "failedWithInvalidConfig",
 			cfg: &config.Config{
@@ -13,7 +13,9 @@ import (
 	"github.com/reflet-devops/go-media-resizer/config"
 	"github.com/reflet-devops/go-media-resizer/context"
 	"github.com/reflet-devops/go-media-resizer/parser"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/spf13/afero"
 	"github.com/valyala/fasthttp"
 
 	"log/slog"
@@ -85,6 +87,10 @@ func GetRootPreRunEFn(ctx *context.Context, validateCfg bool) func(*cobra.Comman
 		}
 		ctx.Config.AcceptTypeFiles = append(ctx.Config.AcceptTypeFiles, ctx.Config.ResizeTypeFiles...)
 
+		if errCascade := loadFaceCascade(ctx); errCascade != nil {
+			return fmt.Errorf("fail to load face cascade: %v", errCascade)
+		}
+
 		errPreparePrj := prepareProject(ctx)
 		if errPreparePrj != nil {
 			return fmt.Errorf("fail to prepare project: %v", errPreparePrj)
@@ -103,6 +109,24 @@ func setHTTPClient(ctx *context.Context) {
 	}
 }
 
+// loadFaceCascade loads the cascade of the face_cascade configuration over the embedded one, so that a missing or
+// invalid file stops the startup instead of being silently ignored.
+func loadFaceCascade(ctx *context.Context) error {
+	if ctx.Config.FaceCascade == "" {
+		return nil
+	}
+	data, err := afero.ReadFile(ctx.Fs, ctx.Config.FaceCascade)
+	if err != nil {
+		return err
+	}
+	cascade, err := transform.UnpackFaceCascade(data)
+	if err != nil {
+		return err
+	}
+	transform.SetFaceCascade(cascade)
+	return nil
+}
+
 func initConfig(ctx *context.Context, cmd *cobra.Command) {
 	dir := ctx.WorkingDir
 
@@ -154,12 +178,19 @@ func prepareProject(ctx *context.Context) error {
 		slices.Sort(project.AcceptTypeFiles)
 		project.AcceptTypeFiles = slices.Compact(project.AcceptTypeFiles) // remove consecutive identical value
 
+		project.FormatDefaults = ctx.Config.FormatDefaults.Merge(project.FormatDefaults)
+
 		for j, endpoint := range project.Endpoints {
+			endpoint.FormatDefaults = project.FormatDefaults.Merge(endpoint.FormatDefaults)
 
 			if endpoint.DefaultResizeOpts.Format == "" {
 				endpoint.DefaultResizeOpts.Format = types.TypeFormatAuto
 			}
 
+			if errRotate := transform.ValidateRotate(&endpoint.DefaultResizeOpts); errRotate != nil {
+				return fmt.Errorf("project=%s, default_resize: %v", project.ID, errRotate)
+			}
+
 			if endpoint.Regex != "" {
 				re, errReCompile := regexp.Compile(endpoint.Regex)
 				if errReCompile != nil {
@@ -182,6 +213,11 @@ func prepareProject(ctx *context.Context) error {
 		}
 		project.PrefixPath = strings.Trim(project.PrefixPath, "/")
 
+		if project.AutoOrient == nil {
+			autoOrient := ctx.Config.AutoOrient
+			project.AutoOrient = &autoOrient
+		}
+
 		if project.Headers == nil {
 			project.Headers = types.Headers{}
 		}
@@ -217,6 +253,12 @@ func validRegexTest(project config.Project, endpoint config.Endpoint) error {
 		if !found {
 			return fmt.Errorf("fail to validate RegexTest %s path not match", test.Path)
 		}
+		if errCrop := transform.ValidateCrop(opts); errCrop != nil {
+			return fmt.Errorf("fail to validate RegexTest %s with error: %v", test.Path, errCrop)
+		}
+		if errRotate := transform.ValidateRotate(opts); errRotate != nil {
+			return fmt.Errorf("fail to validate RegexTest %s with error: %v", test.Path, errRotate)
+		}
 		opts.Headers = nil
 		if !reflect.DeepEqual(opts, &test.ResultOpts) {
 			return fmt.Errorf("fail to validate RegexTest %s excepted: %v, actual: %v", test.Path, &test.ResultOpts, opts)
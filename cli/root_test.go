@@ -3,13 +3,16 @@ package cli
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
 	"testing"
 
 	"github.com/reflet-devops/go-media-resizer/config"
 	"github.com/reflet-devops/go-media-resizer/context"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
@@ -98,8 +101,10 @@ func Test_prepareProject_Success(t *testing.T) {
 	regexStr := "(?<source>.*)"
 	re, errReCompile := regexp.Compile(regexStr)
 	assert.NoError(t, errReCompile)
+	autoOrientEnabled, autoOrientDisabled := true, false
 	cfg := &config.Config{
 		HTTP:            config.HTTPConfig{},
+		AutoOrient:      true,
 		AcceptTypeFiles: []string{".1"},
 		ResizeTypeFiles: []string{".3"},
 		Headers: types.Headers{
@@ -117,6 +122,7 @@ func Test_prepareProject_Success(t *testing.T) {
 				Headers: types.Headers{
 					"X-Custom": "bar",
 				},
+				AutoOrient: &autoOrientDisabled,
 			},
 			{
 				ID:                   "concat",
@@ -159,6 +165,7 @@ func Test_prepareProject_Success(t *testing.T) {
 			Headers: types.Headers{
 				"X-Custom": "bar",
 			},
+			AutoOrient: &autoOrientDisabled,
 		},
 		{
 			ID:                   "concat",
@@ -167,6 +174,7 @@ func Test_prepareProject_Success(t *testing.T) {
 			Headers: types.Headers{
 				"X-Custom": "foo",
 			},
+			AutoOrient: &autoOrientEnabled,
 		},
 		{
 			ID:                   "extra-headers",
@@ -179,6 +187,7 @@ func Test_prepareProject_Success(t *testing.T) {
 			ExtraHeaders: types.Headers{
 				"X-Extra": "foo",
 			},
+			AutoOrient: &autoOrientEnabled,
 		},
 		{
 			ID:              "prefix-path",
@@ -187,6 +196,7 @@ func Test_prepareProject_Success(t *testing.T) {
 				"X-Custom": "foo",
 			},
 			PrefixPath: "prefix",
+			AutoOrient: &autoOrientEnabled,
 		},
 		{
 			ID:                   "regex-test",
@@ -205,6 +215,7 @@ func Test_prepareProject_Success(t *testing.T) {
 			Headers: types.Headers{
 				"X-Custom": "foo",
 			},
+			AutoOrient: &autoOrientEnabled,
 		},
 	}
 
@@ -214,6 +225,38 @@ func Test_prepareProject_Success(t *testing.T) {
 	assert.Equal(t, want, cfg.Projects)
 }
 
+func Test_prepareProject_FormatDefaults(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctx.Config = &config.Config{
+		FormatDefaults: types.FormatDefaults{
+			types.TypeWEBP: {Quality: 80},
+			types.TypeAVIF: {Quality: 50, Speed: 8},
+		},
+		Projects: []config.Project{
+			{
+				ID:             "project",
+				FormatDefaults: types.FormatDefaults{types.TypeAVIF: {Quality: 40}},
+				Endpoints: []config.Endpoint{
+					{},
+					{FormatDefaults: types.FormatDefaults{types.TypeWEBP: {Quality: 70}, types.TypeJPEG: {Quality: 85}}},
+				},
+			},
+		},
+	}
+
+	err := prepareProject(ctx)
+	assert.NoError(t, err)
+
+	project := ctx.Config.Projects[0]
+	assert.Equal(t, types.FormatDefaults{types.TypeWEBP: {Quality: 80}, types.TypeAVIF: {Quality: 40, Speed: 8}}, project.FormatDefaults)
+	assert.Equal(t, project.FormatDefaults, project.Endpoints[0].FormatDefaults)
+	assert.Equal(t, types.FormatDefaults{
+		types.TypeWEBP: {Quality: 70},
+		types.TypeAVIF: {Quality: 40, Speed: 8},
+		types.TypeJPEG: {Quality: 85},
+	}, project.Endpoints[1].FormatDefaults)
+}
+
 func Test_prepareProject_Compile_Fail(t *testing.T) {
 	ctx := context.TestContext(nil)
 	ctx.WorkingDir = "/app"
@@ -395,6 +438,64 @@ func TestGetRootPreRunEFn_FailPrepareProject(t *testing.T) {
 	assert.Contains(t, err.Error(), "fail to prepare project:")
 }
 
+func TestGetRootPreRunEFn_FailFaceCascade(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctx.WorkingDir = "/app"
+	cmd := GetRootCmd(ctx)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	path := ctx.WorkingDir
+	_ = ctx.Fs.Mkdir(path, 0775)
+	globalStr := "accept_type_files: ['txt']\nresize_type_files: ['png']\nface_cascade: /app/facefinder"
+	projectStr := "projects: [{id: test, hostname: foo.com, storage: {type: foo}}]"
+	_ = afero.WriteFile(ctx.Fs, fmt.Sprintf("%s/config.yml", path), []byte(globalStr+"\n"+projectStr), 0644)
+	viper.Reset()
+	viper.SetFs(ctx.Fs)
+	err := GetRootPreRunEFn(ctx, true)(cmd, []string{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "fail to load face cascade:")
+}
+
+func Test_loadFaceCascade(t *testing.T) {
+	// a one tree cascade of depth 1, in the pico binary format
+	cascade := make([]byte, 8)
+	cascade = binary.LittleEndian.AppendUint32(cascade, 1)
+	cascade = binary.LittleEndian.AppendUint32(cascade, 1)
+	cascade = append(cascade, 0, 127, 0, 0)
+	cascade = binary.LittleEndian.AppendUint32(cascade, math.Float32bits(10))
+	cascade = binary.LittleEndian.AppendUint32(cascade, math.Float32bits(-1))
+	cascade = binary.LittleEndian.AppendUint32(cascade, math.Float32bits(0))
+
+	tests := []struct {
+		name            string
+		faceCascade     string
+		data            []byte
+		wantErrContains string
+	}{
+		{name: "successWithoutCascade"},
+		{name: "success", faceCascade: "/app/facefinder", data: cascade},
+		{name: "failMissingFile", faceCascade: "/app/missing", wantErrContains: "/app/missing"},
+		{name: "failInvalidCascade", faceCascade: "/app/facefinder", data: []byte("abc"), wantErrContains: "face cascade is truncated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer transform.SetFaceCascade(nil)
+			ctx := context.TestContext(nil)
+			ctx.Config.FaceCascade = tt.faceCascade
+			if tt.data != nil {
+				_ = afero.WriteFile(ctx.Fs, tt.faceCascade, tt.data, 0644)
+			}
+			err := loadFaceCascade(ctx)
+			if tt.wantErrContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErrContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func Test_validRegexTest(t *testing.T) {
 
 	tests := []struct {
@@ -432,6 +533,54 @@ func Test_validRegexTest(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:    "successWithGravityOption",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/(?<gravity>[a-z-]+|[0-9.]+x[0-9.]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/top-left/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Gravity: types.TypeGravityTopLeft}},
+					{Path: "/0.3x0.7/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Gravity: "0.3x0.7"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "successWithCropOption",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/crop-(?<crop>[0-9.,]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/crop-10,20,300,200/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Crop: "10,20,300,200"}},
+					{Path: "/crop-0.1,0.2,0.5,0.5/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Crop: "0.1,0.2,0.5,0.5"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "failWithCropNotEqual",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/crop-(?<crop>[0-9.,]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/crop-10,20,300,200/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Crop: "10,20,300,100"}},
+				},
+			},
+			wantErr:         true,
+			wantErrContains: "fail to validate RegexTest /crop-10,20,300,200/media/image.png excepted",
+		},
+		{
+			name:    "failWithInvalidCrop",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/crop-(?<crop>[0-9.,]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/crop-10,20,300/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Crop: "10,20,300"}},
+				},
+			},
+			wantErr:         true,
+			wantErrContains: "fail to validate RegexTest /crop-10,20,300/media/image.png with error: invalid crop: 10,20,300",
+		},
 		{
 			name:    "failWithTypeNotAccepted",
 			project: config.Project{AcceptTypeFiles: []string{}},
@@ -456,6 +605,29 @@ func Test_validRegexTest(t *testing.T) {
 			wantErr:         true,
 			wantErrContains: "fail to validate RegexTest /500x500/media/image.png path not match",
 		},
+		{
+			name:    "failWithInvalidRotate",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/rotate-(?<rotate>[0-9]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/rotate-45/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Rotate: 45}},
+				},
+			},
+			wantErr:         true,
+			wantErrContains: "fail to validate RegexTest /rotate-45/media/image.png with error: invalid rotate: 45",
+		},
+		{
+			name:    "successWithGravityFace",
+			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			endpoint: config.Endpoint{
+				Regex: "\\/(?<gravity>[a-z-]+)\\/(?<source>.*)",
+				RegexTests: []config.RegexTest{
+					{Path: "/face/media/image.png", ResultOpts: types.ResizeOption{OriginFormat: types.TypePNG, Source: "media/image.png", Gravity: types.TypeGravityFace}},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name:    "failWithOptNotEqual",
 			project: config.Project{AcceptTypeFiles: []string{types.TypePNG}},
@@ -11,6 +11,12 @@ const DefaultRequestTimeout = 2 * time.Second
 const DefaultBufferPoolSize = 5 // Value in Mo
 const DefaultMaxSourceWidth = 4096
 const DefaultMaxSourceHeight = 4096
+const DefaultMaxSourceFrames = 500
+const DefaultMaxSourceTotalPixels = 100_000_000
+const DefaultTextMaxLength = 64
+const DefaultTextMaxSize = 200
+const DefaultMaxDpr = 3.0
+const DefaultWatermarkOpacity = 1.0
 
 const (
 	SourceLimitModeOff         = "off"
@@ -22,6 +28,41 @@ type SourceLimitConfig struct {
 	Mode      string `mapstructure:"mode" validate:"required,oneof=off passthrough error"`
 	MaxWidth  int    `mapstructure:"max_width" validate:"required_unless=Mode off,omitempty,min=1"`
 	MaxHeight int    `mapstructure:"max_height" validate:"required_unless=Mode off,omitempty,min=1"`
+	// MaxFrames and MaxTotalPixels bound animated sources whatever the mode, 0 uses the default
+	MaxFrames      int `mapstructure:"max_frames" validate:"min=0"`
+	MaxTotalPixels int `mapstructure:"max_total_pixels" validate:"min=0"`
+}
+
+// TextConfig restricts the text overlays requests can ask for.
+type TextConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxLength is the maximum number of characters, DefaultTextMaxLength when 0
+	MaxLength int `mapstructure:"max_length" validate:"min=0"`
+	// MaxSize is the maximum font size in pixels, DefaultTextMaxSize when 0
+	MaxSize int `mapstructure:"max_size" validate:"min=0"`
+	// Fonts lists the fonts requests can use, the first one being the default. Only goregular when empty
+	Fonts []string `mapstructure:"fonts"`
+}
+
+func (t TextConfig) GetMaxLength() int {
+	if t.MaxLength == 0 {
+		return DefaultTextMaxLength
+	}
+	return t.MaxLength
+}
+
+func (t TextConfig) GetMaxSize() int {
+	if t.MaxSize == 0 {
+		return DefaultTextMaxSize
+	}
+	return t.MaxSize
+}
+
+func (t TextConfig) GetFonts() []string {
+	if len(t.Fonts) == 0 {
+		return []string{types.TypeFontGoRegular}
+	}
+	return t.Fonts
 }
 
 type Config struct {
@@ -29,6 +70,7 @@ type Config struct {
 
 	PidPath              string            `mapstructure:"pid_path" validate:"required"`
 	EnableFormatAutoAVIF bool              `mapstructure:"enable_format_auto_avif"`
+	EnableFormatAutoJXL  bool              `mapstructure:"enable_format_auto_jxl"`
 	AcceptTypeFiles      []string          `mapstructure:"accept_type_files" validate:"required"`
 	ResizeTypeFiles      []string          `mapstructure:"resize_type_files" validate:"required"`
 	ResizeCGI            ResizeCGIConfig   `mapstructure:"resize_cgi"`
@@ -37,6 +79,22 @@ type Config struct {
 	Projects             []Project         `mapstructure:"projects" validate:"unique-project-cfg,required,unique=ID,min=1,dive"`
 	BufferPoolSize       int               `mapstructure:"buffer_pool_size" validate:"min=1"`
 	SourceLimit          SourceLimitConfig `mapstructure:"source_limit" validate:"required"`
+	AutoOrient           bool              `mapstructure:"auto_orient"`
+	// MaxDpr caps the dpr option, DefaultMaxDpr when 0
+	MaxDpr float64 `mapstructure:"max_dpr" validate:"omitempty,min=1"`
+	// Text applies to the CDN-CGI route, which can only use the bundled fonts
+	Text TextConfig `mapstructure:"text"`
+	// FaceCascade is the path of a pico cascade used by gravity=face instead of the embedded facefinder cascade
+	FaceCascade string `mapstructure:"face_cascade"`
+
+	FormatDefaults types.FormatDefaults `mapstructure:"format_defaults" validate:"dive,keys,oneof=jpeg webp avif jxl,endkeys"`
+}
+
+func (c *Config) GetMaxDpr() float64 {
+	if c.MaxDpr == 0 {
+		return DefaultMaxDpr
+	}
+	return c.MaxDpr
 }
 
 type Project struct {
@@ -55,17 +113,47 @@ type Project struct {
 	ExtraHeaders         types.Headers `mapstructure:"extra_headers"`
 
 	WebhookToken string `mapstructure:"webhook_token"`
+	ArtDirection bool   `mapstructure:"art_direction"`
+	AutoOrient   *bool  `mapstructure:"auto_orient"`
+	Metadata     string `mapstructure:"metadata" validate:"omitempty,oneof=none copyright keep"`
+
+	// Text overrides the global text configuration
+	Text *TextConfig `mapstructure:"text"`
+	// FontSources maps font names to font files (TTF or OTF) in the project storage
+	FontSources map[string]string `mapstructure:"font_sources" validate:"dive,required"`
+
+	FormatDefaults types.FormatDefaults `mapstructure:"format_defaults" validate:"dive,keys,oneof=jpeg webp avif jxl,endkeys"`
 }
 
 type Endpoint struct {
 	Regex             string             `mapstructure:"regex"`
 	DefaultResizeOpts types.ResizeOption `mapstructure:"default_resize"`
 
+	FormatDefaults types.FormatDefaults `mapstructure:"format_defaults" validate:"dive,keys,oneof=jpeg webp avif jxl,endkeys"`
+
+	Watermark *WatermarkConfig `mapstructure:"watermark"`
+
 	CompiledRegex *regexp.Regexp
 
 	RegexTests []RegexTest `mapstructure:"regex_tests" validate:"dive"`
 }
 
+type WatermarkConfig struct {
+	Source   string `mapstructure:"source" validate:"required"`
+	Position string `mapstructure:"position" validate:"omitempty,oneof=center top bottom left right top-left top-right bottom-left bottom-right"`
+	Margin   int    `mapstructure:"margin" validate:"min=0"`
+	// Opacity goes from 0, fully transparent, to 1, fully opaque, DefaultWatermarkOpacity when not set
+	Opacity *float64 `mapstructure:"opacity" validate:"omitempty,min=0,max=1"`
+	Scale   float64  `mapstructure:"scale" validate:"min=0,max=1"`
+}
+
+func (w WatermarkConfig) GetOpacity() float64 {
+	if w.Opacity == nil {
+		return DefaultWatermarkOpacity
+	}
+	return *w.Opacity
+}
+
 type HTTPConfig struct {
 	Listen                    string          `mapstructure:"listen" validate:"required"`
 	AccessLogPath             string          `mapstructure:"access_log_path"`
@@ -137,14 +225,22 @@ func DefaultConfig() *Config {
 		ResizeTypeFiles: []string{
 			types.TypePNG,
 			types.TypeJPEG,
+			types.TypeAVIF,
+			types.TypeWEBP,
+			types.TypeHEIC,
+			types.TypeHEIF,
+			types.TypeJXL,
 		},
 		Headers:        types.Headers{},
 		RequestTimeout: DefaultRequestTimeout,
 		BufferPoolSize: DefaultBufferPoolSize,
 		SourceLimit: SourceLimitConfig{
-			Mode:      SourceLimitModeOff,
-			MaxWidth:  DefaultMaxSourceWidth,
-			MaxHeight: DefaultMaxSourceHeight,
+			Mode:           SourceLimitModeOff,
+			MaxWidth:       DefaultMaxSourceWidth,
+			MaxHeight:      DefaultMaxSourceHeight,
+			MaxFrames:      DefaultMaxSourceFrames,
+			MaxTotalPixels: DefaultMaxSourceTotalPixels,
 		},
+		AutoOrient: true,
 	}
 }
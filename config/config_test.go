@@ -32,15 +32,23 @@ func TestDefaultConfig(t *testing.T) {
 			ResizeTypeFiles: []string{
 				types.TypePNG,
 				types.TypeJPEG,
+				types.TypeAVIF,
+				types.TypeWEBP,
+				types.TypeHEIC,
+				types.TypeHEIF,
+				types.TypeJXL,
 			},
 			Headers:        types.Headers{},
 			RequestTimeout: DefaultRequestTimeout,
 			BufferPoolSize: DefaultBufferPoolSize,
 			SourceLimit: SourceLimitConfig{
-				Mode:      SourceLimitModeOff,
-				MaxWidth:  DefaultMaxSourceWidth,
-				MaxHeight: DefaultMaxSourceHeight,
+				Mode:           SourceLimitModeOff,
+				MaxWidth:       DefaultMaxSourceWidth,
+				MaxHeight:      DefaultMaxSourceHeight,
+				MaxFrames:      DefaultMaxSourceFrames,
+				MaxTotalPixels: DefaultMaxSourceTotalPixels,
 			},
+			AutoOrient: true,
 		},
 		got,
 	)
@@ -85,3 +93,29 @@ func TestBasicAuth_Enable(t *testing.T) {
 		})
 	}
 }
+
+func TestWatermarkConfig_GetOpacity(t *testing.T) {
+	transparent := 0.0
+
+	tests := []struct {
+		name    string
+		opacity *float64
+		want    float64
+	}{
+		{
+			name: "defaultOpaque",
+			want: 1,
+		},
+		{
+			name:    "transparent",
+			opacity: &transparent,
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := WatermarkConfig{Source: "watermark.png", Opacity: tt.opacity}
+			assert.Equalf(t, tt.want, w.GetOpacity(), "GetOpacity()")
+		})
+	}
+}
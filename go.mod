@@ -5,6 +5,8 @@ go 1.24.6
 require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/gen2brain/avif v0.4.4
+	github.com/gen2brain/heic v0.4.5
+	github.com/gen2brain/jpegxl v0.4.5
 	github.com/go-playground/validator/v10 v10.27.0
 	github.com/go-viper/mapstructure/v2 v2.2.1
 	github.com/jonboulle/clockwork v0.5.0
@@ -16,9 +18,12 @@ require (
 	github.com/spf13/afero v1.14.0
 	github.com/spf13/cobra v1.9.1
 	github.com/spf13/viper v1.20.1
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/stretchr/testify v1.10.0
 	github.com/valyala/fasthttp v1.65.0
 	go.uber.org/mock v0.6.0
+	golang.org/x/image v0.30.1-0.20250813145308-d93554662f37
 )
 
 require (
@@ -65,7 +70,6 @@ require (
 	go.uber.org/atomic v1.11.0 // indirect
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/crypto v0.41.1-0.20250904143959-9d779377cff7 // indirect
-	golang.org/x/image v0.30.1-0.20250813145308-d93554662f37 // indirect
 	golang.org/x/net v0.43.1-0.20250905201806-1ff92d3eb0c2 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
@@ -33,19 +33,83 @@ func init() {
 func DetectFormatFromHeaderAccept(ctx *context.Context, acceptHeaderValue string, opts *types.ResizeOption) {
 	acceptedFormat := strings.Split(acceptHeaderValue, ",")
 
-	if slices.Contains(types.TypesImages, opts.OriginFormat) {
-		if ctx.Config.EnableFormatAutoAVIF && slices.Contains([]string{types.TypeFormatAuto, types.TypeAVIF}, opts.Format) && slices.Contains(acceptedFormat, types.MimeTypeAVIF) {
-			opts.Format = types.TypeAVIF
-			return
-		} else if slices.Contains([]string{types.TypeFormatAuto, types.TypeWEBP, types.TypeAVIF}, opts.Format) && slices.Contains(acceptedFormat, types.MimeTypeWEBP) {
+	// animated GIFs can only be carried over to WebP, and only when GIFs are transformed at all
+	if opts.OriginFormat == types.TypeGIF && slices.Contains(ctx.Config.ResizeTypeFiles, types.TypeGIF) {
+		if slices.Contains([]string{types.TypeFormatAuto, types.TypeWEBP, types.TypeAVIF, types.TypeJXL}, opts.Format) && slices.Contains(acceptedFormat, types.MimeTypeWEBP) {
 			opts.Format = types.TypeWEBP
 			return
 		}
 	}
 
+	// SVG originals are rasterized for a bitmap format or a size, and keep their transparency in PNG unless the client
+	// displays a modern format
+	if opts.OriginFormat == types.TypeSVG {
+		if !slices.Contains(ctx.Config.ResizeTypeFiles, types.TypeSVG) || !needRasterize(opts) {
+			opts.Format = opts.OriginFormat
+		} else if opts.Format != types.TypeJPEG && opts.Format != types.TypePNG {
+			opts.Format = autoFormat(ctx, acceptedFormat, opts.Format, types.TypePNG)
+		}
+		return
+	}
+
+	// AVIF, WebP, JPEG XL, TIFF, BMP and HEIC originals can only be converted when they are decoded
+	convertibleOrigin := slices.Contains([]string{types.TypeAVIF, types.TypeWEBP, types.TypeJXL, types.TypeTIFF, types.TypeBMP, types.TypeHEIC, types.TypeHEIF}, opts.OriginFormat)
+	if convertibleOrigin && !slices.Contains(ctx.Config.ResizeTypeFiles, opts.OriginFormat) {
+		opts.Format = opts.OriginFormat
+		return
+	}
+
+	if slices.Contains(types.TypesImages, opts.OriginFormat) {
+		if format := autoFormat(ctx, acceptedFormat, opts.Format, ""); format != "" {
+			opts.Format = format
+			return
+		}
+	}
+
+	// browsers display neither TIFF, BMP nor HEIC, and only some of them AVIF, WebP and JPEG XL
+	webOrigin := slices.Contains([]string{types.TypeAVIF, types.TypeWEBP, types.TypeJXL}, opts.OriginFormat) && slices.Contains(acceptedFormat, types.GetMimeType(opts.OriginFormat))
+	if convertibleOrigin && !webOrigin {
+		opts.Format = downgradeFormat(opts)
+		return
+	}
+
 	opts.Format = opts.OriginFormat
 }
 
+// autoFormat returns AVIF, JPEG XL or WebP when the requested format allows it and the client accepts it, fallback
+// otherwise.
+func autoFormat(ctx *context.Context, acceptedFormat []string, format string, fallback string) string {
+	if ctx.Config.EnableFormatAutoAVIF && slices.Contains([]string{types.TypeFormatAuto, types.TypeAVIF}, format) && slices.Contains(acceptedFormat, types.MimeTypeAVIF) {
+		return types.TypeAVIF
+	} else if ctx.Config.EnableFormatAutoJXL && slices.Contains([]string{types.TypeFormatAuto, types.TypeJXL}, format) && slices.Contains(acceptedFormat, types.MimeTypeJXL) {
+		return types.TypeJXL
+	} else if slices.Contains([]string{types.TypeFormatAuto, types.TypeWEBP, types.TypeAVIF, types.TypeJXL}, format) && slices.Contains(acceptedFormat, types.MimeTypeWEBP) {
+		return types.TypeWEBP
+	}
+	return fallback
+}
+
+// needRasterize reports whether an SVG original is requested as a bitmap: in a bitmap format, or with the automatic
+// format at a size or with a watermark or a text, which can only be drawn on a bitmap.
+func needRasterize(opts *types.ResizeOption) bool {
+	if slices.Contains([]string{types.TypeJPEG, types.TypePNG, types.TypeWEBP, types.TypeAVIF, types.TypeJXL}, opts.Format) {
+		return true
+	}
+	return opts.Format == types.TypeFormatAuto && (opts.NeedResize() || opts.WatermarkImage != nil || opts.Text != "")
+}
+
+// downgradeFormat returns the format of the originals the client can't display: JPEG or PNG when requested, PNG for
+// lossless requests, JPEG otherwise.
+func downgradeFormat(opts *types.ResizeOption) string {
+	if opts.Format == types.TypeJPEG || opts.Format == types.TypePNG {
+		return opts.Format
+	}
+	if opts.Lossless {
+		return types.TypePNG
+	}
+	return types.TypeJPEG
+}
+
 func SendStream(ctx *context.Context, c echo.Context, opts *types.ResizeOption, content *bytes.Buffer) error {
 	defer func() {
 		resetBuffer(ctx, content)
@@ -54,25 +118,78 @@ func SendStream(ctx *context.Context, c echo.Context, opts *types.ResizeOption,
 	vary := []string{echo.HeaderAccept}
 	acceptHeaderValue := c.Request().Header.Get(echo.HeaderAccept)
 	DetectFormatFromHeaderAccept(ctx, acceptHeaderValue, opts)
+	if maxDpr := ctx.Config.GetMaxDpr(); opts.Dpr > maxDpr {
+		opts.Dpr = maxDpr
+	}
+	if opts.AutoOrient && opts.OriginFormat == types.TypeJPEG {
+		opts.Orientation = transform.ReadOrientation(content.Bytes())
+	}
 
 	needTransform := opts.NeedTransform() && slices.Contains(ctx.Config.ResizeTypeFiles, opts.OriginFormat)
-	if needTransform {
+	if needTransform && opts.OriginFormat == types.TypeWEBP && transform.IsAnimatedWebP(content.Bytes()) {
+		// animations are served as they are to the clients displaying WebP, unless an overlay has to be drawn on every
+		// frame, the others get a still of the first frame
+		if slices.Contains(strings.Split(acceptHeaderValue, ","), types.MimeTypeWEBP) {
+			needTransform = opts.WatermarkImage != nil || opts.Text != ""
+			opts.Format = opts.OriginFormat
+		} else {
+			opts.Format = downgradeFormat(opts)
+		}
+	}
+	if needTransform && opts.OriginFormat == types.TypeSVG && opts.Format == types.TypeSVG {
+		// SVG originals are only transformed once rasterized, overlays included (see needRasterize)
+		needTransform = false
+	}
+	if needTransform && opts.OriginFormat == types.TypeSVG {
+		if errValidate := transform.ValidateSVG(content, opts, ctx.Config.SourceLimit); errValidate != nil {
+			ctx.Logger.Error(fmt.Sprintf("failed to validate svg %s: %v", opts.Source, errValidate), addLogAttr(c)...)
+			c.Response().Header().Add(route.DebugInfoHeader, errValidate.Error())
+			return c.String(http.StatusUnprocessableEntity, fmt.Sprintf("svg not rendered: %s", opts.Source))
+		}
+	} else if needTransform {
+		if errPage := transform.ValidatePage(content, opts); errPage != nil {
+			ctx.Logger.Debug(fmt.Sprintf("%s: %s", errPage.Error(), opts.Source), addLogAttr(c)...)
+			return c.String(http.StatusBadRequest, errPage.Error())
+		}
 		sourceLimit := ctx.Config.SourceLimit
-		if errValidate := transform.ValidateSourceDimensions(content, sourceLimit); errValidate != nil {
+		if errValidate := transform.ValidateSourceDimensions(content, opts, sourceLimit); errValidate != nil {
 			ctx.Logger.Error(fmt.Sprintf("failed to validate image %s: %v", opts.Source, errValidate), addLogAttr(c)...)
 			c.Response().Header().Add(route.DebugInfoHeader, errValidate.Error())
-			if sourceLimit.Mode == config.SourceLimitModeError {
+			// a watermarked image is never served without its watermark
+			if sourceLimit.Mode == config.SourceLimitModeError || opts.WatermarkImage != nil {
 				return c.String(http.StatusUnprocessableEntity, fmt.Sprintf("image too large: %s", opts.Source))
 			}
 			needTransform = false
 		}
 	}
 	if needTransform {
+		if errCrop := transform.ValidateCropBounds(content, opts); errCrop != nil {
+			ctx.Logger.Debug(fmt.Sprintf("%s: %s", errCrop.Error(), opts.Source), addLogAttr(c)...)
+			return c.String(http.StatusBadRequest, errCrop.Error())
+		}
 		errTransform := transform.Transform(content, opts)
 		if errTransform != nil {
 			ctx.Logger.Error(fmt.Sprintf("failed to read data %s: %v", opts.Source, errTransform), addLogAttr(c)...)
 			return c.String(http.StatusInternalServerError, fmt.Sprintf("failed to transform image %s", opts.Source))
 		}
+	} else if opts.Metadata != "" {
+		stripped, errStrip := transform.StripMetadata(content.Bytes(), opts.OriginFormat, opts.Metadata)
+		if errStrip == nil {
+			content.Reset()
+			content.Write(stripped)
+		} else {
+			// the pixels are re-encoded instead, which drops the metadata as well
+			ctx.Logger.Warn(fmt.Sprintf("failed to strip metadata %s, re-encoding: %v", opts.Source, errStrip), addLogAttr(c)...)
+			opts.Format = opts.OriginFormat
+			errReencode := transform.ValidateSourceDimensions(content, opts, ctx.Config.SourceLimit)
+			if errReencode == nil {
+				errReencode = transform.Reencode(content, opts)
+			}
+			if errReencode != nil {
+				ctx.Logger.Error(fmt.Sprintf("failed to strip metadata %s: %v", opts.Source, errReencode), addLogAttr(c)...)
+				return c.String(http.StatusInternalServerError, fmt.Sprintf("failed to strip metadata %s", opts.Source))
+			}
+		}
 	}
 	contentHash, _ := hash.GenerateXXHashFromBytes(content.Bytes())
 
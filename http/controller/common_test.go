@@ -3,18 +3,25 @@ package controller
 import (
 	"bytes"
 	"fmt"
+	"image"
+	"image/color"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
+	"github.com/disintegration/imaging"
+	"github.com/kolesa-team/go-webp/webp"
 	"github.com/labstack/echo/v4"
 	"github.com/reflet-devops/go-media-resizer/config"
 	"github.com/reflet-devops/go-media-resizer/context"
 	"github.com/reflet-devops/go-media-resizer/http/route"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
 )
 
 func TestDetectFormatFromHeaderAccept(t *testing.T) {
@@ -23,6 +30,8 @@ func TestDetectFormatFromHeaderAccept(t *testing.T) {
 		name                 string
 		acceptHeaderValue    string
 		enableFormatAutoAVIF bool
+		enableFormatAutoJXL  bool
+		resizeTypeFiles      []string
 		opts                 *types.ResizeOption
 		want                 *types.ResizeOption
 	}{
@@ -96,11 +105,255 @@ func TestDetectFormatFromHeaderAccept(t *testing.T) {
 			opts:                 &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeFormatAuto},
 			want:                 &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG},
 		},
+		{
+			name:                 "detectFormatWebpWithGifAndAutoAndGoodAcceptHeader",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeGIF},
+			acceptHeaderValue:    "image/avif,image/webp,image/gif",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatGifWithGifAndAutoAndWrongAcceptHeader",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeGIF},
+			acceptHeaderValue:    "image/avif,image/gif",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF},
+		},
+		{
+			name:                 "detectFormatGifWithGifNotInResizeTypeFiles",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypePNG},
+			acceptHeaderValue:    "image/webp,image/gif",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF},
+		},
+		{
+			name:                 "detectFormatWebpWithWebpOriginAndGoodAcceptHeader",
+			enableFormatAutoAVIF: false,
+			acceptHeaderValue:    "image/webp,image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatJpegWithWebpOriginAndWrongAcceptHeader",
+			enableFormatAutoAVIF: true,
+			acceptHeaderValue:    "image/png,image/jpeg",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatPngWithWebpOriginAndLosslessAndWrongAcceptHeader",
+			enableFormatAutoAVIF: true,
+			acceptHeaderValue:    "image/png,image/jpeg",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeFormatAuto, Lossless: true},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypePNG, Lossless: true},
+		},
+		{
+			name:                 "detectFormatPngWithAvifOriginAndPngAndWrongAcceptHeader",
+			enableFormatAutoAVIF: true,
+			acceptHeaderValue:    "image/png,image/jpeg",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypePNG},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypePNG},
+		},
+		{
+			name:                 "detectFormatWebpWithAvifOriginAndDisabledFormatAutoAVIF",
+			enableFormatAutoAVIF: false,
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatAvifWithAvifOriginAndOnlyAvifAcceptHeader",
+			enableFormatAutoAVIF: false,
+			acceptHeaderValue:    "image/avif,image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypeAVIF},
+		},
+		{
+			name:                 "detectFormatWebpWithTiffOriginAndGoodAcceptHeader",
+			enableFormatAutoAVIF: false,
+			resizeTypeFiles:      []string{types.TypeTIFF},
+			acceptHeaderValue:    "image/webp,image/tiff",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatJpegWithTiffOriginAndTiffAcceptHeader",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeTIFF},
+			acceptHeaderValue:    "image/tiff,image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatPngWithBmpOriginAndPng",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeBMP},
+			acceptHeaderValue:    "image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeBMP, Format: types.TypePNG},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeBMP, Format: types.TypePNG},
+		},
+		{
+			name:                "detectFormatJxlWithAutoAndGoodAcceptHeader",
+			enableFormatAutoJXL: true,
+			acceptHeaderValue:   "image/jxl,image/webp,image/png",
+			opts:                &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeFormatAuto},
+			want:                &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeJXL},
+		},
+		{
+			name:              "detectFormatWebpWithAutoAndJxlDisabled",
+			acceptHeaderValue: "image/jxl,image/webp,image/png",
+			opts:              &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeFormatAuto},
+			want:              &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatAvifBeforeJxl",
+			enableFormatAutoAVIF: true,
+			enableFormatAutoJXL:  true,
+			acceptHeaderValue:    "image/avif,image/jxl,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeAVIF},
+		},
+		{
+			name:                 "detectFormatJxlWithJxlFormatAndAvifAccepted",
+			enableFormatAutoAVIF: true,
+			enableFormatAutoJXL:  true,
+			acceptHeaderValue:    "image/avif,image/jxl",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJXL},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJXL},
+		},
+		{
+			name:                "detectFormatWebpWithJxlFormatAndOldClient",
+			enableFormatAutoJXL: true,
+			acceptHeaderValue:   "image/webp,image/png",
+			opts:                &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJXL},
+			want:                &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeWEBP},
+		},
+		{
+			name:              "detectFormatJxlWithJxlOriginAndJxlAcceptHeader",
+			resizeTypeFiles:   []string{types.TypeJXL},
+			acceptHeaderValue: "image/jxl,image/png",
+			opts:              &types.ResizeOption{OriginFormat: types.TypeJXL, Format: types.TypeFormatAuto},
+			want:              &types.ResizeOption{OriginFormat: types.TypeJXL, Format: types.TypeJXL},
+		},
+		{
+			name:              "detectFormatJpegWithJxlOriginAndOldClient",
+			resizeTypeFiles:   []string{types.TypeJXL},
+			acceptHeaderValue: "image/png",
+			opts:              &types.ResizeOption{OriginFormat: types.TypeJXL, Format: types.TypeFormatAuto},
+			want:              &types.ResizeOption{OriginFormat: types.TypeJXL, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatWebpWithHeicOriginAndGoodAcceptHeader",
+			enableFormatAutoAVIF: false,
+			resizeTypeFiles:      []string{types.TypeHEIC},
+			acceptHeaderValue:    "image/webp,image/heic",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeHEIC, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeHEIC, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatJpegWithHeifOriginAndHeifAcceptHeader",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeHEIF},
+			acceptHeaderValue:    "image/heif,image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeHEIF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeHEIF, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatJpegWithHeicOriginAndHeicFormat",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeHEIC},
+			acceptHeaderValue:    "image/heic",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeHEIC, Format: types.TypeHEIC},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeHEIC, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatTiffWithTiffOriginNotInResizeTypeFiles",
+			enableFormatAutoAVIF: true,
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeTIFF},
+		},
+		{
+			name:                 "detectFormatWebpWithWebpOriginNotInResizeTypeFiles",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypePNG},
+			acceptHeaderValue:    "image/avif,image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeWEBP},
+		},
+		{
+			name:                 "detectFormatSvgWithSvgOriginNotInResizeTypeFiles",
+			enableFormatAutoAVIF: true,
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypePNG, Width: 64},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeSVG, Width: 64},
+		},
+		{
+			name:                 "detectFormatSvgWithAutoWithoutSize",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeSVG},
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeFormatAuto},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeSVG},
+		},
+		{
+			name:              "detectFormatWebpWithSvgOriginAndWatermark",
+			resizeTypeFiles:   []string{types.TypeSVG},
+			acceptHeaderValue: "image/webp,image/svg+xml",
+			opts:              &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeFormatAuto, WatermarkImage: &types.Watermark{}},
+			want:              &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeWEBP, WatermarkImage: &types.Watermark{}},
+		},
+		{
+			name:              "detectFormatPngWithSvgOriginAndTextAndOldClient",
+			resizeTypeFiles:   []string{types.TypeSVG},
+			acceptHeaderValue: "image/png,image/svg+xml",
+			opts:              &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeFormatAuto, Text: "SOLD"},
+			want:              &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypePNG, Text: "SOLD"},
+		},
+		{
+			name:                 "detectFormatAvifWithSvgOriginAndSize",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeSVG},
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeFormatAuto, Width: 64},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeAVIF, Width: 64},
+		},
+		{
+			name:                 "detectFormatPngWithSvgOriginAndSizeAndOldClient",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeSVG},
+			acceptHeaderValue:    "image/png,image/svg+xml",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeFormatAuto, Height: 64},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypePNG, Height: 64},
+		},
+		{
+			name:                 "detectFormatJpegWithSvgOriginAndJpeg",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeSVG},
+			acceptHeaderValue:    "image/avif,image/webp",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeJPEG},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeJPEG},
+		},
+		{
+			name:                 "detectFormatPngWithSvgOriginAndWebpAndOldClient",
+			enableFormatAutoAVIF: true,
+			resizeTypeFiles:      []string{types.TypeSVG},
+			acceptHeaderValue:    "image/png",
+			opts:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeWEBP},
+			want:                 &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypePNG},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.TestContext(nil)
 			ctx.Config.EnableFormatAutoAVIF = tt.enableFormatAutoAVIF
+			ctx.Config.EnableFormatAutoJXL = tt.enableFormatAutoJXL
+			if tt.resizeTypeFiles != nil {
+				ctx.Config.ResizeTypeFiles = tt.resizeTypeFiles
+			}
 			DetectFormatFromHeaderAccept(ctx, tt.acceptHeaderValue, tt.opts)
 			assert.Equal(t, tt.want, tt.opts)
 		})
@@ -110,13 +363,25 @@ func TestDetectFormatFromHeaderAccept(t *testing.T) {
 func TestSendStream(t *testing.T) {
 	ctx := context.TestContext(nil)
 	ctx.Config.EnableFormatAutoAVIF = true
+	// a VP8X header with the animation flag, the frames aren't read
+	animatedWebP := []byte("RIFF\x16\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x02\x00\x00\x00\x09\x00\x00\x09\x00\x00")
+	// the fixture GIF converted to an animated WebP
+	animatedFixture := func() []byte {
+		data, errRead := os.ReadFile("../../fixtures/animated.gif")
+		assert.NoError(t, errRead)
+		buff := bytes.NewBuffer(data)
+		assert.NoError(t, transform.Transform(buff, &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeWEBP}))
+		assert.True(t, transform.IsAnimatedWebP(buff.Bytes()))
+		return buff.Bytes()
+	}
 
 	tests := []struct {
-		name         string
-		opts         *types.ResizeOption
-		headerAccept string
-		contentFn    func() *bytes.Buffer
-		sourceLimit  *config.SourceLimitConfig
+		name            string
+		opts            *types.ResizeOption
+		headerAccept    string
+		contentFn       func() *bytes.Buffer
+		sourceLimit     *config.SourceLimitConfig
+		resizeTypeFiles []string
 
 		wantErr assert.ErrorAssertionFunc
 		wantFn  func(t *testing.T, rec *httptest.ResponseRecorder)
@@ -155,6 +420,83 @@ func TestSendStream(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name:            "successWithSVGRasterized",
+			opts:            &types.ResizeOption{Format: types.TypePNG, Width: 64, OriginFormat: types.TypeSVG, Source: "/logo.svg"},
+			headerAccept:    "image/png",
+			resizeTypeFiles: []string{types.TypeSVG},
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"><rect width="20" height="20" fill="red"/></svg>`)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypePNG, rec.Header().Get(echo.HeaderContentType))
+				cfg, format, errDecode := image.DecodeConfig(rec.Body)
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "png", format)
+				assert.Equal(t, image.Pt(64, 32), image.Pt(cfg.Width, cfg.Height))
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:            "successWithSVGRasterizedForWatermark",
+			opts:            &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeSVG, Source: "/logo.svg", WatermarkImage: &types.Watermark{Image: imaging.New(4, 4, color.White), Opacity: 1}},
+			headerAccept:    "image/png,image/svg+xml",
+			resizeTypeFiles: []string{types.TypeSVG},
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20"><rect width="40" height="20" fill="red"/></svg>`)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypePNG, rec.Header().Get(echo.HeaderContentType))
+				img, format, errDecode := image.Decode(rec.Body)
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "png", format)
+				assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+				// the watermark sits in the bottom right corner by default
+				assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBAModel.Convert(img.At(38, 18)))
+				assert.Equal(t, color.NRGBA{R: 255, A: 255}, color.NRGBAModel.Convert(img.At(5, 5)))
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:            "successWithSVGKeptWithoutSize",
+			opts:            &types.ResizeOption{Format: types.TypeFormatAuto, Blur: 2, OriginFormat: types.TypeSVG, Source: "/logo.svg"},
+			headerAccept:    "image/avif,image/webp,image/svg+xml",
+			resizeTypeFiles: []string{types.TypeSVG},
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.WriteString("hello")
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeSVG, rec.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, []byte("hello"), rec.Body.Bytes())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:            "failedWithSVGExternalReference",
+			opts:            &types.ResizeOption{Format: types.TypeFormatAuto, Width: 64, OriginFormat: types.TypeSVG, Source: "/logo.svg"},
+			headerAccept:    "image/webp",
+			resizeTypeFiles: []string{types.TypeSVG},
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><image href="http://169.254.169.254/" width="10" height="10"/></svg>`)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+				assert.Equal(t, "svg external reference not allowed: http://169.254.169.254/", rec.Header().Get(route.DebugInfoHeader))
+				assert.Equal(t, "svg not rendered: /logo.svg", rec.Body.String())
+			},
+			wantErr: assert.NoError,
+		},
 		{
 			name:         "successWithResizeFormat",
 			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypePNG, Source: "/paysage.png", Headers: types.Headers{"X-Custom": "foo"}, Width: 500},
@@ -174,6 +516,184 @@ func TestSendStream(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name:         "successWithWebPDowngradedToJPEG",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeWEBP, Source: "/photo.webp", Width: 20},
+			headerAccept: "image/png,image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				assert.NoError(t, webp.Encode(buff, imaging.New(40, 20, color.White), nil))
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				cfg, format, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "jpeg", format)
+				assert.Equal(t, 20, cfg.Width)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successWithAnimatedWebPPassthrough",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeWEBP, Source: "/anim.webp", Width: 20},
+			headerAccept: "image/webp,image/png,image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(animatedWebP)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeWEBP, rec.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, animatedWebP, rec.Body.Bytes())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successWithAnimatedWebPWatermarkOnEveryFrame",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeWEBP, Source: "/anim.webp", WatermarkImage: &types.Watermark{Image: imaging.New(4, 4, color.NRGBA{R: 255, B: 255, A: 255}), Opacity: 1}},
+			headerAccept: "image/webp,image/png,image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(animatedFixture())
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeWEBP, rec.Header().Get(echo.HeaderContentType))
+				assert.True(t, transform.IsAnimatedWebP(rec.Body.Bytes()))
+				assert.NotEqual(t, animatedFixture(), rec.Body.Bytes())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successWithAnimatedWebPFirstFrameToJPEG",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeWEBP, Source: "/anim.webp", Width: 32},
+			headerAccept: "image/png,image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				data, errRead := os.ReadFile("../../fixtures/animated.gif")
+				assert.NoError(t, errRead)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(data)
+				assert.NoError(t, transform.Transform(buff, &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeWEBP}))
+				assert.True(t, transform.IsAnimatedWebP(buff.Bytes()))
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				cfg, format, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "jpeg", format)
+				assert.Equal(t, image.Pt(32, 16), image.Pt(cfg.Width, cfg.Height))
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successWithAutoOrient",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeJPEG, Source: "/orientation-6.jpg", AutoOrient: true},
+			headerAccept: "image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				file, errOpen := os.Open("../../fixtures/orientation-6.jpg")
+				assert.NoError(t, errOpen)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				_, _ = io.Copy(buff, file)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				cfg, _, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, 32, cfg.Width)
+				assert.Equal(t, 64, cfg.Height)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successWithoutAutoOrient",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeJPEG, Source: "/orientation-6.jpg"},
+			headerAccept: "image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				file, errOpen := os.Open("../../fixtures/orientation-6.jpg")
+				assert.NoError(t, errOpen)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				_, _ = io.Copy(buff, file)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				fixture, errRead := os.ReadFile("../../fixtures/orientation-6.jpg")
+				assert.NoError(t, errRead)
+				assert.Equal(t, fixture, rec.Body.Bytes())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successStripMetadataPassthrough",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeJPEG, Source: "/orientation-6.jpg", Metadata: types.TypeMetadataNone},
+			headerAccept: "image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				fixture, errRead := os.ReadFile("../../fixtures/orientation-6.jpg")
+				assert.NoError(t, errRead)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(fixture[:2])
+				buff.Write([]byte{0xFF, 0xFE, 0x00, 0x08, 's', 'e', 'c', 'r', 'e', 't'})
+				buff.Write(fixture[2:])
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				assert.NotContains(t, rec.Body.String(), "secret")
+				assert.Equal(t, 6, transform.ReadOrientation(rec.Body.Bytes()))
+				assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get(echo.HeaderContentLength))
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "successStripMetadataReencoded",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeJPEG, Source: "/orientation-6.jpg", Metadata: types.TypeMetadataNone},
+			headerAccept: "image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				fixture, errRead := os.ReadFile("../../fixtures/orientation-6.jpg")
+				assert.NoError(t, errRead)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(fixture[:2])
+				buff.Write([]byte{0xFF, 0xFE, 0x00, 0x08, 's', 'e', 'c', 'r', 'e', 't'})
+				// a stray byte between two segments, which the decoder skips but the segment walk can't
+				buff.WriteByte(0x00)
+				buff.Write(fixture[2:])
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				assert.NotContains(t, rec.Body.String(), "secret")
+				_, format, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "jpeg", format)
+				assert.Equal(t, strconv.Itoa(rec.Body.Len()), rec.Header().Get(echo.HeaderContentLength))
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "failedStripMetadata",
+			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeJPEG, Source: "/broken.jpg", Metadata: types.TypeMetadataNone},
+			headerAccept: "image/jpeg",
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.WriteString("broken")
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+				assert.Equal(t, "failed to strip metadata /broken.jpg", rec.Body.String())
+			},
+			wantErr: assert.NoError,
+		},
 		{
 			name:         "failedValidateDimensionsWithErrorMode",
 			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypePNG, Source: "/paysage.png", Width: 500},
@@ -191,6 +711,65 @@ func TestSendStream(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name:            "passthroughGIFAboveFrameLimitWithModeOff",
+			opts:            &types.ResizeOption{Format: types.TypeGIF, OriginFormat: types.TypeGIF, Source: "/animated.gif", Width: 32},
+			headerAccept:    "image/gif",
+			sourceLimit:     &config.SourceLimitConfig{Mode: config.SourceLimitModeOff, MaxFrames: 2},
+			resizeTypeFiles: []string{types.TypeGIF},
+			contentFn: func() *bytes.Buffer {
+				data, errRead := os.ReadFile("../../fixtures/animated.gif")
+				assert.NoError(t, errRead)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(data)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, "source image frame count 4 exceed maximum allowed 2", rec.Header().Get(route.DebugInfoHeader))
+				data, errRead := os.ReadFile("../../fixtures/animated.gif")
+				assert.NoError(t, errRead)
+				assert.Equal(t, data, rec.Body.Bytes())
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:            "successWithHEICOriginToJPEG",
+			opts:            &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypeHEIC, Source: "/photo.heic"},
+			headerAccept:    "image/jpeg,image/png",
+			resizeTypeFiles: []string{types.TypeHEIC},
+			contentFn: func() *bytes.Buffer {
+				data, errRead := os.ReadFile("../../fixtures/photo.heic")
+				assert.NoError(t, errRead)
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				buff.Write(data)
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, types.MimeTypeJPEG, rec.Header().Get(echo.HeaderContentType))
+				_, format, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, "jpeg", format)
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:            "failedWithMissingTIFFPage",
+			opts:            &types.ResizeOption{Format: types.TypeJPEG, OriginFormat: types.TypeTIFF, Source: "/catalog.tiff", Page: 2},
+			headerAccept:    "image/jpeg",
+			resizeTypeFiles: []string{types.TypeTIFF},
+			contentFn: func() *bytes.Buffer {
+				buff := ctx.BufferPool.Get().(*bytes.Buffer)
+				assert.NoError(t, tiff.Encode(buff, image.NewGray(image.Rect(0, 0, 4, 2)), nil))
+				return buff
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, "page 2 not found", rec.Body.String())
+			},
+			wantErr: assert.NoError,
+		},
 		{
 			name:         "passthroughTransformWithPassthroughMode",
 			opts:         &types.ResizeOption{Format: types.TypeFormatAuto, OriginFormat: types.TypePNG, Source: "/paysage.png", Width: 500},
@@ -218,6 +797,11 @@ func TestSendStream(t *testing.T) {
 				ctx.Config.SourceLimit = *tt.sourceLimit
 				defer func() { ctx.Config.SourceLimit = origSourceLimit }()
 			}
+			if tt.resizeTypeFiles != nil {
+				origResizeTypeFiles := ctx.Config.ResizeTypeFiles
+				ctx.Config.ResizeTypeFiles = tt.resizeTypeFiles
+				defer func() { ctx.Config.ResizeTypeFiles = origResizeTypeFiles }()
+			}
 
 			e := echo.New()
 			e.HideBanner = true
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	buildinHttp "net/http"
+	"slices"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -12,6 +13,7 @@ import (
 	"github.com/reflet-devops/go-media-resizer/http/urltools"
 	"github.com/reflet-devops/go-media-resizer/logger"
 	"github.com/reflet-devops/go-media-resizer/mapstructure"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/valyala/fasthttp"
 )
@@ -26,7 +28,10 @@ func GetMediaCGI(ctx *context.Context) func(c echo.Context) error {
 		fileExtension := urltools.GetExtension(source)
 		fileType := types.GetType(fileExtension)
 		opts.OriginFormat = fileType
-		optMap := parseOption(c.Param("options"))
+		optMap, errOption := parseOption(c.Param("options"))
+		if errOption != nil {
+			return c.String(buildinHttp.StatusBadRequest, errOption.Error())
+		}
 
 		fileTypeIsValid := types.ValidateType(fileType, ctx.Config.AcceptTypeFiles)
 		if !fileTypeIsValid {
@@ -38,6 +43,23 @@ func GetMediaCGI(ctx *context.Context) func(c echo.Context) error {
 		if err != nil {
 			return c.String(buildinHttp.StatusInternalServerError, err.Error())
 		}
+		if errText := transform.ValidateText(opts, ctx.Config.Text); errText != nil {
+			return c.String(buildinHttp.StatusBadRequest, errText.Error())
+		}
+		if errCrop := transform.ValidateCrop(opts); errCrop != nil {
+			return c.String(buildinHttp.StatusBadRequest, errCrop.Error())
+		}
+		if errRotate := transform.ValidateRotate(opts); errRotate != nil {
+			return c.String(buildinHttp.StatusBadRequest, errRotate.Error())
+		}
+		if opts.Text != "" {
+			font, errFont := transform.BundledFont(opts.TextFont)
+			if errFont != nil {
+				ctx.Logger.Error(fmt.Sprintf("GetMediaCGI: failed to load font: %v", errFont), addLogAttr(c)...)
+				return c.String(buildinHttp.StatusInternalServerError, "failed to load font")
+			}
+			opts.Font = font
+		}
 
 		buffer := ctx.BufferPool.Get().(*bytes.Buffer)
 		projectIdHeader, errFetch := fetchCGIResource(ctx, c.Request().Header.Get(echo.HeaderXRequestID), source, buffer)
@@ -50,13 +72,23 @@ func GetMediaCGI(ctx *context.Context) func(c echo.Context) error {
 		for k, v := range ctx.Config.Headers {
 			opts.AddHeader(k, v)
 		}
+		opts.AutoOrient = ctx.Config.AutoOrient
+		opts.FormatDefaults = ctx.Config.FormatDefaults
 		return SendStream(ctx, c, opts, buffer)
 	}
 }
 
-func parseOption(optsHeader string) map[string]interface{} {
+// continuedOptions are the options whose value can hold commas: texts, crops, rgb() and rgba() colors and duotone
+// color pairs.
+var continuedOptions = []string{"text", "crop", "background", "tint", "duotone", "text_color", "text_shadow"}
+
+// parseOption reads the comma separated options. A token without = continues the value of the
+// previous option when it is one of continuedOptions, so values such as rgba(0,0,0,0.5) can hold commas.
+// Any other token without = is refused, rather than being glued to a numeric value such as height=50,fit.
+func parseOption(optsHeader string) (map[string]interface{}, error) {
 	optRaw := strings.Split(optsHeader, ",")
 	optMap := map[string]interface{}{}
+	lastKey := ""
 	for _, optStr := range optRaw {
 		optSplit := strings.Split(optStr, "=")
 		if len(optSplit) == 2 {
@@ -67,9 +99,17 @@ func parseOption(optsHeader string) map[string]interface{} {
 			value = strings.Trim(value, " ")
 
 			optMap[key] = value
+			lastKey = key
+		} else if len(optSplit) == 1 && strings.Trim(optStr, " ") != "" {
+			if !slices.Contains(continuedOptions, lastKey) {
+				return nil, fmt.Errorf("invalid option: %s", strings.Trim(optStr, " "))
+			}
+			optMap[lastKey] = fmt.Sprintf("%s,%s", optMap[lastKey], strings.Trim(optStr, " "))
+		} else {
+			lastKey = ""
 		}
 	}
-	return optMap
+	return optMap, nil
 }
 
 func fetchCGIResource(ctx *context.Context, requestId string, source string, buffer *bytes.Buffer) (string, error) {
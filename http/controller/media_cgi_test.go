@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/reflet-devops/go-media-resizer/config"
 	"github.com/reflet-devops/go-media-resizer/context"
 	"github.com/reflet-devops/go-media-resizer/http/route"
 	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
@@ -111,6 +112,78 @@ func Test_GetMediaCGI_Decode_Error(t *testing.T) {
 	assert.Contains(t, body, "decoding failed due to the following error(s):")
 }
 
+func Test_GetMediaCGI_Text_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		textCfg  config.TextConfig
+		options  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "failedDisabled",
+			options:  "text=SOLD",
+			wantCode: http.StatusBadRequest,
+			wantBody: "text overlay is not enabled",
+		},
+		{
+			name:     "failedTooLong",
+			textCfg:  config.TextConfig{Enabled: true, MaxLength: 3},
+			options:  "text=SOLD",
+			wantCode: http.StatusBadRequest,
+			wantBody: "text exceeds 3 characters",
+		},
+		{
+			name:     "failedCrop",
+			options:  "crop=10,20,30",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid crop: 10,20,30",
+		},
+		{
+			name:     "failedStrayToken",
+			options:  "height=50,fit",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid option: fit",
+		},
+		{
+			name:     "failedRotate",
+			options:  "rotate=45",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid rotate: 45",
+		},
+		{
+			name:     "failedStorageFont",
+			textCfg:  config.TextConfig{Enabled: true, Fonts: []string{"brand"}},
+			options:  "text=SOLD",
+			wantCode: http.StatusInternalServerError,
+			wantBody: "failed to load font",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TestContext(nil)
+			ctx.Config.AcceptTypeFiles = []string{types.TypePNG}
+			ctx.Config.Text = tt.textCfg
+			e := echo.New()
+			e.HideBanner = true
+			e.HidePort = true
+
+			req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/images.png", nil)
+			req.Host = "127.0.0.1"
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/images.png")
+			c.SetParamNames("source", "options")
+			c.SetParamValues("https://test.test/images.png", tt.options)
+
+			err := GetMediaCGI(ctx)(c)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
 func Test_GetMediaCGI_fetchCGIResource_Fail(t *testing.T) {
 	ctx := context.TestContext(nil)
 
@@ -238,15 +311,49 @@ func Test_fetchCGIResource_StatusCode_Error(t *testing.T) {
 
 func Test_parseOption(t *testing.T) {
 
-	options := " height= 100, width = 100, type=something"
+	options := " height= 100, width = 100, type=something, gravity=0.3x0.7, lossless=true, near_lossless=60, background=rgba(0, 0,0,0.5), fit=pad,, broken=a=b, quality=80, trim=10;20;10;20, duotone=000080, rgb(255,215,0), grayscale=true, progressive=true, chroma=444, page=2, crop=0.1,0.1,0.5,0.5, text=SOLD,NOW,"
 
 	want := map[string]interface{}{
-		"height": "100",
-		"width":  "100",
-		"type":   "something",
+		"height":        "100",
+		"width":         "100",
+		"type":          "something",
+		"gravity":       "0.3x0.7",
+		"lossless":      "true",
+		"near_lossless": "60",
+		"background":    "rgba(0,0,0,0.5)",
+		"fit":           "pad",
+		"quality":       "80",
+		"trim":          "10;20;10;20",
+		"duotone":       "000080,rgb(255,215,0)",
+		"grayscale":     "true",
+		"progressive":   "true",
+		"chroma":        "444",
+		"page":          "2",
+		"crop":          "0.1,0.1,0.5,0.5",
+		"text":          "SOLD,NOW",
 	}
 
-	got := parseOption(options)
+	got, err := parseOption(options)
+	assert.NoError(t, err)
 	assert.Equal(t, want, got)
 
 }
+
+func Test_parseOption_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr string
+	}{
+		{name: "afterNumber", options: "height=50,fit", wantErr: "invalid option: fit"},
+		{name: "afterBrokenOption", options: "broken=a=b, 1", wantErr: "invalid option: 1"},
+		{name: "first", options: "pad,width=100", wantErr: "invalid option: pad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOption(tt.options)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, got)
+		})
+	}
+}
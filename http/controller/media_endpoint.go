@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -13,10 +15,13 @@ import (
 	"github.com/reflet-devops/go-media-resizer/http/route"
 	"github.com/reflet-devops/go-media-resizer/http/urltools"
 	"github.com/reflet-devops/go-media-resizer/parser"
+	"github.com/reflet-devops/go-media-resizer/storage"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
+	"golang.org/x/image/font/opentype"
 )
 
-func GetMedia(ctx *context.Context, project *config.Project, storage types.Storage) func(c echo.Context) error {
+func GetMedia(ctx *context.Context, project *config.Project, storageInstance types.Storage, assets *storage.AssetCache) func(c echo.Context) error {
 	return func(c echo.Context) error {
 
 		requestPath := c.Request().RequestURI
@@ -35,7 +40,32 @@ func GetMedia(ctx *context.Context, project *config.Project, storage types.Stora
 				continue
 			}
 
-			file, errGetFile := storage.GetFile(opts.Source)
+			// the capture groups are read on the raw request URI
+			text, errUnescape := url.PathUnescape(opts.Text)
+			if errUnescape != nil {
+				ctx.Logger.Debug(fmt.Sprintf("invalid text: %s: %s", errUnescape.Error(), requestPath))
+				return c.String(http.StatusBadRequest, "invalid text")
+			}
+			opts.Text = text
+
+			textCfg := ctx.Config.Text
+			if project.Text != nil {
+				textCfg = *project.Text
+			}
+			if errText := transform.ValidateText(opts, textCfg); errText != nil {
+				ctx.Logger.Debug(fmt.Sprintf("%s: %s", errText.Error(), requestPath))
+				return c.String(http.StatusBadRequest, errText.Error())
+			}
+			if errCrop := transform.ValidateCrop(opts); errCrop != nil {
+				ctx.Logger.Debug(fmt.Sprintf("%s: %s", errCrop.Error(), requestPath))
+				return c.String(http.StatusBadRequest, errCrop.Error())
+			}
+			if errRotate := transform.ValidateRotate(opts); errRotate != nil {
+				ctx.Logger.Debug(fmt.Sprintf("%s: %s", errRotate.Error(), requestPath))
+				return c.String(http.StatusBadRequest, errRotate.Error())
+			}
+
+			file, errGetFile := storageInstance.GetFile(opts.Source)
 			if errGetFile != nil {
 				ctx.Logger.Debug(fmt.Sprintf("failed to get file %s: %s", errGetFile.Error(), opts.Source), addLogAttr(c)...)
 				return c.String(http.StatusNotFound, "file not found")
@@ -53,6 +83,58 @@ func GetMedia(ctx *context.Context, project *config.Project, storage types.Stora
 			opts.AddTag(types.GetTagSourcePathHash(
 				types.FormatProjectPathHash(project.ID, urltools.FormatPathWithPrefix(project.PrefixPath, opts.Source))),
 			)
+			if project.ArtDirection && opts.NeedResize() {
+				artDirection, errArtDirection := storage.GetArtDirection(storageInstance, opts.Source)
+				if errArtDirection != nil {
+					ctx.Logger.Error(fmt.Sprintf("failed to read art direction %s: %v", opts.Source, errArtDirection), addLogAttr(c)...)
+				}
+				opts.ArtDirection = artDirection
+				opts.AddTag(types.GetTagSourcePathHash(
+					types.FormatProjectPathHash(project.ID, urltools.FormatPathWithPrefix(project.PrefixPath, types.GetSidecarPath(opts.Source)))),
+				)
+			}
+			if endpoint.Watermark != nil && opts.WatermarkEnabled() && slices.Contains(ctx.Config.ResizeTypeFiles, opts.OriginFormat) {
+				watermark, errWatermark := assets.GetWatermark(endpoint.Watermark.Source)
+				if errWatermark != nil {
+					ctx.Logger.Error(fmt.Sprintf("failed to load watermark for %s: %v", opts.Source, errWatermark), addLogAttr(c)...)
+					resetBuffer(ctx, buffer)
+					return c.String(http.StatusInternalServerError, "failed to load watermark")
+				}
+				opts.WatermarkImage = &types.Watermark{
+					Image:    watermark,
+					Position: endpoint.Watermark.Position,
+					Margin:   endpoint.Watermark.Margin,
+					Opacity:  endpoint.Watermark.GetOpacity(),
+					Scale:    endpoint.Watermark.Scale,
+				}
+				// purges every variant carrying the watermark when it changes
+				opts.AddTag(types.GetTagSourcePathHash(
+					types.FormatProjectPathHash(project.ID, urltools.FormatPathWithPrefix(project.PrefixPath, endpoint.Watermark.Source))),
+				)
+			}
+			if opts.Text != "" && slices.Contains(ctx.Config.ResizeTypeFiles, opts.OriginFormat) {
+				var font *opentype.Font
+				var errFont error
+				if fontSource, ok := project.FontSources[opts.TextFont]; ok {
+					font, errFont = assets.GetFont(fontSource)
+					opts.AddTag(types.GetTagSourcePathHash(
+						types.FormatProjectPathHash(project.ID, urltools.FormatPathWithPrefix(project.PrefixPath, fontSource))),
+					)
+				} else {
+					font, errFont = transform.BundledFont(opts.TextFont)
+				}
+				if errFont != nil {
+					ctx.Logger.Error(fmt.Sprintf("failed to load font for %s: %v", opts.Source, errFont), addLogAttr(c)...)
+					resetBuffer(ctx, buffer)
+					return c.String(http.StatusInternalServerError, "failed to load font")
+				}
+				opts.Font = font
+			}
+			opts.AutoOrient = project.AutoOrient != nil && *project.AutoOrient
+			opts.FormatDefaults = endpoint.FormatDefaults
+			if opts.Metadata == "" {
+				opts.Metadata = project.Metadata
+			}
 			opts.AddHeader(route.ProjectIdHeader, project.ID)
 			return SendStream(ctx, c, opts, buffer)
 		}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"regexp"
 	"testing"
 
@@ -15,9 +19,11 @@ import (
 	"github.com/reflet-devops/go-media-resizer/context"
 	"github.com/reflet-devops/go-media-resizer/http/route"
 	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
+	"github.com/reflet-devops/go-media-resizer/storage"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 type errorReader struct {
@@ -41,6 +47,7 @@ func (e *errorReader) Close() error {
 
 func Test_GetMedia(t *testing.T) {
 	ctx := context.TestContext(nil)
+	ctx.Config.MaxDpr = 2
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
@@ -48,6 +55,17 @@ func Test_GetMedia(t *testing.T) {
 	regexStr := "/wrong/(?<source>.*)"
 	re, errReCompile := regexp.Compile(regexStr)
 	assert.NoError(t, errReCompile)
+	fixture, errRead := os.ReadFile("../../fixtures/orientation-6.jpg")
+	assert.NoError(t, errRead)
+	// the fixture with a comment segment, stripped by the none metadata policy
+	photo := append(append([]byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x08}, "secret"...), fixture[2:]...)
+	mark := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range mark.Pix {
+		mark.Pix[i] = 0xFF
+	}
+	markBuffer := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(markBuffer, mark))
+	watermark := &config.WatermarkConfig{Source: "watermark.png", Position: "top-left"}
 	tests := []struct {
 		name     string
 		resource string
@@ -108,6 +126,416 @@ func Test_GetMedia(t *testing.T) {
 				assert.Equal(t, "hello world", rec.Body.String())
 			},
 		},
+		{
+			name:     "successWithArtDirection",
+			resource: "path/resource.txt",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeText},
+				ArtDirection:    true,
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitCover},
+						CompiledRegex:     regexp.MustCompile("(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				b := io.NopCloser(bytes.NewBufferString("hello world"))
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/resource.txt")).Times(1).Return(b, nil)
+				sidecar := io.NopCloser(bytes.NewBufferString(`{"focal_point": {"x": 0.2, "y": 0.8}}`))
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/resource.txt.json")).Times(1).Return(sidecar, nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Header().Get(route.CacheTagHeader), "source_path_hash_61ae17d201ab255b")
+				assert.Contains(t, rec.Header().Get(route.CacheTagHeader), types.GetTagSourcePathHash(types.FormatProjectPathHash("project-id", "path/resource.txt.json")))
+				assert.Equal(t, "hello world", rec.Body.String())
+			},
+		},
+		{
+			name:     "successWithArtDirectionInvalidSidecar",
+			resource: "path/resource.txt",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeText},
+				ArtDirection:    true,
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitCover},
+						CompiledRegex:     regexp.MustCompile("(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				b := io.NopCloser(bytes.NewBufferString("hello world"))
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/resource.txt")).Times(1).Return(b, nil)
+				sidecar := io.NopCloser(bytes.NewBufferString("{"))
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/resource.txt.json")).Times(1).Return(sidecar, nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Header().Get(route.CacheTagHeader), types.GetTagSourcePathHash(types.FormatProjectPathHash("project-id", "path/resource.txt.json")))
+				assert.Equal(t, "hello world", rec.Body.String())
+			},
+		},
+		{
+			name:     "successWithProjectMetadata",
+			resource: "path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Metadata:        types.TypeMetadataNone,
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     nil,
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(photo)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.NotContains(t, rec.Body.String(), "secret")
+			},
+		},
+		{
+			name:     "successWithEndpointMetadataOverride",
+			resource: "path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Metadata:        types.TypeMetadataNone,
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{Format: types.TypeFormatAuto, Metadata: types.TypeMetadataKeep},
+						CompiledRegex:     regexp.MustCompile("(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(photo)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, photo, rec.Body.Bytes())
+			},
+		},
+		{
+			name:     "successWithWatermark",
+			resource: "path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{Format: types.TypeFormatAuto},
+						CompiledRegex:     regexp.MustCompile("(?<source>.*)"),
+						Watermark:         watermark,
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+				mockStorage.EXPECT().GetFile(gomock.Eq("watermark.png")).Times(1).Return(io.NopCloser(bytes.NewReader(markBuffer.Bytes())), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Header().Get(route.CacheTagHeader), types.GetTagSourcePathHash(types.FormatProjectPathHash("project-id", "watermark.png")))
+				assert.NotEqual(t, fixture, rec.Body.Bytes())
+				img, _, errDecode := image.Decode(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				// the white watermark covers the top left corner
+				gray := color.GrayModel.Convert(img.At(1, 1)).(color.Gray)
+				assert.Greater(t, gray.Y, uint8(240))
+			},
+		},
+		{
+			name:     "successWithWatermarkDisabled",
+			resource: "false/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<watermark>true|false)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<watermark>true|false)/(?<source>.*)"),
+						Watermark:         watermark,
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.NotContains(t, rec.Header().Get(route.CacheTagHeader), types.GetTagSourcePathHash(types.FormatProjectPathHash("project-id", "watermark.png")))
+				assert.Equal(t, fixture, rec.Body.Bytes())
+			},
+		},
+		{
+			name:     "fail_GetWatermark",
+			resource: "path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<source>.*)"),
+						Watermark:         watermark,
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+				mockStorage.EXPECT().GetFile(gomock.Eq("watermark.png")).Times(1).Return(nil, errors.New("not found"))
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+				assert.Equal(t, "failed to load watermark", rec.Body.String())
+			},
+		},
+		{
+			name:     "successWithText",
+			resource: "SOLD/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Text:            &config.TextConfig{Enabled: true},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<text>[A-Z]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{TextColor: "000"},
+						CompiledRegex:     regexp.MustCompile("(?<text>[A-Z]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.NotEqual(t, fixture, rec.Body.Bytes())
+			},
+		},
+		{
+			name:     "successWithTextFontSource",
+			resource: "SOLD/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Text:            &config.TextConfig{Enabled: true, Fonts: []string{"brand"}},
+				FontSources:     map[string]string{"brand": "fonts/brand.ttf"},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<text>[A-Z]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<text>[A-Z]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+				mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(1).Return(io.NopCloser(bytes.NewReader(goregular.TTF)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Contains(t, rec.Header().Get(route.CacheTagHeader), types.GetTagSourcePathHash(types.FormatProjectPathHash("project-id", "fonts/brand.ttf")))
+				assert.NotEqual(t, fixture, rec.Body.Bytes())
+			},
+		},
+		{
+			name:     "successWithEncodedText",
+			resource: "ON%20SALE/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				// "ON SALE" once decoded, "ON%20SALE" would exceed the length
+				Text: &config.TextConfig{Enabled: true, MaxLength: 7},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<text>[A-Z0-9%]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<text>[A-Z0-9%]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.NotEqual(t, fixture, rec.Body.Bytes())
+			},
+		},
+		{
+			name:     "fail_TextNotEnabled",
+			resource: "SOLD/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<text>[A-Z]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<text>[A-Z]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, "text overlay is not enabled", rec.Body.String())
+			},
+		},
+		{
+			name:     "fail_GetFont",
+			resource: "SOLD/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Text:            &config.TextConfig{Enabled: true, Fonts: []string{"brand"}},
+				FontSources:     map[string]string{"brand": "fonts/brand.ttf"},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<text>[A-Z]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<text>[A-Z]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+				mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(1).Return(nil, errors.New("not found"))
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+				assert.Equal(t, "failed to load font", rec.Body.String())
+			},
+		},
+		{
+			name:     "successWithCrop",
+			resource: "crop-0,0,0.5,0.5/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "crop-(?<crop>[0-9.,]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("crop-(?<crop>[0-9.,]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				source, _, errSource := image.DecodeConfig(bytes.NewReader(fixture))
+				assert.NoError(t, errSource)
+				got, _, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, source.Width/2, got.Width)
+				assert.Equal(t, source.Height/2, got.Height)
+			},
+		},
+		{
+			name:     "fail_CropSyntax",
+			resource: "crop-0,0,10/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "crop-(?<crop>[0-9.,]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("crop-(?<crop>[0-9.,]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, "invalid crop: 0,0,10", rec.Body.String())
+			},
+		},
+		{
+			name:     "fail_RotateNotQuarterTurn",
+			resource: "rotate-45/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "rotate-(?<rotate>[0-9]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("rotate-(?<rotate>[0-9]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, "invalid rotate: 45", rec.Body.String())
+			},
+		},
+		{
+			name:     "fail_CropOutsideSource",
+			resource: "crop-0,0,5000,10/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "crop-(?<crop>[0-9.,]+)/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("crop-(?<crop>[0-9.,]+)/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), "crop 0,0,5000,10 outside the")
+			},
+		},
+		{
+			name:     "successWithDprCappedByConfig",
+			resource: "10@5x/path/photo.jpg",
+			prjConf: &config.Project{
+				ID:              "project-id",
+				AcceptTypeFiles: []string{types.TypeJPEG},
+				Endpoints: []config.Endpoint{
+					{
+						Regex:             "(?<width>[0-9]+)(@(?<dpr>[0-9.]+)x)?/(?<source>.*)",
+						DefaultResizeOpts: types.ResizeOption{},
+						CompiledRegex:     regexp.MustCompile("(?<width>[0-9]+)(@(?<dpr>[0-9.]+)x)?/(?<source>.*)"),
+					},
+				},
+			},
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("path/photo.jpg")).Times(1).Return(io.NopCloser(bytes.NewReader(fixture)), nil)
+			},
+			wantFn: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				got, _, errDecode := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+				assert.NoError(t, errDecode)
+				assert.Equal(t, 20, got.Width)
+			},
+		},
 		{
 			name:     "success_EndpointNotMatch",
 			resource: "resource.txt",
@@ -224,9 +652,38 @@ func Test_GetMedia(t *testing.T) {
 			c := e.NewContext(req, rec)
 			c.SetPath(fmt.Sprintf("/%s", tt.resource))
 
-			err := GetMedia(ctx, tt.prjConf, mockStorage)(c)
+			err := GetMedia(ctx, tt.prjConf, mockStorage, storage.NewAssetCache(mockStorage, true))(c)
 			assert.NoError(t, err)
 			tt.wantFn(t, rec)
 		})
 	}
 }
+
+func Test_GetMedia_InvalidEncodedText(t *testing.T) {
+	ctx := context.TestContext(nil)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	project := &config.Project{
+		ID:              "project-id",
+		AcceptTypeFiles: []string{types.TypeJPEG},
+		Text:            &config.TextConfig{Enabled: true},
+		Endpoints: []config.Endpoint{
+			{
+				Regex:         "(?<text>[A-Z0-9%]+)/(?<source>.*)",
+				CompiledRegex: regexp.MustCompile("(?<text>[A-Z0-9%]+)/(?<source>.*)"),
+			},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/SALE/path/photo.jpg", nil)
+	// an escape that can't be decoded, which the request parsing would refuse
+	req.RequestURI = "/SALE%2/path/photo.jpg"
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err := GetMedia(ctx, project, mockStorage, storage.NewAssetCache(mockStorage, true))(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "invalid text", rec.Body.String())
+}
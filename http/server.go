@@ -17,6 +17,7 @@ import (
 	"github.com/reflet-devops/go-media-resizer/http/route"
 	"github.com/reflet-devops/go-media-resizer/http/urltools"
 	"github.com/reflet-devops/go-media-resizer/storage"
+	"github.com/reflet-devops/go-media-resizer/transform"
 	"github.com/reflet-devops/go-media-resizer/types"
 )
 
@@ -30,6 +31,9 @@ func CreateServerHTTP(ctx *context.Context) (*echo.Echo, error) {
 
 	if ctx.Config.HTTP.Metrics.Enable {
 		middleware.ConfigurePrometheusMiddleware(ctx, e)
+		if errRegister := transform.RegisterMetrics(ctx.MetricsRegistry); errRegister != nil {
+			return nil, fmt.Errorf("can't register transform metrics: %w", errRegister)
+		}
 	}
 
 	extractorTrustOptions, err := getExtractorTrustOptions(ctx)
@@ -129,12 +133,14 @@ func initRouter(ctx *context.Context, cfg *config.Config) (map[string]*Host, err
 		if err != nil {
 			return hosts, fmt.Errorf("project=%s, failed to create storage instance: %v", project.ID, err)
 		}
-		host.Echo.GET(fmt.Sprintf("%s/*", project.PrefixPath), controller.GetMedia(ctx, &project, storageInstance))
+		assets := storage.NewAssetCache(storageInstance, len(project.PurgeCaches) > 0)
+		host.Echo.GET(fmt.Sprintf("%s/*", project.PrefixPath), controller.GetMedia(ctx, &project, storageInstance, assets))
 
 		if len(project.PurgeCaches) > 0 {
 			chanEvents := make(chan types.Events, 2024)
 			host.Echo.POST(fmt.Sprintf("%s/webhook", project.PrefixPath), controller.GetWebhook(ctx, chanEvents, &project))
-			purgeCaches := []types.PurgeCache{}
+			// the cached watermarks and fonts are dropped by the same events as the cached responses
+			purgeCaches := []types.PurgeCache{assets}
 			for _, purgeCacheCfg := range project.PurgeCaches {
 				purgeCache, errCreatePurge := cache_purge.CreatePurgeCache(ctx, &project, purgeCacheCfg)
 				if errCreatePurge != nil {
@@ -226,6 +226,7 @@ func Test_CreateServerHTTP_MetricsEnableNoAuth_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.NotEmpty(t, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "face_detection_duration_seconds")
 }
 
 func Test_initRouter_WithPrefix_Success(t *testing.T) {
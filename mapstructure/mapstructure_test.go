@@ -10,6 +10,7 @@ type dummy struct {
 	Name     string        `mapstructure:"name"`
 	Count    int           `mapstructure:"count"`
 	Duration time.Duration `mapstructure:"duration"`
+	Enabled  *bool         `mapstructure:"enabled"`
 }
 
 func TestDecode(t *testing.T) {
@@ -27,6 +28,13 @@ func TestDecode(t *testing.T) {
 			want:    &dummy{Name: "foo", Count: 10, Duration: 5 * time.Second},
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "successWithBool",
+			input:   map[string]interface{}{"enabled": "false"},
+			output:  &dummy{},
+			want:    &dummy{Enabled: new(bool)},
+			wantErr: assert.NoError,
+		},
 		{
 			name:    "failedCreateDecoder",
 			input:   map[string]interface{}{"name": "foo", "count": "10", "duration": "5s"},
@@ -67,6 +67,87 @@ func Test_ParseOption(t *testing.T) {
 			found:      true,
 			wantErr:    assert.NoError,
 		},
+		{
+			name:       "successWithRegexAndGravityOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<gravity>auto|center)(?<source>.*)", DefaultResizeOpts: types.ResizeOption{Fit: types.TypeFitCover}},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/auto/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Fit: types.TypeFitCover, Gravity: types.TypeGravityAuto, Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndFocalPointGravityOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<gravity>[0-9.]+x[0-9.]+)(?<source>.*)", DefaultResizeOpts: types.ResizeOption{Fit: types.TypeFitCover}},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/0.3x0.7/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Fit: types.TypeFitCover, Gravity: "0.3x0.7", Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndLosslessOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<lossless>true|false)(-(?<near_lossless>[0-9]{1,2}))?(-(?<compression>fast|best))?(?<source>\\/.*)", DefaultResizeOpts: types.ResizeOption{Format: types.TypeWEBP}},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/true-60-best/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeWEBP, Lossless: true, NearLossless: 60, Compression: types.TypeCompressionBest, Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndWatermarkOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<watermark>true|false)(?<source>\\/.*)"},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/false/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Watermark: new(bool), Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndTextOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<text>[A-Za-z ]{1,20})-(?<text_size>[0-9]{1,3})-(?<text_color>[0-9a-f]{6})(?<source>\\/.*)", DefaultResizeOpts: types.ResizeOption{TextPosition: types.TypeGravityTop}},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/Sold Out-48-ff0000/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Text: "Sold Out", TextSize: 48, TextColor: "ff0000", TextPosition: types.TypeGravityTop, Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndCropOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/crop-(?<crop>[0-9.]+,[0-9.]+,[0-9.]+,[0-9.]+)(?<source>\\/.*)"},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/crop-10,20,0.5,0.5/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Crop: "10,20,0.5,0.5", Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndDprSuffix",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<width>[0-9]{1,4})(@(?<dpr>[0-9.]+)x)?(?<source>\\/.*)"},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/300@2x/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Width: 300, Dpr: 2, Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndTrimOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/trim-(?<trim>auto|[0-9;]+)(-(?<trim_tolerance>[0-9]{1,3}))?(?<source>\\/.*)"},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypePNG}},
+			path:       "/trim-auto-30/media/image.png",
+			want:       &types.ResizeOption{OriginFormat: types.TypePNG, Trim: types.TypeTrimAuto, TrimTolerance: 30, Source: "media/image.png"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "successWithRegexAndChromaOpts",
+			endpoint:   &config.Endpoint{Regex: "\\/(?<chroma>420|444)(?<source>\\/.*)", DefaultResizeOpts: types.ResizeOption{Progressive: true}},
+			projectCfg: &config.Project{AcceptTypeFiles: []string{types.TypeJPEG}},
+			path:       "/444/media/image.jpg",
+			want:       &types.ResizeOption{OriginFormat: types.TypeJPEG, Progressive: true, Chroma: types.TypeChromaSubsampling444, Source: "media/image.jpg"},
+			found:      true,
+			wantErr:    assert.NoError,
+		},
 		{
 			name:       "failedWithFileTypeNotAccepted",
 			endpoint:   &config.Endpoint{},
@@ -112,3 +193,30 @@ func Test_ParseOption(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseOption_KeepsEndpointDefaults(t *testing.T) {
+	enabled := true
+	regex := "(\\/anim-(?<anim>true|false))?(\\/watermark-(?<watermark>true|false))?(?<source>\\/.*)"
+	endpoint := &config.Endpoint{
+		Regex:             regex,
+		CompiledRegex:     regexp.MustCompile(regex),
+		DefaultResizeOpts: types.ResizeOption{Anim: &enabled, Watermark: &enabled},
+	}
+	projectCfg := &config.Project{AcceptTypeFiles: []string{types.TypeGIF}}
+
+	first := &types.ResizeOption{}
+	found, err := ParseOption(endpoint, projectCfg, "/anim-false/watermark-false/media/image.gif", first)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.False(t, *first.Anim)
+	assert.False(t, *first.Watermark)
+
+	second := &types.ResizeOption{}
+	found, err = ParseOption(endpoint, projectCfg, "/media/image.gif", second)
+	assert.True(t, found)
+	assert.NoError(t, err)
+	assert.True(t, *second.Anim)
+	assert.True(t, *second.Watermark)
+	assert.True(t, *endpoint.DefaultResizeOpts.Anim)
+	assert.True(t, *endpoint.DefaultResizeOpts.Watermark)
+}
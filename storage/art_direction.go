@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// GetArtDirection reads the optional sidecar stored next to source through the same storage backend.
+// A missing sidecar is not an error: nil is returned and the image is processed without hints. Other
+// storage failures are returned, so they are logged instead of looking like a missing sidecar.
+func GetArtDirection(storage types.Storage, source string) (*types.ArtDirection, error) {
+	sidecarPath := types.GetSidecarPath(source)
+	file, errGetFile := storage.GetFile(sidecarPath)
+	if errors.Is(errGetFile, os.ErrNotExist) {
+		return nil, nil
+	}
+	if errGetFile != nil {
+		return nil, fmt.Errorf("failed to get sidecar %s: %w", sidecarPath, errGetFile)
+	}
+	defer func() { _ = file.Close() }()
+
+	artDirection := &types.ArtDirection{}
+	if errDecode := json.NewDecoder(file).Decode(artDirection); errDecode != nil {
+		return nil, fmt.Errorf("failed to decode sidecar %s: %w", sidecarPath, errDecode)
+	}
+	return artDirection, nil
+}
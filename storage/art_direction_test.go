@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetArtDirection(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockFn      func(mockStorage *mockTypes.MockStorage)
+		want        *types.ArtDirection
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "Success",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				body := `{"focal_point": {"x": 0.3, "y": 0.7}, "safe_area": {"x": 10, "y": 20, "width": 100, "height": 50}, "crops": {"16:9": {"x": 0, "y": 0, "width": 160, "height": 90}}}`
+				mockStorage.EXPECT().GetFile(gomock.Eq("media/image.png.json")).Times(1).Return(io.NopCloser(bytes.NewBufferString(body)), nil)
+			},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 0.3, Y: 0.7},
+				SafeArea:   &types.Rect{X: 10, Y: 20, Width: 100, Height: 50},
+				Crops:      map[string]types.Rect{"16:9": {Width: 160, Height: 90}},
+			},
+		},
+		{
+			name: "SuccessWithoutSidecar",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("media/image.png.json")).Times(1).Return(nil, &os.PathError{Op: "open", Path: "media/image.png.json", Err: os.ErrNotExist})
+			},
+			want: nil,
+		},
+		{
+			name: "FailGetFile",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("media/image.png.json")).Times(1).Return(nil, errors.New("connection refused"))
+			},
+			wantErr:     true,
+			errContains: "failed to get sidecar media/image.png.json: connection refused",
+		},
+		{
+			name: "FailDecode",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("media/image.png.json")).Times(1).Return(io.NopCloser(bytes.NewBufferString("{")), nil)
+			},
+			wantErr:     true,
+			errContains: "failed to decode sidecar media/image.png.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStorage := mockTypes.NewMockStorage(ctrl)
+			tt.mockFn(mockStorage)
+
+			got, err := GetArtDirection(mockStorage, "media/image.png")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
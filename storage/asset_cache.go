@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"image"
+	"strings"
+	"sync"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+	"golang.org/x/image/font/opentype"
+)
+
+var _ types.PurgeCache = &AssetCache{}
+
+// AssetCache keeps the files a project reads on every request, watermarks and fonts, once decoded. It receives the
+// purge events of the project storage, which drop the files that changed.
+type AssetCache struct {
+	storage types.Storage
+	// purged tells whether the purge events of the storage reach the cache, nothing is kept without them
+	purged bool
+	mx     sync.RWMutex
+	assets map[string]any
+}
+
+// NewAssetCache creates the asset cache of a storage. Without purge events, a replaced file would be served until the
+// next restart, so the files are read from the storage on every call instead.
+func NewAssetCache(storage types.Storage, purged bool) *AssetCache {
+	return &AssetCache{storage: storage, purged: purged, assets: map[string]any{}}
+}
+
+// GetWatermark returns the watermark stored at source, decoded by GetWatermark on the first call when the cache is
+// purged, on every call otherwise.
+func (a *AssetCache) GetWatermark(source string) (image.Image, error) {
+	return getAsset(a, source, GetWatermark)
+}
+
+// GetFont returns the font stored at source, parsed by GetFont on the first call when the cache is purged, on every
+// call otherwise.
+func (a *AssetCache) GetFont(source string) (*opentype.Font, error) {
+	return getAsset(a, source, GetFont)
+}
+
+func (a *AssetCache) Purge(events types.Events) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	for _, event := range events {
+		delete(a.assets, strings.Trim(event.Path, "/"))
+	}
+}
+
+func getAsset[T any](a *AssetCache, source string, load func(types.Storage, string) (T, error)) (T, error) {
+	if !a.purged {
+		return load(a.storage, source)
+	}
+	key := strings.Trim(source, "/")
+	a.mx.RLock()
+	asset, found := a.assets[key].(T)
+	a.mx.RUnlock()
+	if found {
+		return asset, nil
+	}
+
+	asset, errLoad := load(a.storage, source)
+	if errLoad != nil {
+		return asset, errLoad
+	}
+	a.mx.Lock()
+	a.assets[key] = asset
+	a.mx.Unlock()
+	return asset, nil
+}
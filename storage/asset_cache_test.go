@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestAssetCache_GetFont(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	assets := NewAssetCache(mockStorage, true)
+
+	// parsed once until purged
+	mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(2).DoAndReturn(func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(goregular.TTF)), nil
+	})
+	first, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	second, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	assets.Purge(types.Events{{Type: types.EventTypePurge, Path: "/fonts/other.ttf"}})
+	third, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	assert.Same(t, first, third)
+
+	assets.Purge(types.Events{{Type: types.EventTypePurge, Path: "/fonts/brand.ttf"}})
+	fourth, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, fourth)
+}
+
+func TestAssetCache_GetWatermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	assets := NewAssetCache(mockStorage, true)
+	data := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(data, image.NewNRGBA(image.Rect(0, 0, 4, 4))))
+
+	// decoded once until purged
+	mockStorage.EXPECT().GetFile(gomock.Eq("brand/logo.png")).Times(2).DoAndReturn(func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data.Bytes())), nil
+	})
+	first, err := assets.GetWatermark("brand/logo.png")
+	assert.NoError(t, err)
+	second, err := assets.GetWatermark("brand/logo.png")
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	assets.Purge(types.Events{{Type: types.EventTypePurge, Path: "brand/logo.png"}})
+	third, err := assets.GetWatermark("brand/logo.png")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, third)
+}
+
+func TestAssetCache_GetWatermark_WithoutPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	assets := NewAssetCache(mockStorage, false)
+	data := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(data, image.NewNRGBA(image.Rect(0, 0, 4, 4))))
+
+	// decoded on every call, a replaced watermark couldn't be purged
+	mockStorage.EXPECT().GetFile(gomock.Eq("brand/logo.png")).Times(2).DoAndReturn(func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data.Bytes())), nil
+	})
+	first, err := assets.GetWatermark("brand/logo.png")
+	assert.NoError(t, err)
+	second, err := assets.GetWatermark("brand/logo.png")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second)
+}
+
+func TestAssetCache_GetFont_WithoutPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	assets := NewAssetCache(mockStorage, false)
+
+	// parsed on every call, a replaced font couldn't be purged
+	mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(2).DoAndReturn(func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(goregular.TTF)), nil
+	})
+	first, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	second, err := assets.GetFont("fonts/brand.ttf")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second)
+}
+
+func TestAssetCache_GetFont_Fail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStorage := mockTypes.NewMockStorage(ctrl)
+	assets := NewAssetCache(mockStorage, true)
+
+	// failures aren't cached
+	mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(2).Return(nil, errors.New("file not found"))
+	_, err := assets.GetFont("fonts/brand.ttf")
+	assert.ErrorContains(t, err, "failed to get font fonts/brand.ttf")
+	_, err = assets.GetFont("fonts/brand.ttf")
+	assert.ErrorContains(t, err, "failed to get font fonts/brand.ttf")
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+	"golang.org/x/image/font/opentype"
+)
+
+// GetFont reads and parses the TrueType or OpenType font stored at source through the project storage.
+func GetFont(storage types.Storage, source string) (*opentype.Font, error) {
+	file, errGetFile := storage.GetFile(source)
+	if errGetFile != nil {
+		return nil, fmt.Errorf("failed to get font %s: %w", source, errGetFile)
+	}
+	defer func() { _ = file.Close() }()
+
+	data, errRead := io.ReadAll(file)
+	if errRead != nil {
+		return nil, fmt.Errorf("failed to read font %s: %w", source, errRead)
+	}
+	font, errParse := opentype.Parse(data)
+	if errParse != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", source, errParse)
+	}
+	return font, nil
+}
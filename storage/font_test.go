@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestGetFont(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockFn      func(mockStorage *mockTypes.MockStorage)
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "Success",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(1).Return(io.NopCloser(bytes.NewReader(goregular.TTF)), nil)
+			},
+		},
+		{
+			name: "FailGetFile",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(1).Return(nil, errors.New("file not found"))
+			},
+			wantErr:     true,
+			errContains: "failed to get font fonts/brand.ttf",
+		},
+		{
+			name: "FailParse",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("fonts/brand.ttf")).Times(1).Return(io.NopCloser(bytes.NewBufferString("font")), nil)
+			},
+			wantErr:     true,
+			errContains: "failed to parse font fonts/brand.ttf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStorage := mockTypes.NewMockStorage(ctrl)
+			tt.mockFn(mockStorage)
+
+			got, err := GetFont(mockStorage, "fonts/brand.ttf")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Greater(t, got.NumGlyphs(), 0)
+		})
+	}
+}
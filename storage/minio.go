@@ -94,6 +94,10 @@ func (m *minio) GetFile(path string) (io.ReadCloser, error) {
 	stat, errStat := object.Stat()
 	if errStat != nil {
 		_ = object.Close()
+		// reported like the other storages, so callers can tell a missing file from a failure
+		if libMinio.ToErrorResponse(errStat).Code == "NoSuchKey" {
+			return nil, os.ErrNotExist
+		}
 		return nil, errStat
 	}
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"sync"
 	"sync/atomic"
@@ -383,6 +384,18 @@ func Test_minio_GetFile(t *testing.T) {
 			},
 			wantErr: assert.Error,
 		},
+		{
+			name: "FailGetObjectStatNoSuchKey",
+			path: "foo/bar.txt",
+			cfg:  ConfigMinio{PrefixPath: "/app", ConfigClientMinio: ConfigClientMinio{BucketName: "bucket"}},
+			mockFn: func(minioMock *mockTypes.MockMinioClient) {
+				object := getMinioObject(libMinio.ObjectInfo{}, libMinio.ErrorResponse{Code: "NoSuchKey", Message: "The specified key does not exist."})
+				minioMock.EXPECT().GetObject(gomock.Any(), gomock.Eq("bucket"), gomock.Eq("/app/foo/bar.txt"), gomock.Any()).Times(1).Return(object, nil)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(t, err, os.ErrNotExist, i...)
+			},
+		},
 		{
 			name: "FailObjectSizeEq0",
 			path: "foo/bar.txt",
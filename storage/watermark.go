@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// GetWatermark reads and decodes the watermark image stored at source through the project storage.
+func GetWatermark(storage types.Storage, source string) (image.Image, error) {
+	file, errGetFile := storage.GetFile(source)
+	if errGetFile != nil {
+		return nil, fmt.Errorf("failed to get watermark %s: %w", source, errGetFile)
+	}
+	defer func() { _ = file.Close() }()
+
+	img, _, errDecode := image.Decode(file)
+	if errDecode != nil {
+		return nil, fmt.Errorf("failed to decode watermark %s: %w", source, errDecode)
+	}
+	return img, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	mockTypes "github.com/reflet-devops/go-media-resizer/mocks/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetWatermark(t *testing.T) {
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	logo.SetNRGBA(1, 1, color.NRGBA{R: 255, A: 128})
+	encoded := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(encoded, logo))
+
+	tests := []struct {
+		name        string
+		mockFn      func(mockStorage *mockTypes.MockStorage)
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "Success",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("brand/logo.png")).Times(1).Return(io.NopCloser(bytes.NewReader(encoded.Bytes())), nil)
+			},
+		},
+		{
+			name: "FailGetFile",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("brand/logo.png")).Times(1).Return(nil, errors.New("file not found"))
+			},
+			wantErr:     true,
+			errContains: "failed to get watermark brand/logo.png",
+		},
+		{
+			name: "FailDecode",
+			mockFn: func(mockStorage *mockTypes.MockStorage) {
+				mockStorage.EXPECT().GetFile(gomock.Eq("brand/logo.png")).Times(1).Return(io.NopCloser(bytes.NewBufferString("logo")), nil)
+			},
+			wantErr:     true,
+			errContains: "failed to decode watermark brand/logo.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStorage := mockTypes.NewMockStorage(ctrl)
+			tt.mockFn(mockStorage)
+
+			got, err := GetWatermark(mockStorage, "brand/logo.png")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 4, 2), got.Bounds())
+			assert.Equal(t, color.NRGBA{R: 255, A: 128}, color.NRGBAModel.Convert(got.At(1, 1)))
+		})
+	}
+}
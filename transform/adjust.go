@@ -4,6 +4,9 @@ import (
 	"github.com/disintegration/imaging"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"image"
+	"image/color"
+	"math"
+	"strings"
 )
 
 type AdjustFn func(img image.Image, opts *types.ResizeOption) image.Image
@@ -16,6 +19,12 @@ var (
 		Contrast,
 		Sharpen,
 		Gamma,
+		Grayscale,
+		Sepia,
+		Hue,
+		Tint,
+		Duotone,
+		Invert,
 	}
 )
 
@@ -66,3 +75,141 @@ func Gamma(img image.Image, opts *types.ResizeOption) image.Image {
 	dst := imaging.AdjustGamma(img, opts.Gamma)
 	return dst
 }
+
+func Grayscale(img image.Image, opts *types.ResizeOption) image.Image {
+	if !opts.Grayscale {
+		return img
+	}
+	dst := imaging.Grayscale(img)
+	return dst
+}
+
+// Sepia mixes the image with its sepia version, opts.Sepia being the amount between 0 and 1.
+func Sepia(img image.Image, opts *types.ResizeOption) image.Image {
+	if opts.Sepia <= 0 {
+		return img
+	}
+	amount := math.Min(opts.Sepia, 1)
+	dst := imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return mixColor(c, color.NRGBA{
+			R: clampChannel(0.393*r + 0.769*g + 0.189*b),
+			G: clampChannel(0.349*r + 0.686*g + 0.168*b),
+			B: clampChannel(0.272*r + 0.534*g + 0.131*b),
+			A: c.A,
+		}, amount)
+	})
+	return dst
+}
+
+// Hue rotates the hue of the image by opts.Hue degrees, with the matrix of the CSS hue-rotate filter.
+func Hue(img image.Image, opts *types.ResizeOption) image.Image {
+	if math.Mod(opts.Hue, 360) == 0 {
+		return img
+	}
+	cos, sin := math.Cos(opts.Hue*math.Pi/180), math.Sin(opts.Hue*math.Pi/180)
+	matrix := [3][3]float64{
+		{0.213 + cos*0.787 - sin*0.213, 0.715 - cos*0.715 - sin*0.715, 0.072 - cos*0.072 + sin*0.928},
+		{0.213 - cos*0.213 + sin*0.143, 0.715 + cos*0.285 + sin*0.140, 0.072 - cos*0.072 - sin*0.283},
+		{0.213 - cos*0.213 - sin*0.787, 0.715 - cos*0.715 + sin*0.715, 0.072 + cos*0.928 + sin*0.072},
+	}
+	dst := imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return color.NRGBA{
+			R: clampChannel(matrix[0][0]*r + matrix[0][1]*g + matrix[0][2]*b),
+			G: clampChannel(matrix[1][0]*r + matrix[1][1]*g + matrix[1][2]*b),
+			B: clampChannel(matrix[2][0]*r + matrix[2][1]*g + matrix[2][2]*b),
+			A: c.A,
+		}
+	})
+	return dst
+}
+
+// Tint colors the luminance of the image with opts.Tint, the alpha of the color being the
+// strength of the tint. Invalid colors are ignored.
+func Tint(img image.Image, opts *types.ResizeOption) image.Image {
+	tint, errColor := ParseColor(opts.Tint)
+	if opts.Tint == "" || errColor != nil {
+		return img
+	}
+	amount := float64(tint.A) / 255
+	dst := imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		lum := luminance(c) / 255
+		return mixColor(c, color.NRGBA{
+			R: clampChannel(lum * float64(tint.R)),
+			G: clampChannel(lum * float64(tint.G)),
+			B: clampChannel(lum * float64(tint.B)),
+			A: c.A,
+		}, amount)
+	})
+	return dst
+}
+
+// Duotone maps the luminance of the image onto the gradient between the two colors of opts.Duotone,
+// the shadows and the highlights. Invalid values are ignored.
+func Duotone(img image.Image, opts *types.ResizeOption) image.Image {
+	if opts.Duotone == "" {
+		return img
+	}
+	colors := splitColors(opts.Duotone)
+	if len(colors) != 2 {
+		return img
+	}
+	shadow, errShadow := ParseColor(colors[0])
+	highlight, errHighlight := ParseColor(colors[1])
+	if errShadow != nil || errHighlight != nil {
+		return img
+	}
+	dst := imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		tone := mixColor(shadow, highlight, luminance(c)/255)
+		// the colors of the duotone are opaque, the image keeps its own transparency
+		tone.A = uint8(float64(tone.A) * float64(c.A) / 255)
+		return tone
+	})
+	return dst
+}
+
+func Invert(img image.Image, opts *types.ResizeOption) image.Image {
+	if !opts.Invert {
+		return img
+	}
+	dst := imaging.Invert(img)
+	return dst
+}
+
+// luminance returns the Rec. 601 luma of c, as imaging.Grayscale does.
+func luminance(c color.NRGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+// mixColor returns the color at amount (0 to 1) between from and to.
+func mixColor(from, to color.NRGBA, amount float64) color.NRGBA {
+	mix := func(a, b uint8) uint8 {
+		return clampChannel(float64(a) + (float64(b)-float64(a))*amount)
+	}
+	return color.NRGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: mix(from.A, to.A)}
+}
+
+func clampChannel(value float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, value))))
+}
+
+// splitColors splits a comma separated list of colors, ignoring the commas of rgb() and rgba().
+func splitColors(value string) []string {
+	var colors []string
+	depth, start := 0, 0
+	for i, char := range value {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				colors = append(colors, strings.TrimSpace(value[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(colors, strings.TrimSpace(value[start:]))
+}
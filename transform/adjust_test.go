@@ -1,9 +1,13 @@
 package transform
 
 import (
+	"bytes"
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/hash"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/stretchr/testify/assert"
 	"image"
+	"image/color"
 	"os"
 	"testing"
 )
@@ -140,3 +144,95 @@ func TestGamma(t *testing.T) {
 		})
 	}
 }
+
+// gradient returns a 16x16 image going from black to red horizontally and to green vertically.
+func gradient() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 17), G: uint8(y * 17), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestAdjust_ColorFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want string
+	}{
+		{name: "grayscale", opts: &types.ResizeOption{Grayscale: true}, want: "2dddfdb4330b41ff0e84b39bc61c3ca20dda560e646e04d3ad56fb25b35c874e"},
+		{name: "sepia", opts: &types.ResizeOption{Sepia: 1}, want: "2b8b9df4a025720c692a61f7f989d7f2b3b6844e0e58a11bedd9f41921893b92"},
+		{name: "sepiaHalf", opts: &types.ResizeOption{Sepia: 0.5}, want: "ede01c54664b2b8b8560d924056e77397ed9d964a2fa747ef6da041b1564dd09"},
+		{name: "hue", opts: &types.ResizeOption{Hue: 90}, want: "ccc4bb5a4ea71fdaa3d3c358a2a4b97851750622c15df2e9f772f6f35354abd5"},
+		{name: "tint", opts: &types.ResizeOption{Tint: "ff8000"}, want: "a1e9b0d8d525e0540adcc857642fd42c54171cb596cb9e38e017d005122bb21e"},
+		{name: "tintTranslucent", opts: &types.ResizeOption{Tint: "rgba(255,128,0,0.5)"}, want: "8946ca9bbbe444867cdf9b64d57cd959c2a5664e4e16940d7f67860a12725d80"},
+		{name: "duotone", opts: &types.ResizeOption{Duotone: "000080,ffd700"}, want: "9ccba5de92a5b26961effe2e868d40cb4a739ce6d3ceefae9d280bdadfdf7fdd"},
+		{name: "invert", opts: &types.ResizeOption{Invert: true}, want: "2fb3b81c52a5e1e837f5690a5653346bb3031d993706a8157b6a5a0b2befb015"},
+		{name: "grayscaleAndInvert", opts: &types.ResizeOption{Grayscale: true, Invert: true}, want: "d200ea82f473e53ade3a5dbaf7ac100670bb0bb81cbcbfa2dc00aa020ea8b011"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Adjust(gradient(), tt.opts)
+			w := &bytes.Buffer{}
+			assert.NoError(t, imaging.Encode(w, got, imaging.PNG))
+			shaSum, errSha := hash.GenerateSHA256(w)
+			assert.NoError(t, errSha)
+			assert.Equal(t, tt.want, shaSum)
+		})
+	}
+}
+
+func TestAdjust_ColorFiltersIgnored(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+	}{
+		{name: "nothing", opts: &types.ResizeOption{}},
+		{name: "fullTurnHue", opts: &types.ResizeOption{Hue: 360}},
+		{name: "invalidTint", opts: &types.ResizeOption{Tint: "orange"}},
+		{name: "duotoneWithOneColor", opts: &types.ResizeOption{Duotone: "000080"}},
+		{name: "invalidDuotone", opts: &types.ResizeOption{Duotone: "000080,gold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := gradient()
+			assert.Same(t, image.Image(source), Adjust(source, tt.opts))
+		})
+	}
+}
+
+func TestColorFilters(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+
+	tests := []struct {
+		name   string
+		fn     AdjustFn
+		opts   *types.ResizeOption
+		source color.NRGBA
+		want   color.NRGBA
+	}{
+		{name: "grayscale", fn: Grayscale, opts: &types.ResizeOption{Grayscale: true}, source: red, want: color.NRGBA{R: 76, G: 76, B: 76, A: 255}},
+		{name: "sepia", fn: Sepia, opts: &types.ResizeOption{Sepia: 1}, source: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, want: color.NRGBA{R: 255, G: 255, B: 239, A: 255}},
+		{name: "sepiaAboveOne", fn: Sepia, opts: &types.ResizeOption{Sepia: 3}, source: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, want: color.NRGBA{R: 255, G: 255, B: 239, A: 255}},
+		{name: "hueHalfTurn", fn: Hue, opts: &types.ResizeOption{Hue: 180}, source: color.NRGBA{R: 128, G: 128, B: 128, A: 200}, want: color.NRGBA{R: 128, G: 128, B: 128, A: 200}},
+		{name: "tint", fn: Tint, opts: &types.ResizeOption{Tint: "0000ff"}, source: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, want: color.NRGBA{B: 255, A: 255}},
+		{name: "tintHalf", fn: Tint, opts: &types.ResizeOption{Tint: "rgba(0,0,255,0.5)"}, source: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, want: color.NRGBA{R: 127, G: 127, B: 255, A: 255}},
+		{name: "duotoneShadow", fn: Duotone, opts: &types.ResizeOption{Duotone: "000080,ffd700"}, source: color.NRGBA{A: 255}, want: color.NRGBA{B: 128, A: 255}},
+		{name: "duotoneHighlight", fn: Duotone, opts: &types.ResizeOption{Duotone: "000080, rgb(255,215,0)"}, source: color.NRGBA{R: 255, G: 255, B: 255, A: 128}, want: color.NRGBA{R: 255, G: 215, A: 128}},
+		{name: "invert", fn: Invert, opts: &types.ResizeOption{Invert: true}, source: red, want: color.NRGBA{G: 255, B: 255, A: 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imaging.Clone(tt.fn(imaging.New(1, 1, tt.source), tt.opts))
+			assertColorInDelta(t, tt.want, got.NRGBAAt(0, 0), 1)
+		})
+	}
+}
+
+func Test_splitColors(t *testing.T) {
+	assert.Equal(t, []string{"000080", "ffd700"}, splitColors("000080,ffd700"))
+	assert.Equal(t, []string{"rgba(0,0,128,1)", "#fff"}, splitColors("rgba(0,0,128,1), #fff"))
+	assert.Equal(t, []string{"000080"}, splitColors("000080"))
+}
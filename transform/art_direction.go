@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"image"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// artDirectionTolerance is the relative aspect ratio difference accepted when matching a sidecar crop box.
+const artDirectionTolerance = 0.01
+
+// hasArtDirectionFocus reports whether the sidecar hints should position the crop window.
+// An explicit gravity in the request always wins over the sidecar.
+func hasArtDirectionFocus(opts *types.ResizeOption) bool {
+	return opts.Gravity == "" && opts.ArtDirection != nil && (opts.ArtDirection.FocalPoint != nil || opts.ArtDirection.SafeArea != nil)
+}
+
+// artDirectionCrop crops img to the sidecar crop box matching the requested aspect ratio, if any.
+func artDirectionCrop(img image.Image, opts *types.ResizeOption) (image.Image, bool) {
+	if opts.Gravity != "" || opts.ArtDirection == nil || len(opts.ArtDirection.Crops) == 0 || opts.Width == 0 || opts.Height == 0 {
+		return img, false
+	}
+
+	target := float64(opts.Width) / float64(opts.Height)
+	keys := make([]string, 0, len(opts.ArtDirection.Crops))
+	for key := range opts.ArtDirection.Crops {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	bestKey, bestDiff := "", artDirectionTolerance
+	for _, key := range keys {
+		ratio, ok := parseAspectRatio(key)
+		if !ok {
+			continue
+		}
+		if diff := math.Abs(ratio-target) / target; diff <= bestDiff {
+			if bestKey == "" || diff < bestDiff {
+				bestKey, bestDiff = key, diff
+			}
+		}
+	}
+	if bestKey == "" {
+		return img, false
+	}
+
+	bounds := img.Bounds()
+	rect := opts.ArtDirection.Crops[bestKey].Rectangle().Add(bounds.Min).Intersect(bounds)
+	if rect.Empty() {
+		return img, false
+	}
+	return imaging.Crop(img, rect), true
+}
+
+// parseAspectRatio parses a crop box key such as "16:9".
+func parseAspectRatio(key string) (float64, bool) {
+	wStr, hStr, found := strings.Cut(key, ":")
+	if !found {
+		return 0, false
+	}
+	w, errW := strconv.ParseFloat(strings.TrimSpace(wStr), 64)
+	h, errH := strconv.ParseFloat(strings.TrimSpace(hStr), 64)
+	if errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, false
+	}
+	return w / h, true
+}
+
+// artDirectionOffset centers the window on the sidecar focal point, or on the safe area when no
+// focal point is set, then slides it so it keeps as much of the safe area as possible.
+func artDirectionOffset(img image.Image, cropW, cropH int, artDirection *types.ArtDirection) (int, int) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	safe := image.Rectangle{}
+	if artDirection.SafeArea != nil {
+		safe = artDirection.SafeArea.Rectangle().Intersect(image.Rect(0, 0, srcW, srcH))
+	}
+
+	fx, fy := 0.5, 0.5
+	if artDirection.FocalPoint != nil {
+		fx, fy = math.Min(1, math.Max(0, artDirection.FocalPoint.X)), math.Min(1, math.Max(0, artDirection.FocalPoint.Y))
+	} else if !safe.Empty() {
+		fx = float64(safe.Min.X+safe.Max.X) / 2 / float64(srcW)
+		fy = float64(safe.Min.Y+safe.Max.Y) / 2 / float64(srcH)
+	}
+
+	x, y := focusOffset(srcW, cropW, fx), focusOffset(srcH, cropH, fy)
+	if !safe.Empty() {
+		x = keepInWindow(x, cropW, safe.Min.X, safe.Max.X)
+		y = keepInWindow(y, cropH, safe.Min.Y, safe.Max.Y)
+	}
+	return x, y
+}
+
+// keepInWindow moves a window of size win starting at offset so it covers [start, end), or stays
+// inside it when the range is larger than the window.
+func keepInWindow(offset, win, start, end int) int {
+	low, high := min(start, end-win), max(start, end-win)
+	return min(high, max(low, offset))
+}
+
+// shiftArtDirection returns the hints of artDirection for the srcSize image cropped to size at
+// offset: the pixel boxes are moved by the offset, the focal point is recomputed on the new size.
+func shiftArtDirection(artDirection *types.ArtDirection, offset, srcSize, size image.Point) *types.ArtDirection {
+	if artDirection == nil {
+		return nil
+	}
+	shift := func(rect types.Rect) types.Rect {
+		return types.Rect{X: rect.X - offset.X, Y: rect.Y - offset.Y, Width: rect.Width, Height: rect.Height}
+	}
+	shifted := &types.ArtDirection{}
+	if artDirection.FocalPoint != nil {
+		shifted.FocalPoint = &types.FocalPoint{
+			X: (artDirection.FocalPoint.X*float64(srcSize.X) - float64(offset.X)) / float64(size.X),
+			Y: (artDirection.FocalPoint.Y*float64(srcSize.Y) - float64(offset.Y)) / float64(size.Y),
+		}
+	}
+	if artDirection.SafeArea != nil {
+		safeArea := shift(*artDirection.SafeArea)
+		shifted.SafeArea = &safeArea
+	}
+	if artDirection.Crops != nil {
+		shifted.Crops = make(map[string]types.Rect, len(artDirection.Crops))
+		for key, crop := range artDirection.Crops {
+			shifted.Crops[key] = shift(crop)
+		}
+	}
+	return shifted
+}
+
+// orientArtDirection returns the hints of artDirection for the size image turned by the rotate and
+// flip of opts: the boxes and the focal point follow the content, and the crop boxes turned a
+// quarter swap their aspect ratio key.
+func orientArtDirection(artDirection *types.ArtDirection, size image.Point, opts *types.ResizeOption) *types.ArtDirection {
+	if artDirection == nil || (opts.Rotate == 0 && opts.Flip == "") {
+		return artDirection
+	}
+	turns := opts.Rotate / 90
+	flipH := opts.Flip == types.TypeFlipHorizontal || opts.Flip == types.TypeFlipBoth
+	flipV := opts.Flip == types.TypeFlipVertical || opts.Flip == types.TypeFlipBoth
+
+	turn := func(rect types.Rect) types.Rect {
+		w, h := size.X, size.Y
+		for range turns {
+			rect = types.Rect{X: h - rect.Y - rect.Height, Y: rect.X, Width: rect.Height, Height: rect.Width}
+			w, h = h, w
+		}
+		if flipH {
+			rect.X = w - rect.X - rect.Width
+		}
+		if flipV {
+			rect.Y = h - rect.Y - rect.Height
+		}
+		return rect
+	}
+	turned := &types.ArtDirection{}
+	if artDirection.FocalPoint != nil {
+		x, y := artDirection.FocalPoint.X, artDirection.FocalPoint.Y
+		for range turns {
+			x, y = 1-y, x
+		}
+		if flipH {
+			x = 1 - x
+		}
+		if flipV {
+			y = 1 - y
+		}
+		turned.FocalPoint = &types.FocalPoint{X: x, Y: y}
+	}
+	if artDirection.SafeArea != nil {
+		safeArea := turn(*artDirection.SafeArea)
+		turned.SafeArea = &safeArea
+	}
+	if artDirection.Crops != nil {
+		turned.Crops = make(map[string]types.Rect, len(artDirection.Crops))
+		for key, crop := range artDirection.Crops {
+			if wStr, hStr, found := strings.Cut(key, ":"); found && turns%2 == 1 {
+				key = strings.TrimSpace(hStr) + ":" + strings.TrimSpace(wStr)
+			}
+			turned.Crops[key] = turn(crop)
+		}
+	}
+	return turned
+}
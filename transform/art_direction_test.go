@@ -0,0 +1,251 @@
+package transform
+
+import (
+	"image"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_artDirectionCrop(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+	crops := map[string]types.Rect{
+		"1:1":  {X: 250, Y: 0, Width: 150, Height: 150},
+		"16:9": {X: 0, Y: 0, Width: 320, Height: 180},
+	}
+
+	tests := []struct {
+		name     string
+		opts     *types.ResizeOption
+		wantRect image.Rectangle
+		wantOk   bool
+	}{
+		{
+			name:     "matchingRatio",
+			opts:     &types.ResizeOption{Width: 100, Height: 100, ArtDirection: &types.ArtDirection{Crops: crops}},
+			wantRect: image.Rect(250, 0, 400, 150),
+			wantOk:   true,
+		},
+		{
+			name:     "matchingRatioWithinTolerance",
+			opts:     &types.ResizeOption{Width: 1600, Height: 901, ArtDirection: &types.ArtDirection{Crops: crops}},
+			wantRect: image.Rect(0, 0, 320, 180),
+			wantOk:   true,
+		},
+		{
+			name:   "noMatchingRatio",
+			opts:   &types.ResizeOption{Width: 100, Height: 300, ArtDirection: &types.ArtDirection{Crops: crops}},
+			wantOk: false,
+		},
+		{
+			name:   "explicitGravityWins",
+			opts:   &types.ResizeOption{Width: 100, Height: 100, Gravity: types.TypeGravityLeft, ArtDirection: &types.ArtDirection{Crops: crops}},
+			wantOk: false,
+		},
+		{
+			name:   "missingDimension",
+			opts:   &types.ResizeOption{Width: 100, ArtDirection: &types.ArtDirection{Crops: crops}},
+			wantOk: false,
+		},
+		{
+			name:   "boxOutsideImage",
+			opts:   &types.ResizeOption{Width: 100, Height: 100, ArtDirection: &types.ArtDirection{Crops: map[string]types.Rect{"1:1": {X: 500, Y: 500, Width: 10, Height: 10}}}},
+			wantOk: false,
+		},
+		{
+			name:   "noArtDirection",
+			opts:   &types.ResizeOption{Width: 100, Height: 100},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := artDirectionCrop(img, tt.opts)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, imaging.Crop(img, tt.wantRect), got)
+			} else {
+				assert.Equal(t, img, got)
+			}
+		})
+	}
+}
+
+func Test_parseAspectRatio(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   float64
+		wantOk bool
+	}{
+		{key: "16:9", want: 16.0 / 9.0, wantOk: true},
+		{key: "1:1", want: 1, wantOk: true},
+		{key: " 4 : 3 ", want: 4.0 / 3.0, wantOk: true},
+		{key: "16x9", wantOk: false},
+		{key: "0:1", wantOk: false},
+		{key: "a:b", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := parseAspectRatio(tt.key)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cropWindow_ArtDirection(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want image.Rectangle
+	}{
+		{
+			name: "focalPoint",
+			opts: &types.ResizeOption{ArtDirection: &types.ArtDirection{FocalPoint: &types.FocalPoint{X: 0.75, Y: 0.5}}},
+			want: image.Rect(200, 0, 400, 200),
+		},
+		{
+			name: "safeArea",
+			opts: &types.ResizeOption{ArtDirection: &types.ArtDirection{SafeArea: &types.Rect{X: 20, Y: 0, Width: 100, Height: 200}}},
+			want: image.Rect(0, 0, 200, 200),
+		},
+		{
+			name: "focalPointKeepsSafeArea",
+			opts: &types.ResizeOption{ArtDirection: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 0.9, Y: 0.5},
+				SafeArea:   &types.Rect{X: 150, Y: 0, Width: 100, Height: 200},
+			}},
+			want: image.Rect(150, 0, 350, 200),
+		},
+		{
+			name: "explicitGravityWins",
+			opts: &types.ResizeOption{Gravity: types.TypeGravityLeft, ArtDirection: &types.ArtDirection{FocalPoint: &types.FocalPoint{X: 1, Y: 1}}},
+			want: image.Rect(0, 0, 200, 200),
+		},
+		{
+			name: "onlyCropsStaysCentered",
+			opts: &types.ResizeOption{ArtDirection: &types.ArtDirection{Crops: map[string]types.Rect{"1:1": {Width: 10, Height: 10}}}},
+			want: image.Rect(100, 0, 300, 200),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cropWindow(img, 200, 200, tt.opts))
+		})
+	}
+}
+
+func Test_keepInWindow(t *testing.T) {
+	tests := []struct {
+		name                    string
+		offset, win, start, end int
+		want                    int
+	}{
+		{name: "alreadyCovered", offset: 50, win: 100, start: 60, end: 120, want: 50},
+		{name: "slideLeft", offset: 100, win: 100, start: 60, end: 120, want: 60},
+		{name: "slideRight", offset: 0, win: 100, start: 60, end: 120, want: 20},
+		{name: "rangeLargerThanWindow", offset: 0, win: 50, start: 60, end: 200, want: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, keepInWindow(tt.offset, tt.win, tt.start, tt.end))
+		})
+	}
+}
+
+func TestResize_ArtDirectionCropBox(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+	opts := &types.ResizeOption{Width: 50, Height: 50, Fit: types.TypeFitCover, ArtDirection: &types.ArtDirection{
+		FocalPoint: &types.FocalPoint{X: 0, Y: 0},
+		Crops:      map[string]types.Rect{"1:1": {X: 250, Y: 0, Width: 150, Height: 150}},
+	}}
+	got := Resize(img, opts)
+	want := imaging.Resize(imaging.Crop(img, image.Rect(250, 0, 400, 150)), 50, 50, imaging.Lanczos)
+	assert.Equal(t, want, got)
+}
+
+func Test_orientArtDirection(t *testing.T) {
+	// 100x50 source, hints on the bottom-left corner
+	artDirection := &types.ArtDirection{
+		FocalPoint: &types.FocalPoint{X: 0.1, Y: 0.8},
+		SafeArea:   &types.Rect{X: 0, Y: 30, Width: 20, Height: 20},
+		Crops:      map[string]types.Rect{"2:1": {X: 0, Y: 20, Width: 60, Height: 30}},
+	}
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want *types.ArtDirection
+	}{
+		{name: "nothing", opts: &types.ResizeOption{}, want: artDirection},
+		{
+			name: "rotate90",
+			opts: &types.ResizeOption{Rotate: 90},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 1 - 0.8, Y: 0.1},
+				SafeArea:   &types.Rect{X: 0, Y: 0, Width: 20, Height: 20},
+				Crops:      map[string]types.Rect{"1:2": {X: 0, Y: 0, Width: 30, Height: 60}},
+			},
+		},
+		{
+			name: "rotate180",
+			opts: &types.ResizeOption{Rotate: 180},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 1 - 0.1, Y: 1 - 0.8},
+				SafeArea:   &types.Rect{X: 80, Y: 0, Width: 20, Height: 20},
+				Crops:      map[string]types.Rect{"2:1": {X: 40, Y: 0, Width: 60, Height: 30}},
+			},
+		},
+		{
+			name: "rotate270",
+			opts: &types.ResizeOption{Rotate: 270},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 0.8, Y: 1 - 0.1},
+				SafeArea:   &types.Rect{X: 30, Y: 80, Width: 20, Height: 20},
+				Crops:      map[string]types.Rect{"1:2": {X: 20, Y: 40, Width: 30, Height: 60}},
+			},
+		},
+		{
+			name: "flipH",
+			opts: &types.ResizeOption{Flip: types.TypeFlipHorizontal},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 1 - 0.1, Y: 0.8},
+				SafeArea:   &types.Rect{X: 80, Y: 30, Width: 20, Height: 20},
+				Crops:      map[string]types.Rect{"2:1": {X: 40, Y: 20, Width: 60, Height: 30}},
+			},
+		},
+		{
+			name: "rotate90FlipV",
+			opts: &types.ResizeOption{Rotate: 90, Flip: types.TypeFlipVertical},
+			want: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 1 - 0.8, Y: 1 - 0.1},
+				SafeArea:   &types.Rect{X: 0, Y: 80, Width: 20, Height: 20},
+				Crops:      map[string]types.Rect{"1:2": {X: 0, Y: 40, Width: 30, Height: 60}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orientArtDirection(artDirection, image.Pt(100, 50), tt.opts)
+			assert.InDelta(t, tt.want.FocalPoint.X, got.FocalPoint.X, 1e-9)
+			assert.InDelta(t, tt.want.FocalPoint.Y, got.FocalPoint.Y, 1e-9)
+			assert.Equal(t, tt.want.SafeArea, got.SafeArea)
+			assert.Equal(t, tt.want.Crops, got.Crops)
+		})
+	}
+	assert.Nil(t, orientArtDirection(nil, image.Pt(100, 50), &types.ResizeOption{Rotate: 90}))
+}
+
+func TestProcess_ArtDirectionRotate90(t *testing.T) {
+	// detail on the bottom-left corner of the source, which a clockwise quarter turn moves to the top-left
+	img := detailedImage(100, 50, image.Rect(0, 30, 20, 50))
+	opts := &types.ResizeOption{Width: 25, Height: 25, Fit: types.TypeFitCover, Rotate: 90, ArtDirection: &types.ArtDirection{
+		FocalPoint: &types.FocalPoint{X: 0.1, Y: 0.8},
+	}}
+	got := process(img, opts)
+	want := imaging.Resize(imaging.Crop(imaging.Rotate270(img), image.Rect(0, 0, 50, 50)), 25, 25, imaging.Lanczos)
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,48 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// DefaultFlattenColor is the background of transparent images encoded to an opaque format, when the
+// request has no background.
+var DefaultFlattenColor = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+// newBackground returns a width x height canvas for img: a blurred copy of img covering the canvas
+// with the blur background, or a plain color, fallback when the background is empty or invalid.
+func newBackground(img image.Image, width, height int, background string, fallback color.Color) *image.NRGBA {
+	if background == types.TypeBackgroundBlur {
+		// the sigma grows with the canvas, so the result looks the same at any output size
+		sigma := math.Max(1, float64(max(width, height))/40)
+		return imaging.Blur(imaging.Fill(img, width, height, imaging.Center, imaging.Linear), sigma)
+	}
+	if parsed, errColor := ParseColor(background); errColor == nil {
+		fallback = parsed
+	}
+	return imaging.New(width, height, fallback)
+}
+
+// Flatten composites img onto its background, so transparent pixels don't turn black in opaque
+// output formats. Opaque images are returned unchanged.
+func Flatten(img image.Image, opts *types.ResizeOption) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	background := newBackground(img, bounds.Dx(), bounds.Dy(), opts.Background, DefaultFlattenColor)
+	// a translucent color or the blurred copy of img aren't opaque either, so they get a plain backdrop
+	if !background.Opaque() {
+		background = imaging.Overlay(imaging.New(bounds.Dx(), bounds.Dy(), DefaultFlattenColor), background, image.Pt(0, 0), 1)
+	}
+	return imaging.Overlay(background, img, image.Pt(0, 0), 1)
+}
+
+// needFlatten reports whether the output format can't carry transparency.
+func needFlatten(opts *types.ResizeOption) bool {
+	return opts.Format == types.TypeJPEG || encodedFormat(opts) == types.TypeJPEG
+}
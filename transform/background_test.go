@@ -0,0 +1,163 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// halfTransparent returns a 40x20 image with an opaque red left half and a transparent right half.
+func halfTransparent() *image.NRGBA {
+	img := imaging.New(40, 20, color.Transparent)
+	return imaging.Paste(img, imaging.New(20, 20, color.NRGBA{R: 255, A: 255}), image.Pt(0, 0))
+}
+
+func Test_newBackground(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+
+	tests := []struct {
+		name       string
+		background string
+		want       color.NRGBA
+	}{
+		{name: "fallback", background: "", want: color.NRGBA{}},
+		{name: "invalidFallback", background: "purple", want: color.NRGBA{}},
+		{name: "hex", background: "00ff00", want: color.NRGBA{G: 255, A: 255}},
+		{name: "rgba", background: "rgba(0,0,255,0.5)", want: color.NRGBA{B: 255, A: 128}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newBackground(imaging.New(4, 4, red), 30, 10, tt.background, color.Transparent)
+			assert.Equal(t, image.Rect(0, 0, 30, 10), got.Bounds())
+			assert.Equal(t, tt.want, got.NRGBAAt(0, 0))
+			assert.Equal(t, tt.want, got.NRGBAAt(29, 9))
+		})
+	}
+
+	t.Run("blur", func(t *testing.T) {
+		source := imaging.New(10, 10, red)
+		source = imaging.Paste(source, imaging.New(5, 10, color.NRGBA{B: 255, A: 255}), image.Pt(5, 0))
+		got := newBackground(source, 40, 20, types.TypeBackgroundBlur, color.Transparent)
+		assert.Equal(t, image.Rect(0, 0, 40, 20), got.Bounds())
+		// a scaled copy of the source: red on the left, blue on the right
+		left, right := got.NRGBAAt(0, 10), got.NRGBAAt(39, 10)
+		assert.Greater(t, left.R, left.B)
+		assert.Greater(t, right.B, right.R)
+	})
+}
+
+func TestFlatten(t *testing.T) {
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.NRGBA{R: 255, A: 255}
+
+	tests := []struct {
+		name       string
+		background string
+		want       color.NRGBA
+	}{
+		{name: "defaultWhite", want: white},
+		{name: "color", background: "#000080", want: color.NRGBA{B: 128, A: 255}},
+		{name: "translucentColor", background: "rgba(0,0,0,0.5)", want: color.NRGBA{R: 127, G: 127, B: 127, A: 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Flatten(halfTransparent(), &types.ResizeOption{Background: tt.background}).(*image.NRGBA)
+			assert.Equal(t, red, got.NRGBAAt(5, 10))
+			assertColorInDelta(t, tt.want, got.NRGBAAt(35, 10), 1)
+			assert.True(t, got.Opaque())
+		})
+	}
+
+	t.Run("blur", func(t *testing.T) {
+		got := Flatten(halfTransparent(), &types.ResizeOption{Background: types.TypeBackgroundBlur}).(*image.NRGBA)
+		assert.Equal(t, red, got.NRGBAAt(5, 10))
+		assert.True(t, got.Opaque())
+		// the red half bleeds into the transparent one, over white
+		next := got.NRGBAAt(21, 10)
+		assert.Equal(t, uint8(255), next.R)
+		assert.Less(t, next.G, uint8(255))
+	})
+
+	t.Run("opaque", func(t *testing.T) {
+		source := imaging.New(4, 4, red)
+		assert.Same(t, image.Image(source), Flatten(source, &types.ResizeOption{}))
+	})
+}
+
+func TestTransform_Background(t *testing.T) {
+	tests := []struct {
+		name   string
+		source image.Image
+		opts   *types.ResizeOption
+		// wantPad is the color of the padded area, wantTransparent the color of the transparent source pixels
+		wantPad         color.NRGBA
+		wantTransparent color.NRGBA
+	}{
+		{
+			name:    "jpegPadDefault",
+			source:  imaging.New(40, 20, color.NRGBA{R: 255, A: 255}),
+			opts:    &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 40, Height: 40, Fit: types.TypeFitPad},
+			wantPad: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+		{
+			name:    "jpegPadColor",
+			source:  imaging.New(40, 20, color.NRGBA{R: 255, A: 255}),
+			opts:    &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 40, Height: 40, Fit: types.TypeFitPad, Background: "0000ff"},
+			wantPad: color.NRGBA{B: 255, A: 255},
+		},
+		{
+			name:            "pngPadDefault",
+			source:          halfTransparent(),
+			opts:            &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Width: 40, Height: 40, Fit: types.TypeFitPad},
+			wantPad:         color.NRGBA{},
+			wantTransparent: color.NRGBA{},
+		},
+		{
+			name:            "pngPadColor",
+			source:          halfTransparent(),
+			opts:            &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Width: 40, Height: 40, Fit: types.TypeFitPad, Background: "00ff00"},
+			wantPad:         color.NRGBA{G: 255, A: 255},
+			wantTransparent: color.NRGBA{G: 255, A: 255},
+		},
+		{
+			name:            "pngToJPEGFlattened",
+			source:          halfTransparent(),
+			opts:            &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeJPEG, Width: 40, Height: 40, Fit: types.TypeFitPad},
+			wantPad:         color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+			wantTransparent: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewBuffer(encodeTestImage(t, tt.source, tt.opts.OriginFormat))
+			assert.NoError(t, Transform(file, tt.opts))
+			img, errDecode := imaging.Decode(file)
+			assert.NoError(t, errDecode)
+			assert.Equal(t, image.Rect(0, 0, 40, 40), img.Bounds())
+
+			tolerance := 0.0
+			if tt.opts.OriginFormat == types.TypeJPEG {
+				tolerance = 8
+			}
+			assertColorInDelta(t, tt.wantPad, img.At(20, 2), tolerance)
+			assertColorInDelta(t, color.NRGBA{R: 255, A: 255}, img.At(5, 20), tolerance)
+			if tt.opts.OriginFormat == types.TypePNG {
+				assertColorInDelta(t, tt.wantTransparent, img.At(35, 20), tolerance)
+			}
+		})
+	}
+}
+
+func assertColorInDelta(t *testing.T, want color.NRGBA, got color.Color, delta float64) {
+	t.Helper()
+	gotNRGBA := color.NRGBAModel.Convert(got).(color.NRGBA)
+	assert.InDelta(t, want.R, gotNRGBA.R, delta, "red of %v", gotNRGBA)
+	assert.InDelta(t, want.G, gotNRGBA.G, delta, "green of %v", gotNRGBA)
+	assert.InDelta(t, want.B, gotNRGBA.B, delta, "blue of %v", gotNRGBA)
+	assert.InDelta(t, want.A, gotNRGBA.A, delta, "alpha of %v", gotNRGBA)
+}
@@ -0,0 +1,69 @@
+package transform
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseColor reads a hexadecimal RGB or RGBA color, in the short (fff) or long (ffffff) form with
+// or without a leading #, or a functional rgb(255,0,0) or rgba(255,0,0,0.5) color.
+func ParseColor(value string) (color.NRGBA, error) {
+	if args, ok := strings.CutPrefix(value, "rgba("); ok {
+		return parseColorFunction(value, args, 4)
+	}
+	if args, ok := strings.CutPrefix(value, "rgb("); ok {
+		return parseColorFunction(value, args, 3)
+	}
+
+	hexValue := strings.TrimPrefix(value, "#")
+	if len(hexValue) == 3 || len(hexValue) == 4 {
+		var long strings.Builder
+		for _, c := range hexValue {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		hexValue = long.String()
+	}
+	if len(hexValue) == 6 {
+		hexValue += "ff"
+	}
+	rgba, errDecode := hex.DecodeString(hexValue)
+	if errDecode != nil || len(rgba) != 4 {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", value)
+	}
+	return color.NRGBA{R: rgba[0], G: rgba[1], B: rgba[2], A: rgba[3]}, nil
+}
+
+// parseColorFunction reads the channels of an rgb() or rgba() color: red, green and blue from 0 to
+// 255, then the alpha from 0 to 1.
+func parseColorFunction(value, args string, count int) (color.NRGBA, error) {
+	invalid := fmt.Errorf("invalid color: %s", value)
+	args, ok := strings.CutSuffix(args, ")")
+	parts := strings.Split(args, ",")
+	if !ok || len(parts) != count {
+		return color.NRGBA{}, invalid
+	}
+
+	channels := [4]uint8{255, 255, 255, 255}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i == 3 {
+			alpha, errAlpha := strconv.ParseFloat(part, 64)
+			if errAlpha != nil || alpha < 0 || alpha > 1 {
+				return color.NRGBA{}, invalid
+			}
+			channels[i] = uint8(math.Round(alpha * 255))
+			continue
+		}
+		channel, errChannel := strconv.ParseUint(part, 10, 8)
+		if errChannel != nil {
+			return color.NRGBA{}, invalid
+		}
+		channels[i] = uint8(channel)
+	}
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: channels[3]}, nil
+}
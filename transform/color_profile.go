@@ -0,0 +1,276 @@
+package transform
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	iccHeaderSize = 128
+	// srgbEncodeSteps is the resolution of the linear to sRGB lookup table.
+	srgbEncodeSteps = 4096
+	// srgbTolerance is how far a profile can be from sRGB and still be treated as sRGB.
+	srgbTolerance = 0.002
+)
+
+var (
+	errUnsupportedProfile = errors.New("unsupported icc profile")
+
+	// srgbMatrix converts linear sRGB to the D50 XYZ connection space of ICC profiles
+	// (the rXYZ, gXYZ and bXYZ columns of the reference sRGB profile).
+	srgbMatrix = [3][3]float64{
+		{0.4360747, 0.3850649, 0.1430804},
+		{0.2225045, 0.7168786, 0.0606169},
+		{0.0139322, 0.0971045, 0.7141733},
+	}
+	srgbInverse = invert3x3(srgbMatrix)
+
+	srgbLinear [256]float64
+	srgbEncode [srgbEncodeSteps + 1]uint8
+)
+
+func init() {
+	for i := range srgbLinear {
+		srgbLinear[i] = srgbToLinear(float64(i) / 255)
+	}
+	for i := range srgbEncode {
+		srgbEncode[i] = uint8(math.Round(linearToSRGB(float64(i)/srgbEncodeSteps) * 255))
+	}
+}
+
+// iccProfile is the matrix/TRC description of an RGB ICC profile, which covers Display P3,
+// Adobe RGB, ProPhoto and most camera profiles. LUT-based and non-RGB profiles are not supported.
+type iccProfile struct {
+	// matrix converts linear device RGB to D50 XYZ
+	matrix [3][3]float64
+	// linear maps each 8-bit channel value to its linear intensity
+	linear [3][256]float64
+}
+
+// parseICCProfile reads the colorants and tone curves of an RGB ICC profile.
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < iccHeaderSize+4 || string(data[36:40]) != "acsp" {
+		return nil, errors.New("invalid icc profile")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, errUnsupportedProfile
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[iccHeaderSize:]))
+	for i := 0; i < count; i++ {
+		entry := iccHeaderSize + 4 + i*12
+		if entry+12 > len(data) {
+			return nil, errors.New("truncated icc tag table")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, errors.New("truncated icc tag")
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	profile := &iccProfile{}
+	for c, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, ok := tags[name]
+		if !ok || len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+			return nil, errUnsupportedProfile
+		}
+		for row := 0; row < 3; row++ {
+			profile.matrix[row][c] = s15Fixed16(xyz[8+row*4:])
+		}
+	}
+	for c, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, ok := tags[name]
+		if !ok {
+			return nil, errUnsupportedProfile
+		}
+		fn, errCurve := parseICCCurve(curve)
+		if errCurve != nil {
+			return nil, errCurve
+		}
+		for i := range profile.linear[c] {
+			profile.linear[c][i] = fn(float64(i) / 255)
+		}
+	}
+	return profile, nil
+}
+
+// parseICCCurve returns the tone response described by a curv or para tag.
+func parseICCCurve(data []byte) (func(float64) float64, error) {
+	if len(data) < 12 {
+		return nil, errors.New("truncated icc curve")
+	}
+	switch string(data[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(data[8:]))
+		if len(data) < 12+n*2 {
+			return nil, errors.New("truncated icc curve")
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(data[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(data[12+i*2:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := x * float64(n-1)
+			i := min(n-2, int(pos))
+			return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+		}, nil
+	case "para":
+		paramCounts := []int{1, 3, 4, 5, 7}
+		kind := int(binary.BigEndian.Uint16(data[8:]))
+		if kind >= len(paramCounts) || len(data) < 12+paramCounts[kind]*4 {
+			return nil, errUnsupportedProfile
+		}
+		p := make([]float64, 7)
+		for i := 0; i < paramCounts[kind]; i++ {
+			p[i] = s15Fixed16(data[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		pow := func(v float64) float64 { return math.Pow(math.Max(0, v), g) }
+		switch kind {
+		case 0:
+			return pow, nil
+		case 1:
+			return func(x float64) float64 {
+				if a != 0 && x < -b/a {
+					return 0
+				}
+				return pow(a*x + b)
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if a != 0 && x < -b/a {
+					return c
+				}
+				return pow(a*x+b) + c
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x < d {
+					return c * x
+				}
+				return pow(a*x + b)
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x < d {
+					return c*x + f
+				}
+				return pow(a*x+b) + e
+			}, nil
+		}
+	}
+	return nil, errUnsupportedProfile
+}
+
+// isSRGB reports whether the profile describes sRGB closely enough that no conversion is needed.
+func (p *iccProfile) isSRGB() bool {
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			if math.Abs(p.matrix[row][col]-srgbMatrix[row][col]) > srgbTolerance {
+				return false
+			}
+		}
+	}
+	for c := 0; c < 3; c++ {
+		for i, v := range p.linear[c] {
+			if math.Abs(v-srgbLinear[i]) > srgbTolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// keepColorProfile reports whether the source profile is embedded in the output instead of
+// converting the pixels to sRGB. AVIF, JPEG XL and GIF outputs can't carry a profile and are always converted.
+func keepColorProfile(opts *types.ResizeOption) bool {
+	if opts.ColorProfile != types.TypeColorProfileKeep {
+		return false
+	}
+	switch opts.Format {
+	case types.TypeJPEG, types.TypePNG, types.TypeWEBP:
+		return true
+	}
+	return false
+}
+
+// ConvertToSRGB converts the pixels of img from the given ICC profile to sRGB. Images without a
+// supported profile, or already in sRGB, are returned unchanged.
+func ConvertToSRGB(img image.Image, profileData []byte) image.Image {
+	if len(profileData) == 0 {
+		return img
+	}
+	profile, errParse := parseICCProfile(profileData)
+	if errParse != nil || profile.isSRGB() {
+		return img
+	}
+
+	var m [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for k := 0; k < 3; k++ {
+				m[row][col] += srgbInverse[row][k] * profile.matrix[k][col]
+			}
+		}
+	}
+
+	dst := imaging.Clone(img)
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+dst.Rect.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			r, g, b := profile.linear[0][row[i]], profile.linear[1][row[i+1]], profile.linear[2][row[i+2]]
+			row[i] = encodeSRGB(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+			row[i+1] = encodeSRGB(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+			row[i+2] = encodeSRGB(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+		}
+	}
+	return dst
+}
+
+func encodeSRGB(v float64) uint8 {
+	return srgbEncode[int(math.Round(math.Min(1, math.Max(0, v))*srgbEncodeSteps))]
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func s15Fixed16(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536
+}
+
+func invert3x3(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return [3][3]float64{
+		{(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det, (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det, (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det},
+		{(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det, (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det, (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det},
+		{(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det, (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det, (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det},
+	}
+}
@@ -0,0 +1,237 @@
+package transform
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	displayP3Matrix = [3][3]float64{
+		{0.515102, 0.291965, 0.157153},
+		{0.241182, 0.692236, 0.066582},
+		{-0.001049, 0.041885, 0.784378},
+	}
+	// srgbCurve is the sRGB tone curve as a parametric curve of type 3
+	srgbCurve = paraCurve(3, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)
+)
+
+func fixed16(v float64) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+}
+
+func paraCurve(kind int, params ...float64) []byte {
+	curve := []byte("para\x00\x00\x00\x00")
+	curve = binary.BigEndian.AppendUint16(curve, uint16(kind))
+	curve = append(curve, 0, 0)
+	for _, p := range params {
+		curve = append(curve, fixed16(p)...)
+	}
+	return curve
+}
+
+func gammaCurve(gamma float64) []byte {
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01")
+	return binary.BigEndian.AppendUint16(curve, uint16(math.Round(gamma*256)))
+}
+
+// buildICCProfile returns a minimal RGB matrix/TRC ICC profile.
+func buildICCProfile(matrix [3][3]float64, curve []byte) []byte {
+	type tag struct {
+		name string
+		data []byte
+	}
+	var tags []tag
+	for c, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz := []byte("XYZ \x00\x00\x00\x00")
+		for row := 0; row < 3; row++ {
+			xyz = append(xyz, fixed16(matrix[row][c])...)
+		}
+		tags = append(tags, tag{name: name, data: xyz})
+	}
+	for _, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		tags = append(tags, tag{name: name, data: curve})
+	}
+
+	header := make([]byte, iccHeaderSize)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	offset := iccHeaderSize + 4 + len(tags)*12
+	var data []byte
+	for _, t := range tags {
+		table = append(table, t.name...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+		data = append(data, t.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	profile := append(append(header, table...), data...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func Test_parseICCProfile(t *testing.T) {
+	t.Run("successSRGB", func(t *testing.T) {
+		profile, err := parseICCProfile(buildICCProfile(srgbMatrix, srgbCurve))
+		assert.NoError(t, err)
+		assert.True(t, profile.isSRGB())
+	})
+	t.Run("successDisplayP3", func(t *testing.T) {
+		profile, err := parseICCProfile(buildICCProfile(displayP3Matrix, srgbCurve))
+		assert.NoError(t, err)
+		assert.False(t, profile.isSRGB())
+		assert.InDelta(t, 0.515102, profile.matrix[0][0], 0.0001)
+		assert.InDelta(t, 0.041885, profile.matrix[2][1], 0.0001)
+	})
+	t.Run("successGammaNotSRGB", func(t *testing.T) {
+		profile, err := parseICCProfile(buildICCProfile(srgbMatrix, gammaCurve(1.8)))
+		assert.NoError(t, err)
+		assert.False(t, profile.isSRGB())
+	})
+	t.Run("failedInvalid", func(t *testing.T) {
+		_, err := parseICCProfile([]byte("not a profile"))
+		assert.ErrorContains(t, err, "invalid icc profile")
+	})
+	t.Run("failedCMYK", func(t *testing.T) {
+		data := buildICCProfile(srgbMatrix, srgbCurve)
+		copy(data[16:], "CMYK")
+		_, err := parseICCProfile(data)
+		assert.ErrorIs(t, err, errUnsupportedProfile)
+	})
+	t.Run("failedMissingTag", func(t *testing.T) {
+		data := buildICCProfile(srgbMatrix, srgbCurve)
+		copy(data[iccHeaderSize+4:], "A2B0")
+		_, err := parseICCProfile(data)
+		assert.ErrorIs(t, err, errUnsupportedProfile)
+	})
+	t.Run("failedTruncated", func(t *testing.T) {
+		data := buildICCProfile(srgbMatrix, srgbCurve)
+		_, err := parseICCProfile(data[:iccHeaderSize+20])
+		assert.Error(t, err)
+	})
+}
+
+func Test_parseICCCurve(t *testing.T) {
+	table := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x03")
+	for _, v := range []uint16{0, 16384, 65535} {
+		table = binary.BigEndian.AppendUint16(table, v)
+	}
+
+	tests := []struct {
+		name  string
+		curve []byte
+		in    float64
+		want  float64
+	}{
+		{name: "identity", curve: []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00"), in: 0.3, want: 0.3},
+		{name: "gamma", curve: gammaCurve(2), in: 0.5, want: 0.25},
+		{name: "tableInterpolated", curve: table, in: 0.25, want: 0.125},
+		{name: "tableEnd", curve: table, in: 1, want: 1},
+		{name: "paraType0", curve: paraCurve(0, 2), in: 0.5, want: 0.25},
+		{name: "paraType1BelowCut", curve: paraCurve(1, 2, 1, -0.5), in: 0.25, want: 0},
+		{name: "paraType2", curve: paraCurve(2, 1, 1, 0, 0.1), in: 0.5, want: 0.6},
+		{name: "paraSRGBLinearPart", curve: srgbCurve, in: 0.02, want: 0.02 / 12.92},
+		{name: "paraSRGBPowerPart", curve: srgbCurve, in: 0.5, want: srgbToLinear(0.5)},
+		{name: "paraType4", curve: paraCurve(4, 1, 1, 0, 2, 0.5, 0.1, 0.05), in: 0.25, want: 0.55},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := parseICCCurve(tt.curve)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, fn(tt.in), 0.001)
+		})
+	}
+
+	t.Run("failedUnknownType", func(t *testing.T) {
+		_, err := parseICCCurve([]byte("mAB \x00\x00\x00\x00\x00\x00\x00\x00"))
+		assert.ErrorIs(t, err, errUnsupportedProfile)
+	})
+	t.Run("failedTruncated", func(t *testing.T) {
+		_, err := parseICCCurve(table[:14])
+		assert.Error(t, err)
+	})
+}
+
+func TestConvertToSRGB(t *testing.T) {
+	p3ToSRGB := [3][3]float64{
+		{1.2249, -0.2247, 0},
+		{-0.0420, 1.0419, 0},
+		{-0.0197, -0.0786, 1.0979},
+	}
+	expected := func(c color.NRGBA) color.NRGBA {
+		in := [3]float64{srgbToLinear(float64(c.R) / 255), srgbToLinear(float64(c.G) / 255), srgbToLinear(float64(c.B) / 255)}
+		var out [3]uint8
+		for row := 0; row < 3; row++ {
+			v := p3ToSRGB[row][0]*in[0] + p3ToSRGB[row][1]*in[1] + p3ToSRGB[row][2]*in[2]
+			out[row] = uint8(math.Round(linearToSRGB(math.Min(1, math.Max(0, v))) * 255))
+		}
+		return color.NRGBA{R: out[0], G: out[1], B: out[2], A: c.A}
+	}
+	near := func(t *testing.T, want, got color.NRGBA) {
+		assert.InDelta(t, want.R, got.R, 2)
+		assert.InDelta(t, want.G, got.G, 2)
+		assert.InDelta(t, want.B, got.B, 2)
+		assert.Equal(t, want.A, got.A)
+	}
+
+	t.Run("successDisplayP3", func(t *testing.T) {
+		colors := []color.NRGBA{
+			{R: 180, G: 120, B: 100, A: 255},
+			{R: 40, G: 200, B: 90, A: 128},
+			{R: 128, G: 128, B: 128, A: 255},
+			{R: 255, G: 0, B: 0, A: 255},
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, len(colors), 1))
+		for i, c := range colors {
+			img.SetNRGBA(i, 0, c)
+		}
+
+		got := ConvertToSRGB(img, buildICCProfile(displayP3Matrix, srgbCurve)).(*image.NRGBA)
+		for i, c := range colors {
+			near(t, expected(c), got.NRGBAAt(i, 0))
+		}
+		// neutral colors share the white point and stay neutral
+		assert.Equal(t, color.NRGBA{R: 128, G: 128, B: 128, A: 255}, got.NRGBAAt(2, 0))
+	})
+	t.Run("successSRGBUnchanged", func(t *testing.T) {
+		img := imaging.New(2, 2, color.NRGBA{R: 180, G: 120, B: 100, A: 255})
+		assert.Same(t, img, ConvertToSRGB(img, buildICCProfile(srgbMatrix, srgbCurve)))
+	})
+	t.Run("successInvalidProfileUnchanged", func(t *testing.T) {
+		img := imaging.New(2, 2, color.White)
+		assert.Same(t, img, ConvertToSRGB(img, []byte("broken")))
+		assert.Same(t, img, ConvertToSRGB(img, nil))
+	})
+}
+
+func Test_keepColorProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want bool
+	}{
+		{name: "default", opts: &types.ResizeOption{Format: types.TypeJPEG}, want: false},
+		{name: "srgb", opts: &types.ResizeOption{Format: types.TypeJPEG, ColorProfile: types.TypeColorProfileSRGB}, want: false},
+		{name: "keepJpeg", opts: &types.ResizeOption{Format: types.TypeJPEG, ColorProfile: types.TypeColorProfileKeep}, want: true},
+		{name: "keepPng", opts: &types.ResizeOption{Format: types.TypePNG, ColorProfile: types.TypeColorProfileKeep}, want: true},
+		{name: "keepWebp", opts: &types.ResizeOption{Format: types.TypeWEBP, ColorProfile: types.TypeColorProfileKeep}, want: true},
+		{name: "keepAvifConverts", opts: &types.ResizeOption{Format: types.TypeAVIF, ColorProfile: types.TypeColorProfileKeep}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, keepColorProfile(tt.opts))
+		})
+	}
+}
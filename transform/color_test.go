@@ -0,0 +1,44 @@
+package transform
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{name: "long", value: "ff8000", want: color.NRGBA{R: 255, G: 128, A: 255}},
+		{name: "longWithHash", value: "#FF8000", want: color.NRGBA{R: 255, G: 128, A: 255}},
+		{name: "longWithAlpha", value: "ff800080", want: color.NRGBA{R: 255, G: 128, A: 128}},
+		{name: "short", value: "f80", want: color.NRGBA{R: 255, G: 136, A: 255}},
+		{name: "shortWithAlpha", value: "#f808", want: color.NRGBA{R: 255, G: 136, A: 136}},
+		{name: "rgb", value: "rgb(255, 128,0)", want: color.NRGBA{R: 255, G: 128, A: 255}},
+		{name: "rgba", value: "rgba(255,128,0,0.5)", want: color.NRGBA{R: 255, G: 128, A: 128}},
+		{name: "failedEmpty", value: "", wantErr: true},
+		{name: "failedLength", value: "ff800", wantErr: true},
+		{name: "failedNotHex", value: "gg8000", wantErr: true},
+		{name: "failedTooLong", value: "ff8000801", wantErr: true},
+		{name: "failedRGBChannelCount", value: "rgb(255,128)", wantErr: true},
+		{name: "failedRGBChannelRange", value: "rgb(256,128,0)", wantErr: true},
+		{name: "failedRGBAAlphaRange", value: "rgba(255,128,0,2)", wantErr: true},
+		{name: "failedRGBUnclosed", value: "rgb(255,128,0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseColor(tt.value)
+			if tt.wantErr {
+				assert.EqualError(t, err, "invalid color: "+tt.value)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
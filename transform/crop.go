@@ -0,0 +1,108 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// cropValue is one of the x,y,w,h values of a crop, in pixels or in a fraction of the source size
+// when it has a decimal point.
+type cropValue struct {
+	value    float64
+	fraction bool
+}
+
+// ValidateCrop checks the syntax of the crop requested in opts.
+func ValidateCrop(opts *types.ResizeOption) error {
+	if opts.Crop == "" {
+		return nil
+	}
+	_, err := parseCrop(opts.Crop)
+	return err
+}
+
+// ValidateCropBounds checks that the crop requested in opts stays inside the source image, once
+// oriented. Sources whose dimensions can't be read are left to the transformation.
+func ValidateCropBounds(data *bytes.Buffer, opts *types.ResizeOption) error {
+	if opts.Crop == "" {
+		return nil
+	}
+	source, errPage := sourcePage(data.Bytes(), opts)
+	if errPage != nil {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return nil
+	}
+	_, err = cropRect(opts.Crop, orientedSize(cfg.Width, cfg.Height, opts))
+	return err
+}
+
+// Crop keeps the opts.Crop rectangle of img. Crops that are invalid or outside img are ignored. The
+// art direction hints are moved to stay on the same pixels.
+func Crop(img image.Image, opts *types.ResizeOption) image.Image {
+	bounds := img.Bounds()
+	rect, err := cropRect(opts.Crop, bounds.Size())
+	if err != nil || rect.Size() == bounds.Size() {
+		return img
+	}
+	opts.ArtDirection = shiftArtDirection(opts.ArtDirection, rect.Min, bounds.Size(), rect.Size())
+	return imaging.Crop(img, rect.Add(bounds.Min))
+}
+
+// parseCrop reads the x,y,w,h values of a crop. The width and height can't be 0.
+func parseCrop(value string) ([4]cropValue, error) {
+	var values [4]cropValue
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return values, fmt.Errorf("invalid crop: %s", value)
+	}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.Contains(part, ".") {
+			fraction, errFloat := strconv.ParseFloat(part, 64)
+			if errFloat != nil || fraction < 0 || fraction > 1 {
+				return values, fmt.Errorf("invalid crop: %s", value)
+			}
+			values[i] = cropValue{value: fraction, fraction: true}
+		} else {
+			pixels, errInt := strconv.Atoi(part)
+			if errInt != nil || pixels < 0 {
+				return values, fmt.Errorf("invalid crop: %s", value)
+			}
+			values[i] = cropValue{value: float64(pixels)}
+		}
+	}
+	if values[2].value == 0 || values[3].value == 0 {
+		return values, fmt.Errorf("invalid crop: %s", value)
+	}
+	return values, nil
+}
+
+// cropRect returns the crop rectangle of value in an image of size, from the origin.
+func cropRect(value string, size image.Point) (image.Rectangle, error) {
+	values, err := parseCrop(value)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	pixels := func(v cropValue, length int) int {
+		if v.fraction {
+			return int(math.Round(v.value * float64(length)))
+		}
+		return int(v.value)
+	}
+	x, y := pixels(values[0], size.X), pixels(values[1], size.Y)
+	rect := image.Rect(x, y, x+pixels(values[2], size.X), y+pixels(values[3], size.Y))
+	if rect.Empty() || !rect.In(image.Rectangle{Max: size}) {
+		return image.Rectangle{}, fmt.Errorf("crop %s outside the %dx%d image", value, size.X, size.Y)
+	}
+	return rect, nil
+}
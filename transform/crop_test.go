@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCrop(t *testing.T) {
+	tests := []struct {
+		name    string
+		crop    string
+		wantErr string
+	}{
+		{name: "empty", crop: ""},
+		{name: "pixels", crop: "10,20,100,50"},
+		{name: "fractions", crop: "0.1,0.2,0.5,0.5"},
+		{name: "mixedWithSpaces", crop: "0, 0.25, 100, 1.0"},
+		{name: "failedCount", crop: "10,20,100", wantErr: "invalid crop: 10,20,100"},
+		{name: "failedNegative", crop: "-10,20,100,50", wantErr: "invalid crop: -10,20,100,50"},
+		{name: "failedFractionAboveOne", crop: "0,0,1.5,0.5", wantErr: "invalid crop: 0,0,1.5,0.5"},
+		{name: "failedValue", crop: "a,0,10,10", wantErr: "invalid crop: a,0,10,10"},
+		{name: "failedEmptyWidth", crop: "0,0,0,10", wantErr: "invalid crop: 0,0,0,10"},
+		{name: "failedEmptyHeight", crop: "0,0,10,0.0", wantErr: "invalid crop: 0,0,10,0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCrop(&types.ResizeOption{Crop: tt.crop})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_cropRect(t *testing.T) {
+	tests := []struct {
+		name    string
+		crop    string
+		want    image.Rectangle
+		wantErr string
+	}{
+		{name: "pixels", crop: "10,20,100,50", want: image.Rect(10, 20, 110, 70)},
+		{name: "fractions", crop: "0.25,0.5,0.5,0.5", want: image.Rect(50, 50, 150, 100)},
+		{name: "mixed", crop: "0.5,10,100,0.1", want: image.Rect(100, 10, 200, 20)},
+		{name: "wholeImage", crop: "0,0,1.0,1.0", want: image.Rect(0, 0, 200, 100)},
+		{name: "failedOutside", crop: "150,0,100,50", wantErr: "crop 150,0,100,50 outside the 200x100 image"},
+		{name: "failedFractionsOutside", crop: "0.6,0,0.5,0.5", wantErr: "crop 0.6,0,0.5,0.5 outside the 200x100 image"},
+		{name: "failedRoundedEmpty", crop: "0,0,0.001,10", wantErr: "crop 0,0,0.001,10 outside the 200x100 image"},
+		{name: "failedSyntax", crop: "0,0,10", wantErr: "invalid crop: 0,0,10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cropRect(tt.crop, image.Pt(200, 100))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateCropBounds(t *testing.T) {
+	data := encodeTestImage(t, imaging.New(200, 100, color.White), types.TypePNG)
+
+	tests := []struct {
+		name    string
+		opts    types.ResizeOption
+		wantErr string
+	}{
+		{name: "withoutCrop", opts: types.ResizeOption{}},
+		{name: "inside", opts: types.ResizeOption{Crop: "100,0,100,100"}},
+		{name: "failedOutside", opts: types.ResizeOption{Crop: "100,0,150,100"}, wantErr: "crop 100,0,150,100 outside the 200x100 image"},
+		{name: "rotated", opts: types.ResizeOption{Crop: "0,100,100,100", Rotate: 90}},
+		{name: "failedRotated", opts: types.ResizeOption{Crop: "100,0,100,100", Rotate: 270}, wantErr: "crop 100,0,100,100 outside the 100x200 image"},
+		{name: "exifTransposed", opts: types.ResizeOption{Crop: "0,100,100,100", AutoOrient: true, Orientation: 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCropBounds(bytes.NewBuffer(data), &tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("undecodable", func(t *testing.T) {
+		assert.NoError(t, ValidateCropBounds(bytes.NewBufferString("not an image"), &types.ResizeOption{Crop: "0,0,10,10"}))
+	})
+}
+
+func TestCrop(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	source := imaging.Paste(imaging.New(200, 100, color.White), imaging.New(50, 20, red), image.Pt(100, 40))
+
+	got := Crop(source, &types.ResizeOption{Crop: "100,40,50,20"})
+	assert.Equal(t, image.Rect(0, 0, 50, 20), got.Bounds())
+	assert.Equal(t, red, imaging.Clone(got).NRGBAAt(0, 0))
+	assert.Equal(t, red, imaging.Clone(got).NRGBAAt(49, 19))
+
+	t.Run("invalidIgnored", func(t *testing.T) {
+		assert.Same(t, image.Image(source), Crop(source, &types.ResizeOption{Crop: "150,0,100,50"}))
+	})
+
+	t.Run("artDirection", func(t *testing.T) {
+		opts := &types.ResizeOption{
+			Crop: "0.5,0,0.5,1.0",
+			ArtDirection: &types.ArtDirection{
+				FocalPoint: &types.FocalPoint{X: 0.75, Y: 0.5},
+				Crops:      map[string]types.Rect{"1:1": {X: 100, Y: 0, Width: 100, Height: 100}},
+			},
+		}
+		assert.Equal(t, image.Rect(0, 0, 100, 100), Crop(source, opts).Bounds())
+		assert.Equal(t, &types.ArtDirection{
+			FocalPoint: &types.FocalPoint{X: 0.5, Y: 0.5},
+			Crops:      map[string]types.Rect{"1:1": {X: 0, Y: 0, Width: 100, Height: 100}},
+		}, opts.ArtDirection)
+	})
+}
+
+func TestTransform_Crop(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	source := imaging.Paste(imaging.New(200, 100, color.White), imaging.New(50, 50, red), image.Pt(100, 50))
+	file := bytes.NewBuffer(encodeTestImage(t, source, types.TypePNG))
+	opts := &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Crop: "0.5,0.5,0.25,0.5", Width: 20, Height: 20, Fit: types.TypeFitCover}
+
+	assert.NoError(t, Transform(file, opts))
+	img, _, errDecode := image.Decode(file)
+	assert.NoError(t, errDecode)
+	// the fit applies to the cropped red square
+	assert.Equal(t, image.Rect(0, 0, 20, 20), img.Bounds())
+	assertColorInDelta(t, red, img.At(0, 0), 0)
+	assertColorInDelta(t, red, img.At(19, 19), 0)
+}
@@ -0,0 +1,29 @@
+package transform
+
+import (
+	"image"
+	"math"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// applyDpr multiplies the requested width and height by opts.Dpr. The scale-down and crop fits,
+// which don't enlarge the source, get no more pixels than the source has, so the aspect ratio of
+// the request is kept.
+func applyDpr(img image.Image, opts *types.ResizeOption) {
+	dpr := opts.Dpr
+	if opts.Fit == "" || opts.Fit == types.TypeFitScaleDown || opts.Fit == types.TypeFitCrop {
+		bounds := img.Bounds()
+		if opts.Width > 0 {
+			dpr = math.Min(dpr, float64(bounds.Dx())/float64(opts.Width))
+		}
+		if opts.Height > 0 {
+			dpr = math.Min(dpr, float64(bounds.Dy())/float64(opts.Height))
+		}
+	}
+	if dpr <= 1 {
+		return
+	}
+	opts.Width = int(math.Round(float64(opts.Width) * dpr))
+	opts.Height = int(math.Round(float64(opts.Height) * dpr))
+}
@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyDpr(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       types.ResizeOption
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "scaleDown", opts: types.ResizeOption{Width: 100, Dpr: 2}, wantWidth: 200},
+		{name: "fractional", opts: types.ResizeOption{Width: 101, Height: 51, Dpr: 1.5}, wantWidth: 152, wantHeight: 77},
+		{name: "scaleDownCappedBySource", opts: types.ResizeOption{Width: 300, Height: 100, Dpr: 3}, wantWidth: 400, wantHeight: 133},
+		{name: "scaleDownAlreadyLarger", opts: types.ResizeOption{Width: 500, Dpr: 2}, wantWidth: 500},
+		{name: "cropCappedBySource", opts: types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitCrop, Dpr: 4}, wantWidth: 300, wantHeight: 300},
+		{name: "coverEnlarges", opts: types.ResizeOption{Width: 300, Height: 300, Fit: types.TypeFitCover, Dpr: 2}, wantWidth: 600, wantHeight: 600},
+		{name: "padEnlarges", opts: types.ResizeOption{Width: 300, Fit: types.TypeFitPad, Dpr: 3}, wantWidth: 900},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyDpr(imaging.New(400, 300, color.White), &tt.opts)
+			assert.Equal(t, tt.wantWidth, tt.opts.Width)
+			assert.Equal(t, tt.wantHeight, tt.opts.Height)
+		})
+	}
+}
+
+func TestTransform_Dpr(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want image.Rectangle
+	}{
+		{name: "doubled", opts: &types.ResizeOption{Width: 100, Dpr: 2}, want: image.Rect(0, 0, 200, 150)},
+		{name: "ignoredWithoutSize", opts: &types.ResizeOption{Dpr: 2}, want: image.Rect(0, 0, 400, 300)},
+		{name: "ignoredBelowOne", opts: &types.ResizeOption{Width: 100, Dpr: 0.5}, want: image.Rect(0, 0, 100, 75)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.OriginFormat, tt.opts.Format = types.TypePNG, types.TypePNG
+			file := bytes.NewBuffer(encodeTestImage(t, imaging.New(400, 300, color.White), types.TypePNG))
+			assert.NoError(t, Transform(file, tt.opts))
+			cfg, _, errDecode := image.DecodeConfig(file)
+			assert.NoError(t, errDecode)
+			assert.Equal(t, tt.want, image.Rect(0, 0, cfg.Width, cfg.Height))
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package transform
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// faceMaxSide is the longest side of the grayscale copy scanned by the cascade.
+	faceMaxSide = 512
+	// faceMinSize is the smallest face searched, in pixels of the scanned copy.
+	faceMinSize      = 20
+	faceShiftFactor  = 0.1
+	faceScaleFactor  = 1.1
+	faceIoUThreshold = 0.2
+	// faceMinQuality drops weak clusters that are most likely false positives.
+	faceMinQuality = 5.0
+)
+
+var (
+	// facefinder is the pico facefinder cascade, as distributed with pigo (see cascade/README.md)
+	//go:embed cascade/facefinder
+	facefinder []byte
+
+	// faceCascade is the embedded facefinder cascade, replaced by SetFaceCascade from the face_cascade configuration
+	faceCascade = mustUnpackFaceCascade(facefinder)
+
+	FaceDetectionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "face_detection_duration_seconds",
+		Help:    "Time spent detecting faces for gravity=face.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// RegisterMetrics exposes the transform metrics on the given registry.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(FaceDetectionDuration)
+}
+
+// FaceCascade is a pico decision tree cascade, as distributed with pico and pigo.
+type FaceCascade struct {
+	treeDepth      int
+	treeNum        int
+	treeCodes      []int8
+	treePred       []float32
+	treeThresholds []float32
+}
+
+type faceDetection struct {
+	row, col, scale int
+	quality         float32
+}
+
+// SetFaceCascade sets the cascade used by gravity=face, nil restoring the embedded facefinder cascade.
+func SetFaceCascade(cascade *FaceCascade) {
+	if cascade == nil {
+		cascade = mustUnpackFaceCascade(facefinder)
+	}
+	faceCascade = cascade
+}
+
+func mustUnpackFaceCascade(data []byte) *FaceCascade {
+	cascade, err := UnpackFaceCascade(data)
+	if err != nil {
+		panic(err)
+	}
+	return cascade
+}
+
+// UnpackFaceCascade decodes a cascade in the pico binary format.
+func UnpackFaceCascade(data []byte) (*FaceCascade, error) {
+	errTruncated := errors.New("face cascade is truncated")
+	if len(data) < 16 {
+		return nil, errTruncated
+	}
+	// the first 8 bytes hold training parameters that are not used for detection
+	pos := 8
+	depth := int(binary.LittleEndian.Uint32(data[pos:]))
+	treeNum := int(binary.LittleEndian.Uint32(data[pos+4:]))
+	pos += 8
+	if depth <= 0 || depth > 16 || treeNum <= 0 {
+		return nil, errors.New("face cascade has an invalid tree depth or count")
+	}
+
+	leaves := 1 << depth
+	codesLen := 4*leaves - 4
+	if (len(data)-pos)/(codesLen+4*leaves+4) < treeNum {
+		return nil, errTruncated
+	}
+
+	cascade := &FaceCascade{
+		treeDepth:      depth,
+		treeNum:        treeNum,
+		treeCodes:      make([]int8, 0, treeNum*4*leaves),
+		treePred:       make([]float32, 0, treeNum*leaves),
+		treeThresholds: make([]float32, 0, treeNum),
+	}
+	for t := 0; t < treeNum; t++ {
+		cascade.treeCodes = append(cascade.treeCodes, 0, 0, 0, 0)
+		for _, code := range data[pos : pos+codesLen] {
+			cascade.treeCodes = append(cascade.treeCodes, int8(code))
+		}
+		pos += codesLen
+		for i := 0; i < leaves; i++ {
+			cascade.treePred = append(cascade.treePred, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		}
+		cascade.treeThresholds = append(cascade.treeThresholds, math.Float32frombits(binary.LittleEndian.Uint32(data[pos:])))
+		pos += 4
+	}
+	return cascade, nil
+}
+
+// classifyRegion runs the cascade on the square of the given size centered on (row, col).
+// It returns a positive confidence for a face and a negative value otherwise.
+func (fc *FaceCascade) classifyRegion(row, col, scale int, pixels []uint8, dim int) float32 {
+	leaves := 1 << fc.treeDepth
+	row, col = row*256, col*256
+	root := 0
+	var out float32
+	for i := 0; i < fc.treeNum; i++ {
+		idx := 1
+		for j := 0; j < fc.treeDepth; j++ {
+			code := fc.treeCodes[root+4*idx : root+4*idx+4]
+			p1 := ((row+int(code[0])*scale)>>8)*dim + ((col + int(code[1])*scale) >> 8)
+			p2 := ((row+int(code[2])*scale)>>8)*dim + ((col + int(code[3])*scale) >> 8)
+			idx = 2 * idx
+			if pixels[p1] <= pixels[p2] {
+				idx++
+			}
+		}
+		out += fc.treePred[leaves*i+idx-leaves]
+		if out <= fc.treeThresholds[i] {
+			return -1
+		}
+		root += 4 * leaves
+	}
+	return out - fc.treeThresholds[fc.treeNum-1]
+}
+
+// detect scans the grayscale pixels at every position and scale.
+func (fc *FaceCascade) detect(pixels []uint8, rows, cols int) []faceDetection {
+	var detections []faceDetection
+	for scale := faceMinSize; scale <= min(rows, cols); scale = max(scale+1, int(float64(scale)*faceScaleFactor)) {
+		step := max(1, int(faceShiftFactor*float64(scale)))
+		offset := scale/2 + 1
+		for row := offset; row <= rows-offset; row += step {
+			for col := offset; col <= cols-offset; col += step {
+				if q := fc.classifyRegion(row, col, scale, pixels, cols); q > 0 {
+					detections = append(detections, faceDetection{row: row, col: col, scale: scale, quality: q})
+				}
+			}
+		}
+	}
+	return detections
+}
+
+// clusterFaceDetections merges overlapping detections, strongest first.
+func clusterFaceDetections(detections []faceDetection) []faceDetection {
+	slices.SortStableFunc(detections, func(a, b faceDetection) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	assigned := make([]bool, len(detections))
+	var clusters []faceDetection
+	for i := range detections {
+		if assigned[i] {
+			continue
+		}
+		var row, col, scale, n int
+		var quality float32
+		for j := range detections {
+			if faceIoU(detections[i], detections[j]) > faceIoUThreshold {
+				assigned[j] = true
+				row, col, scale = row+detections[j].row, col+detections[j].col, scale+detections[j].scale
+				quality += detections[j].quality
+				n++
+			}
+		}
+		clusters = append(clusters, faceDetection{row: row / n, col: col / n, scale: scale / n, quality: quality})
+	}
+	return clusters
+}
+
+func faceIoU(a, b faceDetection) float64 {
+	ra, rb := a.rect(), b.rect()
+	inter := ra.Intersect(rb)
+	if inter.Empty() {
+		return 0
+	}
+	interArea := float64(inter.Dx() * inter.Dy())
+	return interArea / (float64(ra.Dx()*ra.Dy()+rb.Dx()*rb.Dy()) - interArea)
+}
+
+func (d faceDetection) rect() image.Rectangle {
+	return image.Rect(d.col-d.scale/2, d.row-d.scale/2, d.col+d.scale/2, d.row+d.scale/2)
+}
+
+// detectFaces returns the bounding boxes of the faces found in img, in img coordinates.
+func detectFaces(img image.Image, cascade *FaceCascade) []image.Rectangle {
+	if cascade == nil {
+		return nil
+	}
+	start := time.Now()
+	defer func() { FaceDetectionDuration.Observe(time.Since(start).Seconds()) }()
+
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	scale := math.Min(1, float64(faceMaxSide)/float64(max(srcW, srcH)))
+	sample := imaging.Resize(img, max(1, int(math.Round(float64(srcW)*scale))), max(1, int(math.Round(float64(srcH)*scale))), imaging.Box)
+	cols, rows := sample.Bounds().Dx(), sample.Bounds().Dy()
+
+	pixels := make([]uint8, rows*cols)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			i := sample.PixOffset(x, y)
+			r, g, b := int(sample.Pix[i]), int(sample.Pix[i+1]), int(sample.Pix[i+2])
+			pixels[y*cols+x] = uint8((299*r + 587*g + 114*b) / 1000)
+		}
+	}
+
+	var faces []image.Rectangle
+	for _, cluster := range clusterFaceDetections(cascade.detect(pixels, rows, cols)) {
+		if cluster.quality < faceMinQuality {
+			continue
+		}
+		rect := cluster.rect()
+		faces = append(faces, image.Rect(
+			int(math.Round(float64(rect.Min.X)/scale)), int(math.Round(float64(rect.Min.Y)/scale)),
+			int(math.Round(float64(rect.Max.X)/scale)), int(math.Round(float64(rect.Max.Y)/scale)),
+		).Intersect(image.Rect(0, 0, srcW, srcH)))
+	}
+	return faces
+}
+
+// faceOffset centers the crop window on the bounding box of the detected faces and keeps the whole
+// box inside the window when it fits. Without faces the window stays centered.
+func faceOffset(img image.Image, cropW, cropH int) (int, int) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	faces := detectFaces(img, faceCascade)
+	if len(faces) == 0 {
+		return (srcW - cropW) / 2, (srcH - cropH) / 2
+	}
+
+	box := faces[0]
+	for _, face := range faces[1:] {
+		box = box.Union(face)
+	}
+	x := focusOffset(srcW, cropW, float64(box.Min.X+box.Max.X)/2/float64(srcW))
+	y := focusOffset(srcH, cropH, float64(box.Min.Y+box.Max.Y)/2/float64(srcH))
+	return keepInWindow(x, cropW, box.Min.X, box.Max.X), keepInWindow(y, cropH, box.Min.Y, box.Max.Y)
+}
@@ -0,0 +1,186 @@
+package transform
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// darkSpotCascade packs a one node cascade that fires when the center of the region is darker
+// than the point half a region to its right.
+func darkSpotCascade() []byte {
+	data := make([]byte, 8)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = append(data, 0, 127, 0, 0)
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(10))
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(-1))
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(0))
+	return data
+}
+
+func darkSpotImage(w, h int, spot image.Rectangle) *image.NRGBA {
+	img := imaging.New(w, h, color.White)
+	for y := spot.Min.Y; y < spot.Max.Y; y++ {
+		for x := spot.Min.X; x < spot.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{A: 255})
+		}
+	}
+	return img
+}
+
+func TestUnpackFaceCascade(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		got, err := UnpackFaceCascade(darkSpotCascade())
+		assert.NoError(t, err)
+		assert.Equal(t, &FaceCascade{
+			treeDepth:      1,
+			treeNum:        1,
+			treeCodes:      []int8{0, 0, 0, 0, 0, 127, 0, 0},
+			treePred:       []float32{10, -1},
+			treeThresholds: []float32{0},
+		}, got)
+	})
+	t.Run("failTooShort", func(t *testing.T) {
+		_, err := UnpackFaceCascade([]byte{1, 2, 3})
+		assert.EqualError(t, err, "face cascade is truncated")
+	})
+	t.Run("failTruncatedTrees", func(t *testing.T) {
+		data := darkSpotCascade()
+		_, err := UnpackFaceCascade(data[:len(data)-4])
+		assert.EqualError(t, err, "face cascade is truncated")
+	})
+	t.Run("failInvalidDepth", func(t *testing.T) {
+		data := darkSpotCascade()
+		binary.LittleEndian.PutUint32(data[8:], 0)
+		_, err := UnpackFaceCascade(data)
+		assert.EqualError(t, err, "face cascade has an invalid tree depth or count")
+	})
+}
+
+func TestSetFaceCascade(t *testing.T) {
+	previous := faceCascade
+	defer func() { faceCascade = previous }()
+	cascade, err := UnpackFaceCascade(darkSpotCascade())
+	assert.NoError(t, err)
+
+	SetFaceCascade(cascade)
+	assert.Same(t, cascade, faceCascade)
+	SetFaceCascade(nil)
+	assert.Equal(t, mustUnpackFaceCascade(facefinder), faceCascade)
+}
+
+func Test_facefinder(t *testing.T) {
+	cascade, err := UnpackFaceCascade(facefinder)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, cascade.treeDepth)
+	assert.Equal(t, 468, cascade.treeNum)
+}
+
+func TestFaceCascade_classifyRegion(t *testing.T) {
+	cascade, err := UnpackFaceCascade(darkSpotCascade())
+	assert.NoError(t, err)
+
+	pixels := []uint8{
+		255, 255, 255, 255,
+		255, 0, 255, 255,
+		255, 255, 255, 255,
+		255, 255, 255, 255,
+	}
+	assert.Equal(t, float32(10), cascade.classifyRegion(1, 1, 4, pixels, 4))
+	assert.Equal(t, float32(-1), cascade.classifyRegion(2, 2, 4, pixels, 4))
+}
+
+func Test_clusterFaceDetections(t *testing.T) {
+	detections := []faceDetection{
+		{row: 50, col: 50, scale: 20, quality: 3},
+		{row: 52, col: 52, scale: 20, quality: 4},
+		{row: 150, col: 150, scale: 30, quality: 6},
+	}
+	got := clusterFaceDetections(detections)
+	assert.Equal(t, []faceDetection{
+		{row: 150, col: 150, scale: 30, quality: 6},
+		{row: 51, col: 51, scale: 20, quality: 7},
+	}, got)
+}
+
+func Test_detectFaces(t *testing.T) {
+	cascade, err := UnpackFaceCascade(darkSpotCascade())
+	assert.NoError(t, err)
+	spot := image.Rect(40, 110, 120, 190)
+
+	t.Run("withoutCascade", func(t *testing.T) {
+		assert.Nil(t, detectFaces(darkSpotImage(600, 300, spot), nil))
+	})
+	t.Run("noFace", func(t *testing.T) {
+		assert.Empty(t, detectFaces(imaging.New(600, 300, color.White), cascade))
+	})
+	t.Run("findsDarkSpot", func(t *testing.T) {
+		faces := detectFaces(darkSpotImage(600, 300, spot), cascade)
+		assert.NotEmpty(t, faces)
+		for _, face := range faces {
+			assert.True(t, face.Overlaps(spot), "face %v must overlap %v", face, spot)
+		}
+	})
+}
+
+func Test_detectFaces_facefinder(t *testing.T) {
+	portrait, err := imaging.Open("../fixtures/face.jpg")
+	assert.NoError(t, err)
+	// the 320x400 portrait pasted on the right of a wide white canvas
+	img := imaging.Paste(imaging.New(1200, 400, color.White), portrait, image.Pt(800, 0))
+	cascade := mustUnpackFaceCascade(facefinder)
+
+	t.Run("noFace", func(t *testing.T) {
+		assert.Empty(t, detectFaces(imaging.New(1200, 400, color.White), cascade))
+	})
+	t.Run("findsFace", func(t *testing.T) {
+		faces := detectFaces(img, cascade)
+		assert.Len(t, faces, 1)
+		// between the eyes, above the mouth
+		assert.True(t, image.Pt(960, 220).In(faces[0]), "face %v must contain the nose", faces[0])
+		assert.True(t, faces[0].In(image.Rect(800, 0, 1120, 400)), "face %v must be in the portrait", faces[0])
+	})
+	t.Run("centerOnFace", func(t *testing.T) {
+		previous := faceCascade
+		defer func() { faceCascade = previous }()
+		faceCascade = cascade
+		x, y := faceOffset(img, 400, 400)
+		assert.Equal(t, 0, y)
+		assert.InDelta(t, 760, x, 60)
+	})
+}
+
+func Test_faceOffset(t *testing.T) {
+	spot := image.Rect(40, 110, 120, 190)
+	img := darkSpotImage(600, 300, spot)
+	previous := faceCascade
+	defer func() { faceCascade = previous }()
+
+	t.Run("fallbackToCenter", func(t *testing.T) {
+		faceCascade = nil
+		x, y := faceOffset(img, 300, 300)
+		assert.Equal(t, 150, x)
+		assert.Equal(t, 0, y)
+	})
+	t.Run("centerOnFaces", func(t *testing.T) {
+		cascade, err := UnpackFaceCascade(darkSpotCascade())
+		assert.NoError(t, err)
+		faceCascade = cascade
+		got := cropWindow(img, 300, 300, &types.ResizeOption{Gravity: types.TypeGravityFace})
+		assert.True(t, spot.In(got), "window %v must contain the face %v", got, spot)
+	})
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, RegisterMetrics(registry))
+	assert.Error(t, RegisterMetrics(registry))
+}
@@ -0,0 +1,288 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"strconv"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// transformGIF applies the requested options to every frame of a GIF and re-encodes an animated GIF
+// or an animated WebP. Stills, anim=false and conversions to another format use the first frame only.
+func transformGIF(file *bytes.Buffer, opts *types.ResizeOption) error {
+	g, errDecode := gif.DecodeAll(bytes.NewReader(file.Bytes()))
+	if errDecode != nil {
+		return fmt.Errorf("failed to decode image %s: %w", opts.Source, errDecode)
+	}
+	frames := composeGIFFrames(g)
+	if len(frames) == 0 {
+		return fmt.Errorf("failed to decode image %s: gif has no frame", opts.Source)
+	}
+
+	if len(frames) == 1 || !opts.KeepAnimation() || (opts.Format != types.TypeGIF && opts.Format != types.TypeWEBP) {
+		return encode(file, process(frames[0], opts), opts)
+	}
+
+	stabilizeAnimation(frames[0], opts)
+	if opts.Format == types.TypeWEBP {
+		return transformGIFToWebP(file, g, frames, opts)
+	}
+
+	out := &gif.GIF{
+		Image:     make([]*image.Paletted, 0, len(frames)),
+		Delay:     g.Delay,
+		Disposal:  make([]byte, 0, len(frames)),
+		LoopCount: g.LoopCount,
+	}
+	sourcePalette := gifSourcePalette(g)
+	for _, frame := range frames {
+		frameOpts := *opts
+		img := process(frame, &frameOpts)
+
+		paletted := image.NewPaletted(img.Bounds(), framePalette(img, sourcePalette))
+		draw.Draw(paletted, paletted.Rect, img, img.Bounds().Min, draw.Src)
+		out.Image = append(out.Image, paletted)
+		// every output frame is a full composited frame, the canvas is cleared between them
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+	}
+
+	file.Reset()
+	if errEncode := gif.EncodeAll(file, out); errEncode != nil {
+		return fmt.Errorf("failed to format image %s: %w", opts.Source, errEncode)
+	}
+	return nil
+}
+
+// gifSourcePalette merges the global palette and the local palettes of every frame, since a composited
+// frame shows the pixels left by the previous frames in their own colors. It is cut at the 256 colors
+// a GIF palette can hold.
+func gifSourcePalette(g *gif.GIF) color.Palette {
+	merged := color.Palette{color.NRGBA{}}
+	seen := map[color.NRGBA]bool{{}: true}
+	add := func(p color.Palette) {
+		for _, c := range p {
+			key := color.NRGBAModel.Convert(c).(color.NRGBA)
+			if key.A == 0 {
+				key = color.NRGBA{}
+			}
+			if len(merged) < 256 && !seen[key] {
+				seen[key] = true
+				merged = append(merged, key)
+			}
+		}
+	}
+	if global, ok := g.Config.ColorModel.(color.Palette); ok {
+		add(global)
+	}
+	for _, frame := range g.Image {
+		add(frame.Palette)
+	}
+	return merged
+}
+
+// framePalette returns the exact colors of img when they fit in a GIF palette, and the source palette
+// otherwise, for instance when resizing blended new colors.
+func framePalette(img image.Image, sourcePalette color.Palette) color.Palette {
+	colors := make(color.Palette, 0, 256)
+	seen := make(map[color.NRGBA]bool)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			if seen[c] {
+				continue
+			}
+			if len(colors) == 256 {
+				return sourcePalette
+			}
+			seen[c] = true
+			colors = append(colors, c)
+		}
+	}
+	return colors
+}
+
+func transformGIFToWebP(file *bytes.Buffer, g *gif.GIF, frames []image.Image, opts *types.ResizeOption) error {
+	processed := make([]image.Image, 0, len(frames))
+	delays := make([]int, 0, len(frames))
+	for i, frame := range frames {
+		frameOpts := *opts
+		processed = append(processed, process(frame, &frameOpts))
+		delay := 0
+		if i < len(g.Delay) {
+			// GIF delays are in hundredths of a second
+			delay = g.Delay[i] * 10
+		}
+		delays = append(delays, delay)
+	}
+
+	options, errOptions := webpOptions(opts)
+	if errOptions != nil {
+		return fmt.Errorf("failed to format image %s: %w", opts.Source, errOptions)
+	}
+	file.Reset()
+	if errEncode := encodeAnimatedWebP(file, processed, delays, webpLoopCount(g.LoopCount), options); errEncode != nil {
+		return fmt.Errorf("failed to format image %s: %w", opts.Source, errEncode)
+	}
+	return nil
+}
+
+// webpLoopCount converts a GIF loop count, where -1 plays once and n repeats n times, to the WebP
+// convention where 0 loops forever and n plays n times.
+func webpLoopCount(loopCount int) int {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	default:
+		return loopCount + 1
+	}
+}
+
+// gifFrameCount counts the image descriptors of a GIF by walking its blocks, without decoding any frame.
+func gifFrameCount(data []byte) (int, error) {
+	errTruncated := errors.New("truncated gif")
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return 0, errors.New("invalid gif header")
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(data) {
+				return errTruncated
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return nil
+			}
+		}
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension
+			pos += 2
+			if errSkip := skipSubBlocks(); errSkip != nil {
+				return 0, errSkip
+			}
+		case 0x2c: // image descriptor
+			if pos+10 > len(data) {
+				return 0, errTruncated
+			}
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			// LZW minimum code size
+			pos++
+			if errSkip := skipSubBlocks(); errSkip != nil {
+				return 0, errSkip
+			}
+			frames++
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, fmt.Errorf("unknown gif block 0x%02x", data[pos])
+		}
+	}
+	return 0, errTruncated
+}
+
+// composeGIFFrames renders each frame on the logical screen, honouring the disposal method of the
+// previous frames, so every returned image is a full frame.
+func composeGIFFrames(g *gif.GIF) []image.Image {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	frames := make([]image.Image, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		composed := image.NewNRGBA(bounds)
+		copy(composed.Pix, canvas.Pix)
+		frames = append(frames, composed)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+// stabilizeAnimation resolves the automatic trim and the content-aware gravities on the first frame, cropped and trimmed
+// as process does, art direction included, so every frame of an animation is processed the same way.
+func stabilizeAnimation(first image.Image, opts *types.ResizeOption) {
+	firstOpts := *opts
+	first = Orient(first, &firstOpts)
+	if opts.Crop != "" {
+		first = Crop(first, &firstOpts)
+	}
+	stabilizeTrim(first, opts)
+	if opts.Trim != "" {
+		firstOpts.Trim = opts.Trim
+		first = Trim(first, &firstOpts)
+	}
+	stabilizeGravity(first, &firstOpts)
+	opts.Gravity = firstOpts.Gravity
+}
+
+// stabilizeGravity resolves content-aware gravities once, on the first frame, and turns them into a
+// focal point so every frame of an animation is cropped at the same place.
+func stabilizeGravity(img image.Image, opts *types.ResizeOption) {
+	if opts.Gravity != types.TypeGravityAuto && opts.Gravity != types.TypeGravityFace {
+		return
+	}
+	if !opts.NeedResize() || (opts.Fit != types.TypeFitCover && opts.Fit != types.TypeFitCrop) {
+		return
+	}
+
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	width, height := opts.Width, opts.Height
+	if width == 0 {
+		width = srcW
+	}
+	if height == 0 {
+		height = srcH
+	}
+	cropW, cropH := coverSize(srcW, srcH, width, height)
+	if opts.Fit == types.TypeFitCrop && srcW <= width && srcH <= height {
+		cropW, cropH = srcW, srcH
+	}
+
+	rect := cropWindow(img, cropW, cropH, opts).Sub(bounds.Min)
+	fx := (float64(rect.Min.X) + float64(cropW)/2) / float64(srcW)
+	fy := (float64(rect.Min.Y) + float64(cropH)/2) / float64(srcH)
+	opts.Gravity = strconv.FormatFloat(fx, 'f', -1, 64) + "x" + strconv.FormatFloat(fy, 'f', -1, 64)
+}
@@ -0,0 +1,258 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"testing"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func getAnimatedGIF(t *testing.T) *bytes.Buffer {
+	data, err := os.ReadFile("../fixtures/animated.gif")
+	assert.NoError(t, err)
+	return bytes.NewBuffer(data)
+}
+
+func Test_composeGIFFrames(t *testing.T) {
+	g, err := gif.DecodeAll(getAnimatedGIF(t))
+	assert.NoError(t, err)
+
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.NRGBA{R: 220, G: 30, B: 30, A: 255}
+	blue := color.NRGBA{R: 30, G: 30, B: 220, A: 255}
+	green := color.NRGBA{R: 30, G: 160, B: 30, A: 255}
+
+	frames := composeGIFFrames(g)
+	assert.Len(t, frames, 4)
+	for _, frame := range frames {
+		assert.Equal(t, image.Rect(0, 0, 64, 32), frame.Bounds())
+	}
+
+	at := func(i, x, y int) color.NRGBA {
+		return frames[i].(*image.NRGBA).NRGBAAt(x, y)
+	}
+	assert.Equal(t, white, at(0, 8, 16))
+	assert.Equal(t, red, at(1, 8, 16))
+	// frame 1 is disposed to previous: the red square is gone on frame 2
+	assert.Equal(t, white, at(2, 8, 16))
+	assert.Equal(t, blue, at(2, 24, 16))
+	// frame 2 is disposed to background: its area is transparent on frame 3
+	assert.Equal(t, color.NRGBA{}, at(3, 24, 16))
+	assert.Equal(t, green, at(3, 40, 16))
+	assert.Equal(t, white, at(3, 60, 2))
+}
+
+func Test_transformGIF(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   *types.ResizeOption
+		wantFn func(t *testing.T, data []byte)
+	}{
+		{
+			name: "resizeAnimation",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Width: 32},
+			wantFn: func(t *testing.T, data []byte) {
+				g, err := gif.DecodeAll(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Len(t, g.Image, 4)
+				assert.Equal(t, []int{10, 20, 30, 40}, g.Delay)
+				assert.Equal(t, 0, g.LoopCount)
+				for i, frame := range g.Image {
+					assert.Equal(t, image.Rect(0, 0, 32, 16), frame.Bounds())
+					assert.Equal(t, uint8(gif.DisposalBackground), g.Disposal[i])
+				}
+			},
+		},
+		{
+			name: "coverWithAutoGravityKeepsTheSameWindow",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Width: 16, Height: 16, Fit: types.TypeFitCover, Gravity: types.TypeGravityAuto},
+			wantFn: func(t *testing.T, data []byte) {
+				g, err := gif.DecodeAll(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Len(t, g.Image, 4)
+				for _, frame := range g.Image {
+					assert.Equal(t, image.Rect(0, 0, 16, 16), frame.Bounds())
+				}
+			},
+		},
+		{
+			name: "explicitTrim",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Trim: "2;4;2;4"},
+			wantFn: func(t *testing.T, data []byte) {
+				g, err := gif.DecodeAll(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Len(t, g.Image, 4)
+				for _, frame := range g.Image {
+					assert.Equal(t, image.Rect(0, 0, 56, 28), frame.Bounds())
+				}
+			},
+		},
+		{
+			name: "animDisabled",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Anim: new(bool)},
+			wantFn: func(t *testing.T, data []byte) {
+				g, err := gif.DecodeAll(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Len(t, g.Image, 1)
+				assert.Equal(t, image.Rect(0, 0, 64, 32), g.Image[0].Bounds())
+			},
+		},
+		{
+			name: "convertToStill",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeAVIF, Width: 32},
+			wantFn: func(t *testing.T, data []byte) {
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+				assert.NoError(t, err)
+				assert.Equal(t, "avif", format)
+				assert.Equal(t, 32, cfg.Width)
+				assert.Equal(t, 16, cfg.Height)
+			},
+		},
+		{
+			name: "convertToAnimatedWebP",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeWEBP, Width: 32},
+			wantFn: func(t *testing.T, data []byte) {
+				chunks, err := parseWebPChunks(data)
+				assert.NoError(t, err)
+				assert.Len(t, chunks, 6)
+				assert.Equal(t, "VP8X", chunks[0].fourCC)
+				assert.Equal(t, byte(webpFlagAnimation), chunks[0].data[0]&webpFlagAnimation)
+				assert.Equal(t, []byte{31, 0, 0, 15, 0, 0}, chunks[0].data[4:10])
+				assert.Equal(t, "ANIM", chunks[1].fourCC)
+				for i, delay := range []int{100, 200, 300, 400} {
+					anmf := chunks[2+i]
+					assert.Equal(t, "ANMF", anmf.fourCC)
+					assert.Equal(t, []byte{31, 0, 0, 15, 0, 0}, anmf.data[6:12])
+					assert.Equal(t, []byte{byte(delay), byte(delay >> 8), 0}, anmf.data[12:15])
+				}
+			},
+		},
+		{
+			name: "convertToStillWebPWithAnimDisabled",
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeWEBP, Anim: new(bool)},
+			wantFn: func(t *testing.T, data []byte) {
+				chunks, err := parseWebPChunks(data)
+				assert.NoError(t, err)
+				for _, chunk := range chunks {
+					assert.NotEqual(t, "ANMF", chunk.fourCC)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := getAnimatedGIF(t)
+			err := Transform(file, tt.opts)
+			assert.NoError(t, err)
+			tt.wantFn(t, file.Bytes())
+		})
+	}
+}
+
+func Test_transformGIF_LocalPalettes(t *testing.T) {
+	// the 8x8 red first frame and the 2x2 blue second frame each have a one-color local palette
+	data, err := os.ReadFile("../fixtures/local-palettes.gif")
+	assert.NoError(t, err)
+	file := bytes.NewBuffer(data)
+	assert.NoError(t, Transform(file, &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Width: 8}))
+
+	g, err := gif.DecodeAll(file)
+	assert.NoError(t, err)
+	assert.Len(t, g.Image, 2)
+	rgba := func(c color.Color) color.RGBA {
+		return color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	assert.Equal(t, red, rgba(g.Image[0].At(0, 0)))
+	assert.Equal(t, blue, rgba(g.Image[1].At(1, 1)))
+	// the red left by the first frame keeps its color on the second frame
+	assert.Equal(t, red, rgba(g.Image[1].At(5, 5)))
+}
+
+func Test_framePalette(t *testing.T) {
+	sourcePalette := color.Palette{color.NRGBA{}, color.NRGBA{R: 255, A: 255}}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{G: 255, A: 255})
+	assert.Equal(t, color.Palette{color.NRGBA{G: 255, A: 255}, color.NRGBA{}}, framePalette(img, sourcePalette))
+
+	gradient := image.NewNRGBA(image.Rect(0, 0, 300, 1))
+	for x := 0; x < 300; x++ {
+		gradient.SetNRGBA(x, 0, color.NRGBA{R: uint8(x), G: uint8(x / 256), A: 255})
+	}
+	assert.Equal(t, sourcePalette, framePalette(gradient, sourcePalette))
+}
+
+func Test_gifSourcePalette(t *testing.T) {
+	data, err := os.ReadFile("../fixtures/local-palettes.gif")
+	assert.NoError(t, err)
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	assert.NoError(t, err)
+	// the decoder pads each one-color palette with black
+	black := color.NRGBA{A: 255}
+	assert.Equal(t, color.Palette{color.NRGBA{}, color.NRGBA{R: 255, A: 255}, black, color.NRGBA{B: 255, A: 255}}, gifSourcePalette(g))
+}
+
+func Test_transformGIF_DecodeError(t *testing.T) {
+	err := Transform(bytes.NewBufferString("GIF89a"), &types.ResizeOption{OriginFormat: types.TypeGIF, Format: types.TypeGIF, Width: 10, Source: "broken.gif"})
+	assert.ErrorContains(t, err, "failed to decode image broken.gif")
+}
+
+func Test_webpLoopCount(t *testing.T) {
+	assert.Equal(t, 0, webpLoopCount(0))
+	assert.Equal(t, 1, webpLoopCount(-1))
+	assert.Equal(t, 4, webpLoopCount(3))
+}
+
+func Test_gifFrameCount(t *testing.T) {
+	data := getAnimatedGIF(t).Bytes()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr string
+	}{
+		{name: "animated", data: data, want: 4},
+		{name: "invalidHeader", data: []byte("not a gif at all"), wantErr: "invalid gif header"},
+		{name: "truncated", data: data[:len(data)-8], wantErr: "truncated gif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gifFrameCount(tt.data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_stabilizeGravity(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+
+	t.Run("autoBecomesFocalPoint", func(t *testing.T) {
+		opts := &types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitCover, Gravity: types.TypeGravityAuto}
+		want := cropWindow(img, 200, 200, opts)
+		stabilizeGravity(img, opts)
+		assert.NotEqual(t, types.TypeGravityAuto, opts.Gravity)
+		assert.Equal(t, want, cropWindow(img, 200, 200, opts))
+	})
+	t.Run("keepsOtherGravity", func(t *testing.T) {
+		opts := &types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitCover, Gravity: types.TypeGravityLeft}
+		stabilizeGravity(img, opts)
+		assert.Equal(t, types.TypeGravityLeft, opts.Gravity)
+	})
+	t.Run("keepsGravityWithoutCrop", func(t *testing.T) {
+		opts := &types.ResizeOption{Width: 100, Height: 100, Fit: types.TypeFitContain, Gravity: types.TypeGravityAuto}
+		stabilizeGravity(img, opts)
+		assert.Equal(t, types.TypeGravityAuto, opts.Gravity)
+	})
+}
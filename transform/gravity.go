@@ -0,0 +1,246 @@
+package transform
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	// saliencyMaxSide is the longest side of the downsampled copy used to score crop windows.
+	saliencyMaxSide = 256
+	// saliencyMaxSteps bounds the number of candidate positions evaluated on each axis.
+	saliencyMaxSteps = 64
+	// saliencyBins is the number of luminance buckets used for the entropy score.
+	saliencyBins = 16
+)
+
+var gravityAnchors = map[string][2]float64{
+	types.TypeGravityCenter:      {0.5, 0.5},
+	types.TypeGravityTop:         {0.5, 0},
+	types.TypeGravityBottom:      {0.5, 1},
+	types.TypeGravityLeft:        {0, 0.5},
+	types.TypeGravityRight:       {1, 0.5},
+	types.TypeGravityTopLeft:     {0, 0},
+	types.TypeGravityTopRight:    {1, 0},
+	types.TypeGravityBottomLeft:  {0, 1},
+	types.TypeGravityBottomRight: {1, 1},
+}
+
+func hasGravity(opts *types.ResizeOption) bool {
+	return (opts.Gravity != "" && opts.Gravity != types.TypeGravityCenter) || hasArtDirectionFocus(opts)
+}
+
+// gravityFocus returns the fractional focal point described by a named anchor or by "XxY"
+// coordinates between 0 and 1. Unknown values report false and callers fall back to center.
+func gravityFocus(gravity string) (float64, float64, bool) {
+	if anchor, ok := gravityAnchors[gravity]; ok {
+		return anchor[0], anchor[1], true
+	}
+	xStr, yStr, found := strings.Cut(gravity, "x")
+	if !found {
+		return 0.5, 0.5, false
+	}
+	fx, errX := strconv.ParseFloat(xStr, 64)
+	fy, errY := strconv.ParseFloat(yStr, 64)
+	if errX != nil || errY != nil || fx < 0 || fx > 1 || fy < 0 || fy > 1 {
+		return 0.5, 0.5, false
+	}
+	return fx, fy, true
+}
+
+// focusOffset centers a window of size win on the focal point and keeps it inside src.
+func focusOffset(src, win int, focus float64) int {
+	return min(src-win, max(0, int(math.Round(focus*float64(src)-float64(win)/2))))
+}
+
+// coverSize returns the largest window with the target aspect ratio that fits in the source.
+func coverSize(srcW, srcH, width, height int) (int, int) {
+	if srcW*height > srcH*width {
+		w := int(math.Round(float64(srcH) * float64(width) / float64(height)))
+		return max(1, min(srcW, w)), srcH
+	}
+	h := int(math.Round(float64(srcW) * float64(height) / float64(width)))
+	return srcW, max(1, min(srcH, h))
+}
+
+// fill behaves like imaging.Fill but positions the crop window with the requested gravity.
+func fill(img image.Image, width, height int, opts *types.ResizeOption) *image.NRGBA {
+	if !hasGravity(opts) {
+		return imaging.Fill(img, width, height, imaging.Center, resampleFilter(opts))
+	}
+	bounds := img.Bounds()
+	cropW, cropH := coverSize(bounds.Dx(), bounds.Dy(), width, height)
+	rect := cropWindow(img, cropW, cropH, opts)
+	return imaging.Resize(imaging.Crop(img, rect), width, height, resampleFilter(opts))
+}
+
+// cropAnchor behaves like imaging.CropAnchor but positions the crop window with the requested gravity.
+func cropAnchor(img image.Image, width, height int, opts *types.ResizeOption) *image.NRGBA {
+	if !hasGravity(opts) {
+		return imaging.CropAnchor(img, width, height, imaging.Center)
+	}
+	bounds := img.Bounds()
+	rect := cropWindow(img, min(width, bounds.Dx()), min(height, bounds.Dy()), opts)
+	return imaging.Crop(img, rect)
+}
+
+// cropWindow returns the cropW x cropH rectangle of img selected by opts.Gravity.
+func cropWindow(img image.Image, cropW, cropH int, opts *types.ResizeOption) image.Rectangle {
+	bounds := img.Bounds()
+	var x, y int
+	switch opts.Gravity {
+	case types.TypeGravityAuto:
+		x, y = saliencyOffset(img, cropW, cropH)
+	case types.TypeGravityFace:
+		x, y = faceOffset(img, cropW, cropH)
+	case "":
+		if hasArtDirectionFocus(opts) {
+			x, y = artDirectionOffset(img, cropW, cropH, opts.ArtDirection)
+		} else {
+			x, y = (bounds.Dx()-cropW)/2, (bounds.Dy()-cropH)/2
+		}
+	default:
+		fx, fy, ok := gravityFocus(opts.Gravity)
+		if !ok || !hasGravity(opts) {
+			x, y = (bounds.Dx()-cropW)/2, (bounds.Dy()-cropH)/2
+		} else {
+			x, y = focusOffset(bounds.Dx(), cropW, fx), focusOffset(bounds.Dy(), cropH, fy)
+		}
+	}
+	return image.Rect(x, y, x+cropW, y+cropH).Add(bounds.Min)
+}
+
+// paste places img over the background following opts.Gravity, as used by the pad fit. The
+// background shows through the transparent pixels of img. Auto and face gravities have nothing to
+// look for in the padding and stay centered.
+func paste(background, img image.Image, opts *types.ResizeOption) *image.NRGBA {
+	fx, fy, ok := gravityFocus(opts.Gravity)
+	if !ok || !hasGravity(opts) {
+		return imaging.OverlayCenter(background, img, 1)
+	}
+	bgBounds, imgBounds := background.Bounds(), img.Bounds()
+	x := int(math.Round(fx * float64(bgBounds.Dx()-imgBounds.Dx())))
+	y := int(math.Round(fy * float64(bgBounds.Dy()-imgBounds.Dy())))
+	return imaging.Overlay(background, img, image.Pt(bgBounds.Min.X+x, bgBounds.Min.Y+y), 1)
+}
+
+// saliencyOffset picks the crop window holding the most edge energy and luminance entropy.
+// The search runs on a downsampled copy and is fully deterministic: on equal scores the
+// window closest to the center wins, so cached variants stay stable.
+func saliencyOffset(img image.Image, cropW, cropH int) (int, int) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if cropW >= srcW && cropH >= srcH {
+		return 0, 0
+	}
+
+	scale := math.Min(1, float64(saliencyMaxSide)/float64(max(srcW, srcH)))
+	sample := imaging.Resize(img, max(1, int(math.Round(float64(srcW)*scale))), max(1, int(math.Round(float64(srcH)*scale))), imaging.Box)
+	sw, sh := sample.Bounds().Dx(), sample.Bounds().Dy()
+	winW := min(sw, max(1, int(math.Round(float64(cropW)*scale))))
+	winH := min(sh, max(1, int(math.Round(float64(cropH)*scale))))
+
+	energy, hist := saliencyTables(sample)
+	area := float64(winW * winH)
+
+	bestX, bestY := (sw-winW)/2, (sh-winH)/2
+	bestScore, bestDist := math.Inf(-1), math.Inf(1)
+	centerX, centerY := float64(sw-winW)/2, float64(sh-winH)/2
+	for _, y := range candidateOffsets(sh - winH) {
+		for _, x := range candidateOffsets(sw - winW) {
+			edge := float64(sumArea(energy, sw, x, y, winW, winH)) / area / 255
+			entropy := 0.0
+			for b := 0; b < saliencyBins; b++ {
+				count := float64(sumArea(hist[b], sw, x, y, winW, winH))
+				if count > 0 {
+					p := count / area
+					entropy -= p * math.Log2(p)
+				}
+			}
+			score := edge + entropy/math.Log2(saliencyBins)
+			dist := math.Hypot(float64(x)-centerX, float64(y)-centerY)
+			if score > bestScore+1e-9 || (math.Abs(score-bestScore) <= 1e-9 && dist < bestDist) {
+				bestScore, bestDist = score, dist
+				bestX, bestY = x, y
+			}
+		}
+	}
+
+	x := min(srcW-cropW, int(math.Round(float64(bestX)/scale)))
+	y := min(srcH-cropH, int(math.Round(float64(bestY)/scale)))
+	return max(0, x), max(0, y)
+}
+
+// candidateOffsets lists the window offsets evaluated along one axis.
+func candidateOffsets(free int) []int {
+	if free <= 0 {
+		return []int{0}
+	}
+	step := max(1, int(math.Ceil(float64(free)/saliencyMaxSteps)))
+	offsets := make([]int, 0, free/step+2)
+	for o := 0; o < free; o += step {
+		offsets = append(offsets, o)
+	}
+	return append(offsets, free)
+}
+
+// saliencyTables builds summed-area tables of the edge energy and of each luminance bucket.
+func saliencyTables(img *image.NRGBA) ([]int64, [saliencyBins][]int64) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	luma := make([]int32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			r, g, b := int32(img.Pix[i]), int32(img.Pix[i+1]), int32(img.Pix[i+2])
+			luma[y*w+x] = (299*r + 587*g + 114*b) / 1000
+		}
+	}
+
+	stride := w + 1
+	energy := make([]int64, stride*(h+1))
+	var hist [saliencyBins][]int64
+	for b := range hist {
+		hist[b] = make([]int64, stride*(h+1))
+	}
+
+	at := func(x, y int) int32 {
+		return luma[min(h-1, max(0, y))*w+min(w-1, max(0, x))]
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx := at(x+1, y) - at(x-1, y)
+			dy := at(x, y+1) - at(x, y-1)
+			e := int64(abs32(dx) + abs32(dy))
+			bin := int(luma[y*w+x]) * saliencyBins / 256
+
+			i := (y+1)*stride + x + 1
+			energy[i] = e + energy[i-1] + energy[i-stride] - energy[i-stride-1]
+			for b := range hist {
+				v := int64(0)
+				if b == bin {
+					v = 1
+				}
+				hist[b][i] = v + hist[b][i-1] + hist[b][i-stride] - hist[b][i-stride-1]
+			}
+		}
+	}
+	return energy, hist
+}
+
+// sumArea returns the sum of a w x h window at (x, y) in a summed-area table built for an image of width imgW.
+func sumArea(table []int64, imgW, x, y, w, h int) int64 {
+	stride := imgW + 1
+	return table[(y+h)*stride+x+w] - table[y*stride+x+w] - table[(y+h)*stride+x] + table[y*stride+x]
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// detailedImage returns a flat gray image with a high-contrast checkerboard in the given rectangle.
+func detailedImage(w, h int, detail image.Rectangle) *image.NRGBA {
+	img := imaging.New(w, h, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+	for y := detail.Min.Y; y < detail.Max.Y; y++ {
+		for x := detail.Min.X; x < detail.Max.X; x++ {
+			if (x/4+y/4)%2 == 0 {
+				img.SetNRGBA(x, y, color.NRGBA{A: 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func Test_coverSize(t *testing.T) {
+	tests := []struct {
+		name             string
+		srcW, srcH, w, h int
+		wantW, wantH     int
+	}{
+		{name: "landscapeToSquare", srcW: 400, srcH: 200, w: 100, h: 100, wantW: 200, wantH: 200},
+		{name: "portraitToSquare", srcW: 200, srcH: 400, w: 100, h: 100, wantW: 200, wantH: 200},
+		{name: "sameRatio", srcW: 400, srcH: 200, w: 200, h: 100, wantW: 400, wantH: 200},
+		{name: "squareToLandscape", srcW: 300, srcH: 300, w: 300, h: 100, wantW: 300, wantH: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotW, gotH := coverSize(tt.srcW, tt.srcH, tt.w, tt.h)
+			assert.Equal(t, tt.wantW, gotW)
+			assert.Equal(t, tt.wantH, gotH)
+		})
+	}
+}
+
+func Test_cropWindow(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want image.Rectangle
+	}{
+		{
+			name: "defaultCenter",
+			opts: &types.ResizeOption{},
+			want: image.Rect(100, 0, 300, 200),
+		},
+		{
+			name: "explicitCenter",
+			opts: &types.ResizeOption{Gravity: types.TypeGravityCenter},
+			want: image.Rect(100, 0, 300, 200),
+		},
+		{
+			name: "left",
+			opts: &types.ResizeOption{Gravity: types.TypeGravityLeft},
+			want: image.Rect(0, 0, 200, 200),
+		},
+		{
+			name: "bottomRight",
+			opts: &types.ResizeOption{Gravity: types.TypeGravityBottomRight},
+			want: image.Rect(200, 0, 400, 200),
+		},
+		{
+			name: "focalPoint",
+			opts: &types.ResizeOption{Gravity: "0.3x0.7"},
+			want: image.Rect(20, 0, 220, 200),
+		},
+		{
+			name: "focalPointClamped",
+			opts: &types.ResizeOption{Gravity: "0.9x0.1"},
+			want: image.Rect(200, 0, 400, 200),
+		},
+		{
+			name: "invalidFallsBackToCenter",
+			opts: &types.ResizeOption{Gravity: "2x0.5"},
+			want: image.Rect(100, 0, 300, 200),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cropWindow(img, 200, 200, tt.opts))
+		})
+	}
+
+	t.Run("autoFollowsDetail", func(t *testing.T) {
+		got := cropWindow(img, 200, 200, &types.ResizeOption{Gravity: types.TypeGravityAuto})
+		assert.Equal(t, 200, got.Dx())
+		assert.Equal(t, 200, got.Dy())
+		assert.True(t, image.Rect(300, 40, 380, 160).In(got), "window %v must contain the detailed area", got)
+	})
+}
+
+func Test_gravityFocus(t *testing.T) {
+	tests := []struct {
+		gravity string
+		wantX   float64
+		wantY   float64
+		wantOk  bool
+	}{
+		{gravity: types.TypeGravityCenter, wantX: 0.5, wantY: 0.5, wantOk: true},
+		{gravity: types.TypeGravityTop, wantX: 0.5, wantY: 0, wantOk: true},
+		{gravity: types.TypeGravityBottomLeft, wantX: 0, wantY: 1, wantOk: true},
+		{gravity: types.TypeGravityTopRight, wantX: 1, wantY: 0, wantOk: true},
+		{gravity: "0.3x0.7", wantX: 0.3, wantY: 0.7, wantOk: true},
+		{gravity: "0x1", wantX: 0, wantY: 1, wantOk: true},
+		{gravity: "1.5x0.5", wantX: 0.5, wantY: 0.5, wantOk: false},
+		{gravity: "axb", wantX: 0.5, wantY: 0.5, wantOk: false},
+		{gravity: "middle", wantX: 0.5, wantY: 0.5, wantOk: false},
+		{gravity: types.TypeGravityAuto, wantX: 0.5, wantY: 0.5, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gravity, func(t *testing.T) {
+			x, y, ok := gravityFocus(tt.gravity)
+			assert.Equal(t, tt.wantX, x)
+			assert.Equal(t, tt.wantY, y)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func Test_saliencyOffset(t *testing.T) {
+	t.Run("detailOnTheLeft", func(t *testing.T) {
+		img := detailedImage(600, 300, image.Rect(20, 100, 120, 200))
+		x, y := saliencyOffset(img, 150, 300)
+		assert.Equal(t, 0, y)
+		assert.LessOrEqual(t, x, 20)
+	})
+	t.Run("detailOnTheBottom", func(t *testing.T) {
+		img := detailedImage(300, 600, image.Rect(100, 480, 200, 580))
+		x, y := saliencyOffset(img, 300, 200)
+		assert.Equal(t, 0, x)
+		assert.GreaterOrEqual(t, y, 380)
+	})
+	t.Run("flatImageStaysCentered", func(t *testing.T) {
+		img := imaging.New(400, 200, color.White)
+		x, y := saliencyOffset(img, 200, 200)
+		assert.Equal(t, 100, x)
+		assert.Equal(t, 0, y)
+	})
+	t.Run("windowCoversSource", func(t *testing.T) {
+		img := imaging.New(400, 200, color.White)
+		x, y := saliencyOffset(img, 400, 200)
+		assert.Equal(t, 0, x)
+		assert.Equal(t, 0, y)
+	})
+	t.Run("deterministic", func(t *testing.T) {
+		img := getImage(t)
+		x1, y1 := saliencyOffset(img, 100, 100)
+		x2, y2 := saliencyOffset(img, 100, 100)
+		assert.Equal(t, x1, x2)
+		assert.Equal(t, y1, y2)
+	})
+}
+
+func Test_fill(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+
+	t.Run("centerMatchesImagingFill", func(t *testing.T) {
+		got := fill(img, 100, 100, &types.ResizeOption{})
+		assert.Equal(t, imaging.Fill(img, 100, 100, imaging.Center, imaging.Lanczos), got)
+	})
+	t.Run("autoGravity", func(t *testing.T) {
+		got := fill(img, 100, 100, &types.ResizeOption{Gravity: types.TypeGravityAuto})
+		assert.Equal(t, image.Rect(0, 0, 100, 100), got.Bounds())
+		rect := cropWindow(img, 200, 200, &types.ResizeOption{Gravity: types.TypeGravityAuto})
+		want := imaging.Resize(imaging.Crop(img, rect), 100, 100, imaging.Lanczos)
+		assert.Equal(t, want, got)
+	})
+}
+
+func Test_cropAnchor(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 40, 380, 160))
+
+	t.Run("centerMatchesImagingCropAnchor", func(t *testing.T) {
+		got := cropAnchor(img, 500, 100, &types.ResizeOption{})
+		assert.Equal(t, imaging.CropAnchor(img, 500, 100, imaging.Center), got)
+	})
+	t.Run("autoGravity", func(t *testing.T) {
+		got := cropAnchor(img, 100, 500, &types.ResizeOption{Gravity: types.TypeGravityAuto})
+		assert.Equal(t, image.Rect(0, 0, 100, 200), got.Bounds())
+		assert.Equal(t, imaging.Crop(img, cropWindow(img, 100, 200, &types.ResizeOption{Gravity: types.TypeGravityAuto})), got)
+	})
+}
+
+func Test_paste(t *testing.T) {
+	bg := imaging.New(100, 50, color.Transparent)
+	img := imaging.New(50, 50, color.White)
+
+	tests := []struct {
+		name    string
+		gravity string
+		want    image.Point
+	}{
+		{name: "center", gravity: "", want: image.Pt(25, 0)},
+		{name: "auto", gravity: types.TypeGravityAuto, want: image.Pt(25, 0)},
+		{name: "left", gravity: types.TypeGravityLeft, want: image.Pt(0, 0)},
+		{name: "right", gravity: types.TypeGravityRight, want: image.Pt(50, 0)},
+		{name: "focalPoint", gravity: "0.2x0.5", want: image.Pt(10, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := paste(bg, img, &types.ResizeOption{Gravity: tt.gravity})
+			assert.Equal(t, imaging.Paste(bg, img, tt.want), got)
+		})
+	}
+}
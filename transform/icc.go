@@ -0,0 +1,337 @@
+package transform
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	// jpegICCChunkSize is the largest profile chunk an APP2 segment can hold.
+	jpegICCChunkSize = 65535 - 2 - 14
+	webpFlagICC      = 0x20
+	webpFlagEXIF     = 0x08
+	webpFlagXMP      = 0x04
+	// iccMaxSize bounds the size of a decompressed PNG profile.
+	iccMaxSize = 4 << 20
+	// tiffICCProfileTag is the TIFF tag holding the ICC profile (InterColorProfile).
+	tiffICCProfileTag = 34675
+)
+
+var (
+	jpegICCHeader = []byte("ICC_PROFILE\x00")
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+
+	webpChunkFlags = map[string]byte{"ICCP": webpFlagICC, "EXIF": webpFlagEXIF, "XMP ": webpFlagXMP}
+)
+
+// ReadICCProfile returns a copy of the ICC profile embedded in a JPEG, PNG, WebP, AVIF, HEIC or TIFF file, or nil.
+func ReadICCProfile(data []byte, format string) []byte {
+	switch format {
+	case types.TypeJPEG:
+		return readJPEGICCProfile(data)
+	case types.TypePNG:
+		return readPNGICCProfile(data)
+	case types.TypeWEBP:
+		return readWebPICCProfile(data)
+	case types.TypeAVIF, types.TypeHEIC, types.TypeHEIF:
+		return readISOBMFFICCProfile(data)
+	case types.TypeTIFF:
+		return readTIFFICCProfile(data)
+	}
+	return nil
+}
+
+// readJPEGICCProfile reassembles the profile split over the ICC_PROFILE APP2 segments.
+func readJPEGICCProfile(data []byte) []byte {
+	chunks := map[int][]byte{}
+	total := 0
+	walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+		if marker != jpegMarkerAPP2 || !bytes.HasPrefix(payload, jpegICCHeader) || len(payload) < len(jpegICCHeader)+2 {
+			return true
+		}
+		seq, count := int(payload[len(jpegICCHeader)]), int(payload[len(jpegICCHeader)+1])
+		chunks[seq] = payload[len(jpegICCHeader)+2:]
+		total = count
+		return true
+	})
+	if total == 0 || len(chunks) != total {
+		return nil
+	}
+	seqs := make([]int, 0, len(chunks))
+	for seq := range chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	var profile []byte
+	for i, seq := range seqs {
+		if seq != i+1 {
+			return nil
+		}
+		profile = append(profile, chunks[seq]...)
+	}
+	return profile
+}
+
+// readPNGICCProfile decompresses the iCCP chunk, which comes before the image data.
+func readPNGICCProfile(data []byte) []byte {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil
+	}
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if size < 0 || pos+12+size > len(data) || chunkType == "IDAT" {
+			return nil
+		}
+		if chunkType == "iCCP" {
+			payload := data[pos+8 : pos+8+size]
+			// profile name, null separator, compression method
+			name := bytes.IndexByte(payload, 0)
+			if name < 0 || name+2 > len(payload) || payload[name+1] != 0 {
+				return nil
+			}
+			reader, errZlib := zlib.NewReader(bytes.NewReader(payload[name+2:]))
+			if errZlib != nil {
+				return nil
+			}
+			defer reader.Close()
+			profile, errRead := io.ReadAll(io.LimitReader(reader, iccMaxSize))
+			if errRead != nil {
+				return nil
+			}
+			return profile
+		}
+		pos += 12 + size
+	}
+	return nil
+}
+
+func readWebPICCProfile(data []byte) []byte {
+	chunks, errParse := parseWebPChunks(data)
+	if errParse != nil {
+		return nil
+	}
+	for _, chunk := range chunks {
+		if chunk.fourCC == "ICCP" {
+			return bytes.Clone(chunk.data)
+		}
+	}
+	return nil
+}
+
+// readISOBMFFICCProfile reads the restricted or unrestricted ICC profile of the first colr property of an AVIF or
+// HEIC file, in the meta/iprp/ipco boxes.
+func readISOBMFFICCProfile(data []byte) []byte {
+	meta := findISOBMFFBox(data, "meta")
+	// full box: version and flags
+	if len(meta) < 4 {
+		return nil
+	}
+	var profile []byte
+	walkISOBMFFBoxes(findISOBMFFBox(findISOBMFFBox(meta[4:], "iprp"), "ipco"), func(boxType string, payload []byte) bool {
+		if boxType != "colr" || len(payload) < 4 {
+			return true
+		}
+		switch string(payload[:4]) {
+		case "prof", "rICC":
+			profile = bytes.Clone(payload[4:])
+			return false
+		}
+		return true
+	})
+	return profile
+}
+
+// findISOBMFFBox returns the payload of the first box of type boxType in data, or nil.
+func findISOBMFFBox(data []byte, boxType string) []byte {
+	var found []byte
+	walkISOBMFFBoxes(data, func(current string, payload []byte) bool {
+		if current == boxType {
+			found = payload
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// walkISOBMFFBoxes calls fn for every box of data with its payload (after the header). The walk
+// stops when fn returns false or on a malformed box.
+func walkISOBMFFBoxes(data []byte, fn func(boxType string, payload []byte) bool) {
+	pos := 0
+	for pos+8 <= len(data) {
+		size, header := uint64(binary.BigEndian.Uint32(data[pos:])), 8
+		boxType := string(data[pos+4 : pos+8])
+		switch size {
+		case 0:
+			// the box extends to the end of the data
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return
+			}
+			size, header = binary.BigEndian.Uint64(data[pos+8:]), 16
+		}
+		if size < uint64(header) || size > uint64(len(data)-pos) {
+			return
+		}
+		if !fn(boxType, data[pos+header:pos+int(size)]) {
+			return
+		}
+		pos += int(size)
+	}
+}
+
+// readTIFFICCProfile reads the InterColorProfile tag of the first IFD of a TIFF file.
+func readTIFFICCProfile(data []byte) []byte {
+	order, entry, _ := findExifEntry(data, tiffICCProfileTag)
+	if entry == nil {
+		return nil
+	}
+	count := int(order.Uint32(entry[4:]))
+	if count <= 4 {
+		return bytes.Clone(entry[8 : 8+count])
+	}
+	offset := int(order.Uint32(entry[8:]))
+	if offset < 8 || offset > len(data) || count > len(data)-offset {
+		return nil
+	}
+	return bytes.Clone(data[offset : offset+count])
+}
+
+// embedICCProfile returns the encoded image with the profile added, for JPEG, PNG and WebP.
+func embedICCProfile(data []byte, format string, profile []byte, img image.Image) ([]byte, error) {
+	switch format {
+	case types.TypeJPEG:
+		return embedJPEGICCProfile(data, profile)
+	case types.TypePNG:
+		return embedPNGICCProfile(data, profile)
+	case types.TypeWEBP:
+		return embedWebPICCProfile(data, profile, img)
+	}
+	return data, nil
+}
+
+// embedJPEGICCProfile inserts the ICC_PROFILE APP2 segments right after the SOI marker.
+func embedJPEGICCProfile(data, profile []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, errors.New("invalid jpeg")
+	}
+	count := (len(profile) + jpegICCChunkSize - 1) / jpegICCChunkSize
+	if count > 255 {
+		return nil, errors.New("icc profile too large")
+	}
+	out := make([]byte, 0, len(data)+len(profile)+count*18)
+	out = append(out, data[:2]...)
+	for i := 0; i < count; i++ {
+		chunk := profile[i*jpegICCChunkSize : min(len(profile), (i+1)*jpegICCChunkSize)]
+		out = append(out, 0xFF, jpegMarkerAPP2)
+		out = binary.BigEndian.AppendUint16(out, uint16(2+len(jpegICCHeader)+2+len(chunk)))
+		out = append(out, jpegICCHeader...)
+		out = append(out, byte(i+1), byte(count))
+		out = append(out, chunk...)
+	}
+	return append(out, data[2:]...), nil
+}
+
+// embedPNGICCProfile inserts an iCCP chunk right after the IHDR chunk.
+func embedPNGICCProfile(data, profile []byte) ([]byte, error) {
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	if !bytes.HasPrefix(data, pngSignature) || len(data) < ihdrEnd || string(data[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, errors.New("invalid png")
+	}
+
+	payload := &bytes.Buffer{}
+	payload.WriteString("ICC profile\x00\x00")
+	writer := zlib.NewWriter(payload)
+	if _, errWrite := writer.Write(profile); errWrite != nil {
+		return nil, errWrite
+	}
+	if errClose := writer.Close(); errClose != nil {
+		return nil, errClose
+	}
+
+	out := make([]byte, 0, len(data)+payload.Len()+12)
+	out = append(out, data[:ihdrEnd]...)
+	out = appendPNGChunk(out, "iCCP", payload.Bytes())
+	return append(out, data[ihdrEnd:]...), nil
+}
+
+func appendPNGChunk(dst []byte, chunkType string, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	start := len(dst)
+	dst = append(dst, chunkType...)
+	dst = append(dst, payload...)
+	return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst[start:]))
+}
+
+// embedWebPICCProfile turns a simple WebP into an extended one carrying an ICCP chunk.
+func embedWebPICCProfile(data, profile []byte, img image.Image) ([]byte, error) {
+	return setWebPChunks(data, img, webpChunk{fourCC: "ICCP", data: profile})
+}
+
+// setWebPChunks returns the WebP as an extended file carrying the given ICCP, EXIF or XMP chunks,
+// replacing existing ones, with the matching VP8X flags set.
+func setWebPChunks(data []byte, img image.Image, extra ...webpChunk) ([]byte, error) {
+	chunks, errParse := parseWebPChunks(data)
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	replaced := map[string]bool{}
+	for _, chunk := range extra {
+		replaced[chunk.fourCC] = true
+	}
+	var vp8x []byte
+	var iccp, body, trailer []webpChunk
+	for i, chunk := range append(chunks, extra...) {
+		if chunk.fourCC == "VP8X" {
+			vp8x = bytes.Clone(chunk.data)
+			continue
+		}
+		if i < len(chunks) && replaced[chunk.fourCC] {
+			continue
+		}
+		switch chunk.fourCC {
+		case "ICCP":
+			iccp = append(iccp, chunk)
+		case "EXIF", "XMP ":
+			trailer = append(trailer, chunk)
+		default:
+			body = append(body, chunk)
+		}
+	}
+	if len(vp8x) < 10 {
+		bounds := img.Bounds()
+		vp8x = []byte{0, 0, 0, 0}
+		vp8x = appendUint24(vp8x, bounds.Dx()-1)
+		vp8x = appendUint24(vp8x, bounds.Dy()-1)
+		if opaque, ok := img.(interface{ Opaque() bool }); !ok || !opaque.Opaque() {
+			vp8x[0] |= webpFlagAlpha
+		}
+	}
+	vp8x[0] &^= webpFlagICC | webpFlagEXIF | webpFlagXMP
+	for _, chunk := range append(iccp, trailer...) {
+		vp8x[0] |= webpChunkFlags[chunk.fourCC]
+	}
+
+	// VP8X and ICCP come first, EXIF and XMP after the image data
+	out := []byte("WEBP")
+	out = appendWebPChunk(out, "VP8X", vp8x)
+	for _, chunk := range slices.Concat(iccp, body, trailer) {
+		out = appendWebPChunk(out, chunk.fourCC, chunk.data)
+	}
+
+	header := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(out)))
+	return append(header, out...), nil
+}
@@ -0,0 +1,234 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/tiff"
+)
+
+func encodeTestImage(t *testing.T, img image.Image, format string) []byte {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, Format(buffer, img, &types.ResizeOption{Format: format, OriginFormat: format}))
+	return buffer.Bytes()
+}
+
+func TestReadICCProfile_Embedded(t *testing.T) {
+	img := imaging.New(16, 8, color.NRGBA{R: 180, G: 120, B: 100, A: 255})
+	profile := buildICCProfile(displayP3Matrix, srgbCurve)
+	largeProfile := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 30000)
+
+	tests := []struct {
+		name    string
+		format  string
+		profile []byte
+		decode  func(r *bytes.Reader) error
+	}{
+		{
+			name:    "jpeg",
+			format:  types.TypeJPEG,
+			profile: profile,
+			decode:  func(r *bytes.Reader) error { _, err := jpeg.Decode(r); return err },
+		},
+		{
+			name:    "jpegSplitOverSegments",
+			format:  types.TypeJPEG,
+			profile: largeProfile,
+			decode:  func(r *bytes.Reader) error { _, err := jpeg.Decode(r); return err },
+		},
+		{
+			name:    "png",
+			format:  types.TypePNG,
+			profile: profile,
+			decode:  func(r *bytes.Reader) error { _, err := png.Decode(r); return err },
+		},
+		{
+			name:    "webp",
+			format:  types.TypeWEBP,
+			profile: profile,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeTestImage(t, img, tt.format)
+			assert.Nil(t, ReadICCProfile(data, tt.format))
+
+			embedded, err := embedICCProfile(data, tt.format, tt.profile, img)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.profile, ReadICCProfile(embedded, tt.format))
+			if tt.decode != nil {
+				assert.NoError(t, tt.decode(bytes.NewReader(embedded)))
+			}
+		})
+	}
+}
+
+func Test_embedWebPICCProfile(t *testing.T) {
+	img := imaging.New(16, 8, color.NRGBA{R: 180, G: 120, B: 100, A: 128})
+	data := encodeTestImage(t, img, types.TypeWEBP)
+
+	embedded, err := embedWebPICCProfile(data, []byte("profile"), img)
+	assert.NoError(t, err)
+
+	chunks, err := parseWebPChunks(embedded)
+	assert.NoError(t, err)
+	assert.Equal(t, "VP8X", chunks[0].fourCC)
+	assert.Equal(t, byte(webpFlagICC|webpFlagAlpha), chunks[0].data[0])
+	assert.Equal(t, []byte{15, 0, 0, 7, 0, 0}, chunks[0].data[4:10])
+	assert.Equal(t, webpChunk{fourCC: "ICCP", data: []byte("profile")}, chunks[1])
+
+	// embedding again replaces the profile
+	again, err := embedWebPICCProfile(embedded, []byte("other"), img)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("other"), readWebPICCProfile(again))
+	againChunks, err := parseWebPChunks(again)
+	assert.NoError(t, err)
+	assert.Len(t, againChunks, len(chunks))
+}
+
+func Test_embedICCProfile_Error(t *testing.T) {
+	img := imaging.New(1, 1, color.White)
+	_, err := embedICCProfile([]byte("nope"), types.TypeJPEG, []byte("profile"), img)
+	assert.ErrorContains(t, err, "invalid jpeg")
+	_, err = embedICCProfile([]byte("nope"), types.TypePNG, []byte("profile"), img)
+	assert.ErrorContains(t, err, "invalid png")
+	_, err = embedICCProfile([]byte("nope"), types.TypeWEBP, []byte("profile"), img)
+	assert.ErrorContains(t, err, "invalid webp container")
+
+	data, err := embedICCProfile([]byte("gif"), types.TypeGIF, []byte("profile"), img)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("gif"), data)
+}
+
+func testISOBMFFBox(boxType string, payload ...[]byte) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(slices.Concat(payload...))))
+	return slices.Concat(box, []byte(boxType), slices.Concat(payload...))
+}
+
+func TestReadICCProfile_ISOBMFF(t *testing.T) {
+	profile := buildICCProfile(displayP3Matrix, srgbCurve)
+	ftyp := testISOBMFFBox("ftyp", []byte("avif\x00\x00\x00\x00mif1avif"))
+	hdlr := testISOBMFFBox("hdlr", make([]byte, 24))
+	nclx := testISOBMFFBox("colr", []byte("nclx"), []byte{0, 1, 0, 13, 0, 6, 0x80})
+	meta := func(properties ...[]byte) []byte {
+		return testISOBMFFBox("meta", []byte{0, 0, 0, 0}, hdlr, testISOBMFFBox("iprp", testISOBMFFBox("ipco", properties...)))
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		want   []byte
+	}{
+		{name: "avifProf", data: slices.Concat(ftyp, meta(nclx, testISOBMFFBox("colr", []byte("prof"), profile))), format: types.TypeAVIF, want: profile},
+		{name: "avifRestrictedICC", data: slices.Concat(ftyp, meta(testISOBMFFBox("colr", []byte("rICC"), profile))), format: types.TypeAVIF, want: profile},
+		{name: "heicProf", data: slices.Concat(ftyp, meta(testISOBMFFBox("colr", []byte("prof"), profile))), format: types.TypeHEIC, want: profile},
+		{name: "nclxOnly", data: slices.Concat(ftyp, meta(nclx)), format: types.TypeAVIF},
+		{name: "noMeta", data: ftyp, format: types.TypeAVIF},
+		{name: "truncatedBox", data: slices.Concat(ftyp, meta(testISOBMFFBox("colr", []byte("prof"), profile)))[:len(ftyp)+40], format: types.TypeAVIF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ReadICCProfile(tt.data, tt.format))
+		})
+	}
+}
+
+func TestReadICCProfile_TIFF(t *testing.T) {
+	profile := buildICCProfile(displayP3Matrix, srgbCurve)
+	plainTIFF := &bytes.Buffer{}
+	assert.NoError(t, tiff.Encode(plainTIFF, imaging.New(4, 4, color.White), nil))
+	buildTIFF := func(order binary.AppendByteOrder, header string, count uint32, value []byte) []byte {
+		data := []byte(header)
+		data = order.AppendUint32(data, 8)
+		data = order.AppendUint16(data, 1)
+		data = order.AppendUint16(data, tiffICCProfileTag)
+		data = order.AppendUint16(data, 7)
+		data = order.AppendUint32(data, count)
+		if count <= 4 {
+			return append(data, append(value, make([]byte, 4-len(value))...)...)
+		}
+		data = order.AppendUint32(data, 8+2+12+4)
+		data = order.AppendUint32(data, 0)
+		return append(data, value...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{name: "littleEndian", data: buildTIFF(binary.LittleEndian, "II*\x00", uint32(len(profile)), profile), want: profile},
+		{name: "bigEndian", data: buildTIFF(binary.BigEndian, "MM\x00*", uint32(len(profile)), profile), want: profile},
+		{name: "inline", data: buildTIFF(binary.LittleEndian, "II*\x00", 3, []byte{1, 2, 3}), want: []byte{1, 2, 3}},
+		{name: "truncated", data: buildTIFF(binary.LittleEndian, "II*\x00", uint32(len(profile)+1), profile)},
+		{name: "noProfile", data: plainTIFF.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ReadICCProfile(tt.data, types.TypeTIFF))
+		})
+	}
+}
+
+func TestReadICCProfile_Invalid(t *testing.T) {
+	assert.Nil(t, ReadICCProfile([]byte("nope"), types.TypeJPEG))
+	assert.Nil(t, ReadICCProfile([]byte("nope"), types.TypePNG))
+	assert.Nil(t, ReadICCProfile([]byte("nope"), types.TypeWEBP))
+	assert.Nil(t, ReadICCProfile([]byte("nope"), types.TypeAVIF))
+	assert.Nil(t, ReadICCProfile([]byte("nope"), types.TypeTIFF))
+
+	// a missing chunk makes the profile unusable
+	data := encodeTestImage(t, imaging.New(4, 4, color.White), types.TypeJPEG)
+	embedded, err := embedJPEGICCProfile(data, bytes.Repeat([]byte{1}, jpegICCChunkSize+10))
+	assert.NoError(t, err)
+	embedded[2+4+len(jpegICCHeader)] = 3
+	assert.Nil(t, ReadICCProfile(embedded, types.TypeJPEG))
+}
+
+func TestTransform_ColorProfile(t *testing.T) {
+	profile := buildICCProfile(displayP3Matrix, srgbCurve)
+	source := imaging.New(32, 16, color.NRGBA{R: 40, G: 200, B: 90, A: 255})
+	jpegData, err := embedJPEGICCProfile(encodeTestImage(t, source, types.TypeJPEG), profile)
+	assert.NoError(t, err)
+
+	t.Run("convertToSRGB", func(t *testing.T) {
+		file := bytes.NewBuffer(bytes.Clone(jpegData))
+		err := Transform(file, &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 16})
+		assert.NoError(t, err)
+		assert.Nil(t, ReadICCProfile(file.Bytes(), types.TypeJPEG))
+
+		img, errDecode := jpeg.Decode(file)
+		assert.NoError(t, errDecode)
+		r, g, _, _ := img.At(8, 4).RGBA()
+		// the P3 green is more saturated than the sRGB one: red drops once converted
+		assert.Less(t, int(r>>8), 20)
+		assert.Greater(t, int(g>>8), 190)
+	})
+	t.Run("keep", func(t *testing.T) {
+		file := bytes.NewBuffer(bytes.Clone(jpegData))
+		err := Transform(file, &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 16, ColorProfile: types.TypeColorProfileKeep})
+		assert.NoError(t, err)
+		assert.Equal(t, profile, ReadICCProfile(file.Bytes(), types.TypeJPEG))
+
+		img, errDecode := jpeg.Decode(file)
+		assert.NoError(t, errDecode)
+		r, _, _, _ := img.At(8, 4).RGBA()
+		assert.InDelta(t, 40, int(r>>8), 4)
+	})
+	t.Run("keepToWebP", func(t *testing.T) {
+		file := bytes.NewBuffer(bytes.Clone(jpegData))
+		err := Transform(file, &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeWEBP, Width: 16, ColorProfile: types.TypeColorProfileKeep})
+		assert.NoError(t, err)
+		assert.Equal(t, profile, ReadICCProfile(file.Bytes(), types.TypeWEBP))
+	})
+}
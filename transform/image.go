@@ -7,32 +7,98 @@ import (
 	"image/color"
 	_ "image/gif"
 	_ "image/jpeg"
-	_ "image/png"
+	"image/png"
 	"math"
 	"slices"
 
 	"github.com/disintegration/imaging"
 	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+	"github.com/gen2brain/jpegxl"
+	"github.com/kolesa-team/go-webp/encoder"
 	"github.com/kolesa-team/go-webp/webp"
 	"github.com/reflet-devops/go-media-resizer/config"
+	"github.com/reflet-devops/go-media-resizer/transform/jpeg"
 	"github.com/reflet-devops/go-media-resizer/types"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 )
 
+// the avif, jpegxl, heic, webp, bmp and tiff packages register their decoders with image.RegisterFormat, so these
+// sources are decoded by image.Decode like the standard library formats
 var (
-	DefaultOptionAvif = avif.Options{Speed: avif.DefaultSpeed, Quality: avif.DefaultQuality}
+	DefaultOptionAvif  = avif.Options{Speed: avif.DefaultSpeed, Quality: avif.DefaultQuality}
+	DefaultOptionJXL   = jpegxl.Options{Effort: jpegxl.DefaultEffort, Quality: jpegxl.DefaultQuality}
+	DefaultJPEGQuality = 95
+
+	// convertedOrigins are the source formats encoded in the requested format, JPEG and PNG outputs included
+	convertedOrigins = []string{types.TypeAVIF, types.TypeWEBP, types.TypeJXL, types.TypeTIFF, types.TypeBMP, types.TypeHEIC, types.TypeHEIF, types.TypeSVG}
+
+	pngCompressionLevels = map[string]png.CompressionLevel{
+		types.TypeCompressionFast: png.BestSpeed,
+		types.TypeCompressionBest: png.BestCompression,
+		types.TypeCompressionNone: png.NoCompression,
+	}
 )
 
-func ValidateSourceDimensions(data *bytes.Buffer, sourceLimit config.SourceLimitConfig) error {
-	if sourceLimit.Mode == config.SourceLimitModeOff {
+func init() {
+	// the heic package only recognizes the heic brand, 10-bit photos are written with the heix brand
+	image.RegisterFormat("heic", "????ftypheix", heic.Decode, heic.DecodeConfig)
+}
+
+// ValidateSourceDimensions checks the dimensions of the source, of the page requested in opts for multi-page TIFF
+// sources, against the source limit. Every frame of a GIF, and of an animated WebP kept animated, is decoded in memory,
+// so its frames and total pixels are limited even when the source limit mode is off, by the default maximums when
+// none are configured.
+func ValidateSourceDimensions(data *bytes.Buffer, opts *types.ResizeOption, sourceLimit config.SourceLimitConfig) error {
+	limitDimensions := sourceLimit.Mode != config.SourceLimitModeOff
+	animatedWebP := opts.OriginFormat == types.TypeWEBP && opts.Format == types.TypeWEBP && opts.KeepAnimation() && IsAnimatedWebP(data.Bytes())
+	if !limitDimensions && opts.OriginFormat != types.TypeGIF && !animatedWebP {
 		return nil
 	}
-	cfg, _, err := image.DecodeConfig(bytes.NewReader(data.Bytes()))
+	var cfg image.Config
+	var format string
+	var err error
+	if animatedWebP {
+		cfg, format, err = webpCanvasConfig(data.Bytes())
+	} else {
+		source, errPage := sourcePage(data.Bytes(), opts)
+		if errPage != nil {
+			return fmt.Errorf("failed to read image dimensions: %w", errPage)
+		}
+		cfg, format, err = image.DecodeConfig(bytes.NewReader(source))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to read image dimensions: %w", err)
 	}
-	if cfg.Width > sourceLimit.MaxWidth || cfg.Height > sourceLimit.MaxHeight {
+	if limitDimensions && (cfg.Width > sourceLimit.MaxWidth || cfg.Height > sourceLimit.MaxHeight) {
 		return fmt.Errorf("source image dimensions %dx%d exceed maximum allowed %dx%d", cfg.Width, cfg.Height, sourceLimit.MaxWidth, sourceLimit.MaxHeight)
 	}
+	if format != types.TypeGIF && !animatedWebP {
+		return nil
+	}
+
+	maxFrames, maxTotalPixels := sourceLimit.MaxFrames, sourceLimit.MaxTotalPixels
+	if maxFrames == 0 {
+		maxFrames = config.DefaultMaxSourceFrames
+	}
+	if maxTotalPixels == 0 {
+		maxTotalPixels = config.DefaultMaxSourceTotalPixels
+	}
+	countFrames := gifFrameCount
+	if animatedWebP {
+		countFrames = webpFrameCount
+	}
+	frames, errCount := countFrames(data.Bytes())
+	if errCount != nil {
+		return fmt.Errorf("failed to read image frames: %w", errCount)
+	}
+	if frames > maxFrames {
+		return fmt.Errorf("source image frame count %d exceed maximum allowed %d", frames, maxFrames)
+	}
+	if totalPixels := cfg.Width * cfg.Height * frames; totalPixels > maxTotalPixels {
+		return fmt.Errorf("source image total pixels %d exceed maximum allowed %d", totalPixels, maxTotalPixels)
+	}
 	return nil
 }
 
@@ -40,20 +106,109 @@ func Transform(file *bytes.Buffer, opts *types.ResizeOption) error {
 	if !opts.NeedTransform() {
 		return nil
 	}
+	return Reencode(file, opts)
+}
+
+// Reencode decodes, processes and encodes file like Transform, even when opts requests no transformation. The output
+// only carries the metadata the policy of opts keeps.
+func Reencode(file *bytes.Buffer, opts *types.ResizeOption) error {
+	if opts.OriginFormat == types.TypeGIF {
+		return transformGIF(file, opts)
+	}
+	if opts.OriginFormat == types.TypeWEBP && opts.Format == types.TypeWEBP && opts.KeepAnimation() && IsAnimatedWebP(file.Bytes()) {
+		return transformAnimatedWebP(file, opts)
+	}
 
-	img, _, errDecode := image.Decode(file)
+	// copied out of the buffer, which is reused for the output
+	opts.ICCProfile = ReadICCProfile(file.Bytes(), opts.OriginFormat)
+	var metadata Metadata
+	if opts.Metadata != "" {
+		metadata = ReadMetadata(file.Bytes(), opts.OriginFormat).Filter(opts.Metadata, opts.AutoOrient && opts.Orientation > 1)
+	}
+
+	var img image.Image
+	var errDecode error
+	if opts.OriginFormat == types.TypeSVG {
+		img, errDecode = RasterizeSVG(file.Bytes(), opts)
+	} else if opts.OriginFormat == types.TypeWEBP && IsAnimatedWebP(file.Bytes()) {
+		// animations converted to another format, or with anim=false, become a still of their first frame
+		img, errDecode = decodeFirstWebPFrame(file.Bytes())
+	} else {
+		var source []byte
+		if source, errDecode = sourcePage(file.Bytes(), opts); errDecode == nil {
+			img, _, errDecode = image.Decode(bytes.NewReader(source))
+		}
+	}
 	if errDecode != nil {
 		return fmt.Errorf("failed to decode image %s: %w", opts.Source, errDecode)
 	}
 
+	img = process(img, opts)
+	if errEncode := encode(file, img, opts); errEncode != nil {
+		return errEncode
+	}
+
+	if !metadata.isEmpty() {
+		data, errEmbed := embedMetadata(file.Bytes(), encodedFormat(opts), metadata, img)
+		if errEmbed != nil {
+			return fmt.Errorf("failed to embed metadata %s: %w", opts.Source, errEmbed)
+		}
+		file.Reset()
+		file.Write(data)
+	}
+	return nil
+}
+
+// process applies the orientation, crop, trim, resize, color conversion, adjustments, text and watermark requested in
+// opts, then flattens the transparency for opaque output formats.
+func process(img image.Image, opts *types.ResizeOption) image.Image {
+	if opts.NeedOrient() {
+		img = Orient(img, opts)
+	}
+
+	// cropped on the image as displayed, before the trim and the resize
+	if opts.Crop != "" {
+		img = Crop(img, opts)
+	}
+
+	// trimmed before the resize, so the requested size applies to the content
+	if opts.Trim != "" {
+		img = Trim(img, opts)
+	}
+
 	if opts.NeedResize() {
+		if opts.Dpr > 1 {
+			applyDpr(img, opts)
+		}
 		img = Resize(img, opts)
 	}
 
+	// converted after the resize, on fewer pixels, and before the adjustments which expect sRGB
+	if len(opts.ICCProfile) > 0 && !keepColorProfile(opts) {
+		img = ConvertToSRGB(img, opts.ICCProfile)
+	}
+
 	if opts.NeedAdjust() {
 		img = Adjust(img, opts)
 	}
 
+	// drawn after the adjustments, which would blur or tint it
+	if opts.Text != "" && opts.Font != nil {
+		img = DrawText(img, opts)
+	}
+
+	// composited last, so the adjustments don't alter it
+	if opts.WatermarkImage != nil {
+		img = Watermark(img, opts.WatermarkImage)
+	}
+
+	if needFlatten(opts) {
+		img = Flatten(img, opts)
+	}
+	return img
+}
+
+func encode(file *bytes.Buffer, img image.Image, opts *types.ResizeOption) error {
 	// Discard any bytes left in the input buffer (e.g. trailing MPF sub-image
 	// in Apple HDR Gain Map JPEGs) before reusing it as the output buffer.
 	file.Reset()
@@ -61,6 +216,15 @@ func Transform(file *bytes.Buffer, opts *types.ResizeOption) error {
 	if errFormat != nil {
 		return fmt.Errorf("failed to format image %s: %w", opts.Source, errFormat)
 	}
+
+	if len(opts.ICCProfile) > 0 && keepColorProfile(opts) {
+		data, errEmbed := embedICCProfile(file.Bytes(), encodedFormat(opts), opts.ICCProfile, img)
+		if errEmbed != nil {
+			return fmt.Errorf("failed to embed color profile %s: %w", opts.Source, errEmbed)
+		}
+		file.Reset()
+		file.Write(data)
+	}
 	return nil
 }
 
@@ -85,36 +249,47 @@ func Resize(img image.Image, opts *types.ResizeOption) image.Image {
 	var imgResize *image.NRGBA
 	bounds := img.Bounds()
 	srcW, srcH := bounds.Dx(), bounds.Dy()
+	filter := resampleFilter(opts)
+
+	if opts.Fit == types.TypeFitCrop || opts.Fit == types.TypeFitCover {
+		if cropped, ok := artDirectionCrop(img, opts); ok {
+			// the crop box already frames the image, the focal point and safe area no longer apply
+			img = cropped
+			opts.ArtDirection = nil
+			bounds = img.Bounds()
+			srcW, srcH = bounds.Dx(), bounds.Dy()
+		}
+	}
 
 	switch opts.Fit {
 	case types.TypeFitCrop:
 		fillMissingDimension(img, opts)
 		if srcW <= opts.Width && srcH <= opts.Height {
-			imgResize = imaging.CropAnchor(img, opts.Width, opts.Height, imaging.Center)
+			imgResize = cropAnchor(img, opts.Width, opts.Height, opts)
 		} else {
-			imgResize = imaging.Fill(img, opts.Width, opts.Height, imaging.Center, imaging.Lanczos)
+			imgResize = fill(img, opts.Width, opts.Height, opts)
 		}
 	case types.TypeFitCover:
 		fillMissingDimension(img, opts)
-		imgResize = imaging.Fill(img, opts.Width, opts.Height, imaging.Center, imaging.Lanczos)
+		imgResize = fill(img, opts.Width, opts.Height, opts)
 	case types.TypeFitContain:
 		if opts.Width == 0 || opts.Height == 0 {
-			imgResize = imaging.Resize(img, opts.Width, opts.Height, imaging.Lanczos)
+			imgResize = imaging.Resize(img, opts.Width, opts.Height, filter)
 		} else {
 			w, h := fitProportional(srcW, srcH, opts.Width, opts.Height)
-			imgResize = imaging.Resize(img, w, h, imaging.Lanczos)
+			imgResize = imaging.Resize(img, w, h, filter)
 		}
 	case types.TypeFitPad:
 		fillMissingDimension(img, opts)
 		w, h := fitProportional(srcW, srcH, opts.Width, opts.Height)
-		imgResize = imaging.Resize(img, w, h, imaging.Lanczos)
-		bg := imaging.New(opts.Width, opts.Height, color.Transparent)
-		imgResize = imaging.PasteCenter(bg, imgResize)
+		imgResize = imaging.Resize(img, w, h, filter)
+		bg := newBackground(img, opts.Width, opts.Height, opts.Background, color.Transparent)
+		imgResize = paste(bg, imgResize, opts)
 	case types.TypeResize:
-		imgResize = imaging.Resize(img, opts.Width, opts.Height, imaging.Lanczos)
+		imgResize = imaging.Resize(img, opts.Width, opts.Height, filter)
 	default: // types.TypeFitScaleDown
 		fillMissingDimension(img, opts)
-		imgResize = imaging.Fit(img, opts.Width, opts.Height, imaging.Lanczos)
+		imgResize = imaging.Fit(img, opts.Width, opts.Height, filter)
 	}
 
 	return imgResize
@@ -130,22 +305,32 @@ func Adjust(img image.Image, opts *types.ResizeOption) image.Image {
 func Format(buffer *bytes.Buffer, img image.Image, opts *types.ResizeOption) error {
 	var errFormat error
 
-	if slices.Contains([]string{types.TypeAVIF, types.TypeWEBP}, opts.Format) {
+	if slices.Contains([]string{types.TypeAVIF, types.TypeWEBP, types.TypeJXL}, opts.Format) {
 		if opts.Format == types.TypeAVIF {
-			errFormat = avif.Encode(buffer, img, DefaultOptionAvif)
+			errFormat = avif.Encode(buffer, img, avifOptions(opts))
+		} else if opts.Format == types.TypeJXL {
+			errFormat = jpegxl.Encode(buffer, img, jxlOptions(opts))
 		} else if opts.Format == types.TypeWEBP {
-			errFormat = webp.Encode(buffer, img, nil)
+			options, errOptions := webpOptions(opts)
+			if errOptions != nil {
+				return errOptions
+			}
+			errFormat = webp.Encode(buffer, img, options)
 		}
 
-	} else if slices.Contains([]string{types.TypeJPEG, types.TypePNG}, opts.Format) {
-		format, errFindFormat := imaging.FormatFromExtension(opts.OriginFormat)
+	} else if slices.Contains([]string{types.TypeJPEG, types.TypePNG, types.TypeGIF}, opts.Format) {
+		format, errFindFormat := imaging.FormatFromExtension(encodedFormat(opts))
 		if errFindFormat != nil {
 			return fmt.Errorf("failed to find format from %s: %w", opts.Source, errFindFormat)
 		}
 
-		if opts.OriginFormat == types.TypeJPEG && opts.Quality != 0 {
-			optsEncode := imaging.JPEGQuality(opts.Quality)
+		if format == imaging.JPEG && (opts.Progressive || opts.Chroma == types.TypeChromaSubsampling444) {
+			errFormat = encodeJPEG(buffer, img, opts)
+		} else if quality := opts.FormatQuality(); format == imaging.JPEG && quality != 0 {
+			optsEncode := imaging.JPEGQuality(quality)
 			errFormat = imaging.Encode(buffer, img, format, optsEncode)
+		} else if level, ok := pngCompressionLevels[opts.Compression]; format == imaging.PNG && ok {
+			errFormat = imaging.Encode(buffer, img, format, imaging.PNGCompressionLevel(level))
 		} else {
 			errFormat = imaging.Encode(buffer, img, format)
 		}
@@ -155,3 +340,91 @@ func Format(buffer *bytes.Buffer, img image.Image, opts *types.ResizeOption) err
 
 	return errFormat
 }
+
+// encodedFormat returns the format Format writes: JPEG, PNG and GIF outputs keep the origin format, except for AVIF,
+// WebP, JPEG XL, TIFF, BMP, HEIC and SVG origins which are converted to the requested format.
+func encodedFormat(opts *types.ResizeOption) string {
+	if slices.Contains([]string{types.TypeAVIF, types.TypeWEBP, types.TypeJXL}, opts.Format) || slices.Contains(convertedOrigins, opts.OriginFormat) {
+		return opts.Format
+	}
+	return opts.OriginFormat
+}
+
+// jxlOptions returns the JPEG XL encoder options, libjxl being lossless at quality 100.
+func jxlOptions(opts *types.ResizeOption) jpegxl.Options {
+	options := DefaultOptionJXL
+	if opts.Lossless {
+		options.Quality = 100
+	} else if quality := opts.FormatQuality(); quality != 0 {
+		options.Quality = quality
+	}
+	return options
+}
+
+func avifOptions(opts *types.ResizeOption) avif.Options {
+	options := DefaultOptionAvif
+	if opts.Lossless {
+		// libavif is lossless at quality 100 with full chroma resolution
+		options.Quality, options.QualityAlpha = 100, 100
+		options.ChromaSubsampling = image.YCbCrSubsampleRatio444
+	} else if quality := opts.FormatQuality(); quality != 0 {
+		options.Quality = quality
+	}
+	if speed := opts.FormatDefaults[types.TypeAVIF].Speed; speed != 0 {
+		options.Speed = speed
+	}
+	return options
+}
+
+// webpOptions returns nil, the libwebp defaults, unless a quality or a lossless mode is requested.
+func webpOptions(opts *types.ResizeOption) (*encoder.Options, error) {
+	if opts.Lossless || opts.NearLossless > 0 {
+		options, errOptions := encoder.NewLosslessEncoderOptions(encoder.PresetDefault, webpLosslessLevel(opts.Compression))
+		if errOptions != nil {
+			return nil, fmt.Errorf("failed to build webp options: %w", errOptions)
+		}
+		if opts.NearLossless > 0 {
+			options.NearLossless = min(opts.NearLossless, 100)
+		}
+		return options, nil
+	}
+
+	quality := opts.FormatQuality()
+	if quality == 0 {
+		return nil, nil
+	}
+	options, errOptions := encoder.NewLossyEncoderOptions(encoder.PresetDefault, float32(quality))
+	if errOptions != nil {
+		return nil, fmt.Errorf("failed to build webp options: %w", errOptions)
+	}
+	return options, nil
+}
+
+// webpLosslessLevel maps the compression option to a libwebp lossless preset, from 0 (fastest) to 9 (smallest).
+func webpLosslessLevel(compression string) int {
+	switch compression {
+	case types.TypeCompressionFast, types.TypeCompressionNone:
+		return 0
+	case types.TypeCompressionBest:
+		return 9
+	default:
+		return 6
+	}
+}
+
+// encodeJPEG encodes with the transform/jpeg encoder, which supports the progressive and chroma
+// options, at the same default quality as imaging.Encode.
+func encodeJPEG(buffer *bytes.Buffer, img image.Image, opts *types.ResizeOption) error {
+	options := &jpeg.Options{Quality: DefaultJPEGQuality, Progressive: opts.Progressive}
+	if quality := opts.FormatQuality(); quality != 0 {
+		options.Quality = quality
+	}
+	if opts.Chroma == types.TypeChromaSubsampling444 {
+		options.Subsampling = jpeg.Subsampling444
+	}
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Opaque() {
+		// Same pixels as an opaque image.RGBA, which the encoder converts faster
+		img = &image.RGBA{Pix: nrgba.Pix, Stride: nrgba.Stride, Rect: nrgba.Rect}
+	}
+	return jpeg.Encode(buffer, img, options)
+}
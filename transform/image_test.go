@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
@@ -14,9 +16,12 @@ import (
 
 	"github.com/disintegration/imaging"
 	"github.com/gen2brain/avif"
+	"github.com/gen2brain/jpegxl"
+	"github.com/kolesa-team/go-webp/encoder"
 	"github.com/kolesa-team/go-webp/webp"
 	"github.com/reflet-devops/go-media-resizer/config"
 	"github.com/reflet-devops/go-media-resizer/hash"
+	"github.com/reflet-devops/go-media-resizer/transform/jpeg"
 	"github.com/reflet-devops/go-media-resizer/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,9 +34,12 @@ func TestValidateSourceDimensions(t *testing.T) {
 		return buf
 	}
 
+	multiPage := multiPageTIFF(image.NewGray(image.Rect(0, 0, 100, 100)), image.NewGray(image.Rect(0, 0, 200, 100)))
+
 	tests := []struct {
 		name        string
 		data        *bytes.Buffer
+		opts        *types.ResizeOption
 		sourceLimit config.SourceLimitConfig
 		wantErr     bool
 		errSubstr   string
@@ -66,6 +74,86 @@ func TestValidateSourceDimensions(t *testing.T) {
 			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModePassthrough, MaxWidth: 4096, MaxHeight: 4096},
 			wantErr: true, errSubstr: "exceed maximum",
 		},
+		{
+			name: "gifWithinFrameLimits",
+			data: getAnimatedGIF(t),
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 4096, MaxHeight: 4096, MaxFrames: 4, MaxTotalPixels: 64 * 32 * 4},
+			wantErr: false,
+		},
+		{
+			name: "gifFramesExceeded",
+			data: getAnimatedGIF(t),
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 4096, MaxHeight: 4096, MaxFrames: 3},
+			wantErr: true, errSubstr: "frame count 4 exceed maximum",
+		},
+		{
+			name: "gifTotalPixelsExceeded",
+			data: getAnimatedGIF(t),
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 4096, MaxHeight: 4096, MaxTotalPixels: 64 * 32 * 3},
+			wantErr: true, errSubstr: "total pixels 8192 exceed maximum",
+		},
+		{
+			name: "gifFramesExceededWithModeOff",
+			data: getAnimatedGIF(t),
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeOff, MaxFrames: 3},
+			wantErr: true, errSubstr: "frame count 4 exceed maximum allowed 3",
+		},
+		{
+			name: "gifDefaultFrameLimitsWithModeOff",
+			data: getAnimatedGIF(t),
+			opts: &types.ResizeOption{OriginFormat: types.TypeGIF},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeOff},
+			wantErr: false,
+		},
+		{
+			name: "gifTruncatedFrames",
+			data: bytes.NewBuffer(getAnimatedGIF(t).Bytes()[:200]),
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 4096, MaxHeight: 4096, MaxFrames: 10},
+			wantErr: true, errSubstr: "failed to read image frames",
+		},
+		{
+			name: "animatedWebPFramesExceededWithModeOff",
+			data: animatedWebP(t, 3),
+			opts: &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeWEBP},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeOff, MaxFrames: 2},
+			wantErr: true, errSubstr: "frame count 3 exceed maximum allowed 2",
+		},
+		{
+			name: "animatedWebPCanvasExceeded",
+			data: animatedWebP(t, 3),
+			opts: &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeWEBP},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 30, MaxHeight: 30},
+			wantErr: true, errSubstr: "source image dimensions 40x20 exceed maximum allowed 30x30",
+		},
+		{
+			name: "animatedWebPToStillWithModeOff",
+			data: animatedWebP(t, 3),
+			opts: &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeJPEG},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeOff, MaxFrames: 2},
+			wantErr: false,
+		},
+		{
+			name: "tiffFirstPageWithinLimits",
+			data: bytes.NewBuffer(multiPage),
+			opts: &types.ResizeOption{OriginFormat: types.TypeTIFF},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 150, MaxHeight: 150},
+			wantErr: false,
+		},
+		{
+			name: "tiffSelectedPageExceeded",
+			data: bytes.NewBuffer(multiPage),
+			opts: &types.ResizeOption{OriginFormat: types.TypeTIFF, Page: 2},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 150, MaxHeight: 150},
+			wantErr: true, errSubstr: "source image dimensions 200x100 exceed maximum allowed 150x150",
+		},
+		{
+			name: "tiffMissingPage",
+			data: bytes.NewBuffer(multiPage),
+			opts: &types.ResizeOption{OriginFormat: types.TypeTIFF, Page: 3},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 150, MaxHeight: 150},
+			wantErr: true, errSubstr: "page 3 not found",
+		},
 		{
 			name: "invalidData",
 			data: bytes.NewBufferString("not an image"),
@@ -75,7 +163,11 @@ func TestValidateSourceDimensions(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := ValidateSourceDimensions(tt.data, tt.sourceLimit)
+			opts := tt.opts
+			if opts == nil {
+				opts = &types.ResizeOption{}
+			}
+			err := ValidateSourceDimensions(tt.data, opts, tt.sourceLimit)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tt.errSubstr)
@@ -459,6 +551,154 @@ func TestFormat(t *testing.T) {
 			},
 			wantErr: assert.NoError,
 		},
+		{
+			name: "successFormatAvifWithOptQuality",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, Quality: 40},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = avif.Encode(w, img, avif.Options{Speed: avif.DefaultSpeed, Quality: 40})
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatAvifWithFormatDefaults",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, FormatDefaults: types.FormatDefaults{types.TypeAVIF: {Quality: 50, Speed: 8}}},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = avif.Encode(w, img, avif.Options{Speed: 8, Quality: 50})
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatWebPWithOptQuality",
+			opts: &types.ResizeOption{Format: types.TypeWEBP, Quality: 75},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				options, err := encoder.NewLossyEncoderOptions(encoder.PresetDefault, 75)
+				assert.NoError(t, err)
+				err = webp.Encode(w, img, options)
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatJpegWithFormatDefaults",
+			opts: &types.ResizeOption{Format: types.TypeJPEG, OriginFormat: types.TypeJPEG, FormatDefaults: types.FormatDefaults{types.TypeJPEG: {Quality: 70}}},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(70))
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatJpegProgressive",
+			opts: &types.ResizeOption{Format: types.TypeJPEG, OriginFormat: types.TypeJPEG, Progressive: true},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = jpeg.Encode(w, img, &jpeg.Options{Quality: DefaultJPEGQuality, Progressive: true})
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatJpegChroma444WithOptQuality",
+			opts: &types.ResizeOption{Format: types.TypeJPEG, OriginFormat: types.TypeJPEG, Quality: 80, Chroma: types.TypeChromaSubsampling444},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = jpeg.Encode(w, img, &jpeg.Options{Quality: 80, Subsampling: jpeg.Subsampling444})
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatJpegChroma420",
+			opts: &types.ResizeOption{Format: types.TypeJPEG, OriginFormat: types.TypeJPEG, Chroma: types.TypeChromaSubsampling420},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = imaging.Encode(w, img, imaging.JPEG)
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "successFormatPngWithCompression",
+			opts: &types.ResizeOption{Format: types.TypePNG, OriginFormat: types.TypePNG, Compression: types.TypeCompressionFast},
+			wantFn: func() string {
+				w := &bytes.Buffer{}
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				img, _, err := image.Decode(file)
+				assert.NoError(t, err)
+				err = imaging.Encode(w, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestSpeed))
+				assert.NoError(t, err)
+
+				shaSum, err := hash.GenerateSHA256(w)
+				assert.NoError(t, err)
+				return shaSum
+			},
+			wantErr: assert.NoError,
+		},
 		{
 			name:    "failedUnsupportedFormat",
 			opts:    &types.ResizeOption{Format: types.TypeText},
@@ -493,6 +733,107 @@ func TestFormat(t *testing.T) {
 	}
 }
 
+func Test_avifOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want avif.Options
+	}{
+		{
+			name: "successDefault",
+			opts: &types.ResizeOption{Format: types.TypeAVIF},
+			want: DefaultOptionAvif,
+		},
+		{
+			name: "successWithQuality",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, Quality: 40},
+			want: avif.Options{Speed: avif.DefaultSpeed, Quality: 40},
+		},
+		{
+			name: "successWithFormatDefaults",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, FormatDefaults: types.FormatDefaults{types.TypeAVIF: {Quality: 50, Speed: 8}}},
+			want: avif.Options{Speed: 8, Quality: 50},
+		},
+		{
+			name: "successLossless",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, Lossless: true, Quality: 30},
+			want: avif.Options{Speed: avif.DefaultSpeed, Quality: 100, QualityAlpha: 100, ChromaSubsampling: image.YCbCrSubsampleRatio444},
+		},
+		{
+			name: "successQualityOverridesFormatDefaults",
+			opts: &types.ResizeOption{Format: types.TypeAVIF, Quality: 30, FormatDefaults: types.FormatDefaults{types.TypeAVIF: {Quality: 50}}},
+			want: avif.Options{Speed: avif.DefaultSpeed, Quality: 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, avifOptions(tt.opts))
+		})
+	}
+}
+
+func Test_jxlOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want jpegxl.Options
+	}{
+		{
+			name: "successDefault",
+			opts: &types.ResizeOption{Format: types.TypeJXL},
+			want: DefaultOptionJXL,
+		},
+		{
+			name: "successWithQuality",
+			opts: &types.ResizeOption{Format: types.TypeJXL, Quality: 40},
+			want: jpegxl.Options{Effort: jpegxl.DefaultEffort, Quality: 40},
+		},
+		{
+			name: "successWithFormatDefaults",
+			opts: &types.ResizeOption{Format: types.TypeJXL, FormatDefaults: types.FormatDefaults{types.TypeJXL: {Quality: 50}}},
+			want: jpegxl.Options{Effort: jpegxl.DefaultEffort, Quality: 50},
+		},
+		{
+			name: "successLossless",
+			opts: &types.ResizeOption{Format: types.TypeJXL, Lossless: true, Quality: 30},
+			want: jpegxl.Options{Effort: jpegxl.DefaultEffort, Quality: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, jxlOptions(tt.opts))
+		})
+	}
+}
+
+func Test_webpOptions(t *testing.T) {
+	options, err := webpOptions(&types.ResizeOption{Format: types.TypeWEBP})
+	assert.NoError(t, err)
+	assert.Nil(t, options)
+
+	options, err = webpOptions(&types.ResizeOption{Format: types.TypeWEBP, FormatDefaults: types.FormatDefaults{types.TypeWEBP: {Quality: 80}}})
+	assert.NoError(t, err)
+	assert.Equal(t, float32(80), options.Quality)
+	assert.False(t, options.Lossless)
+
+	options, err = webpOptions(&types.ResizeOption{Format: types.TypeWEBP, Lossless: true, Quality: 80})
+	assert.NoError(t, err)
+	assert.True(t, options.Lossless)
+	assert.Equal(t, 100, options.NearLossless)
+
+	options, err = webpOptions(&types.ResizeOption{Format: types.TypeWEBP, NearLossless: 60})
+	assert.NoError(t, err)
+	assert.True(t, options.Lossless)
+	assert.Equal(t, 60, options.NearLossless)
+}
+
+func Test_webpLosslessLevel(t *testing.T) {
+	assert.Equal(t, 6, webpLosslessLevel(""))
+	assert.Equal(t, 0, webpLosslessLevel(types.TypeCompressionFast))
+	assert.Equal(t, 9, webpLosslessLevel(types.TypeCompressionBest))
+	assert.Equal(t, 6, webpLosslessLevel("unknown"))
+}
+
 func TestTransform(t *testing.T) {
 	path := "../fixtures/paysage.jpg"
 
@@ -575,3 +916,106 @@ func TestAdjust(t *testing.T) {
 	got := Adjust(img, &types.ResizeOption{})
 	assert.NotNil(t, got)
 }
+
+func Test_encodedFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want string
+	}{
+		{name: "webp", opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeWEBP}, want: types.TypeWEBP},
+		{name: "avif", opts: &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeAVIF}, want: types.TypeAVIF},
+		{name: "jpegKeepsOrigin", opts: &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypeJPEG}, want: types.TypePNG},
+		{name: "pngKeepsOrigin", opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypePNG}, want: types.TypeJPEG},
+		{name: "webpOriginToJpeg", opts: &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeJPEG}, want: types.TypeJPEG},
+		{name: "avifOriginToPng", opts: &types.ResizeOption{OriginFormat: types.TypeAVIF, Format: types.TypePNG}, want: types.TypePNG},
+		{name: "tiffOriginToJpeg", opts: &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeJPEG}, want: types.TypeJPEG},
+		{name: "jxl", opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJXL}, want: types.TypeJXL},
+		{name: "jxlOriginToJpeg", opts: &types.ResizeOption{OriginFormat: types.TypeJXL, Format: types.TypeJPEG}, want: types.TypeJPEG},
+		{name: "heicOriginToPng", opts: &types.ResizeOption{OriginFormat: types.TypeHEIC, Format: types.TypePNG}, want: types.TypePNG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, encodedFormat(tt.opts))
+		})
+	}
+}
+
+func TestTransform_ModernOrigins(t *testing.T) {
+	src := imaging.New(40, 20, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	encodeFns := map[string]func(w io.Writer) error{
+		types.TypeWEBP: func(w io.Writer) error { return webp.Encode(w, src, nil) },
+		types.TypeAVIF: func(w io.Writer) error { return avif.Encode(w, src, DefaultOptionAvif) },
+		types.TypeJXL:  func(w io.Writer) error { return jpegxl.Encode(w, src, DefaultOptionJXL) },
+	}
+	tests := []struct {
+		name       string
+		origin     string
+		format     string
+		wantFormat string
+	}{
+		{name: "webpToJpeg", origin: types.TypeWEBP, format: types.TypeJPEG, wantFormat: "jpeg"},
+		{name: "webpToPng", origin: types.TypeWEBP, format: types.TypePNG, wantFormat: "png"},
+		{name: "avifToJpeg", origin: types.TypeAVIF, format: types.TypeJPEG, wantFormat: "jpeg"},
+		{name: "avifToWebp", origin: types.TypeAVIF, format: types.TypeWEBP, wantFormat: "webp"},
+		{name: "webpToAvif", origin: types.TypeWEBP, format: types.TypeAVIF, wantFormat: "avif"},
+		{name: "jxlToPng", origin: types.TypeJXL, format: types.TypePNG, wantFormat: "png"},
+		{name: "jxlToWebp", origin: types.TypeJXL, format: types.TypeWEBP, wantFormat: "webp"},
+		{name: "avifToJxl", origin: types.TypeAVIF, format: types.TypeJXL, wantFormat: "jxl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &bytes.Buffer{}
+			assert.NoError(t, encodeFns[tt.origin](file))
+
+			err := Transform(file, &types.ResizeOption{OriginFormat: tt.origin, Format: tt.format, Width: 20})
+			assert.NoError(t, err)
+
+			cfg, format, errDecode := image.DecodeConfig(file)
+			assert.NoError(t, errDecode)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, 20, cfg.Width)
+			assert.Equal(t, 10, cfg.Height)
+		})
+	}
+}
+
+func TestTransform_HEICOrigin(t *testing.T) {
+	source, errRead := os.ReadFile("../fixtures/photo.heic")
+	assert.NoError(t, errRead)
+	sourceCfg, sourceFormat, errConfig := image.DecodeConfig(bytes.NewReader(source))
+	assert.NoError(t, errConfig)
+	assert.Equal(t, "heic", sourceFormat)
+
+	tests := []struct {
+		name       string
+		origin     string
+		format     string
+		wantFormat string
+	}{
+		{name: "heicToJpeg", origin: types.TypeHEIC, format: types.TypeJPEG, wantFormat: "jpeg"},
+		{name: "heicToPng", origin: types.TypeHEIC, format: types.TypePNG, wantFormat: "png"},
+		{name: "heifToJpeg", origin: types.TypeHEIF, format: types.TypeJPEG, wantFormat: "jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewBuffer(append([]byte{}, source...))
+			err := Transform(file, &types.ResizeOption{OriginFormat: tt.origin, Format: tt.format, Width: sourceCfg.Width / 2})
+			assert.NoError(t, err)
+
+			cfg, format, errDecode := image.DecodeConfig(file)
+			assert.NoError(t, errDecode)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, sourceCfg.Width/2, cfg.Width)
+		})
+	}
+}
+
+func Test_heixBrand(t *testing.T) {
+	source, errRead := os.ReadFile("../fixtures/photo.heic")
+	assert.NoError(t, errRead)
+	copy(source[8:12], "heix")
+	_, format, err := image.DecodeConfig(bytes.NewReader(source))
+	assert.NoError(t, err)
+	assert.Equal(t, "heic", format)
+}
@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	jpegMarkerSOI   = 0xD8
+	jpegMarkerEOI   = 0xD9
+	jpegMarkerSOS   = 0xDA
+	jpegMarkerAPP0  = 0xE0
+	jpegMarkerAPP1  = 0xE1
+	jpegMarkerAPP2  = 0xE2
+	jpegMarkerAPP13 = 0xED
+	jpegMarkerAPP14 = 0xEE
+	jpegMarkerAPP15 = 0xEF
+	jpegMarkerCOM   = 0xFE
+)
+
+type jpegSegment struct {
+	marker byte
+	// payload is the segment data after the length field, nil for markers without payload
+	payload []byte
+	// raw is the whole segment, marker included
+	raw []byte
+}
+
+// splitJPEG returns the marker segments found before the first start of scan, and the offset of
+// that start of scan marker. The offset is -1 when the structure is malformed.
+func splitJPEG(data []byte) ([]jpegSegment, int) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, -1
+	}
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return segments, -1
+		}
+		// any number of 0xFF fill bytes may precede a marker
+		if data[pos+1] == 0xFF {
+			pos++
+			continue
+		}
+		marker := data[pos+1]
+		// markers without payload
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segments = append(segments, jpegSegment{marker: marker, raw: data[pos : pos+2]})
+			pos += 2
+			continue
+		}
+		// start of scan: no metadata after this point
+		if marker == jpegMarkerSOS {
+			return segments, pos
+		}
+		if marker == jpegMarkerEOI {
+			return segments, -1
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return segments, -1
+		}
+		segments = append(segments, jpegSegment{marker: marker, payload: data[pos+4 : pos+2+size], raw: data[pos : pos+2+size]})
+		pos += 2 + size
+	}
+	return segments, -1
+}
+
+// walkJPEGSegments calls fn for every marker segment found before the start of scan, with the
+// segment payload (after the length field). The walk stops when fn returns false or on a
+// malformed segment.
+func walkJPEGSegments(data []byte, fn func(marker byte, payload []byte) bool) {
+	segments, _ := splitJPEG(data)
+	for _, segment := range segments {
+		if segment.payload == nil {
+			continue
+		}
+		if !fn(segment.marker, segment.payload) {
+			return
+		}
+	}
+}
+
+// jpegScanEnd returns the offset right after the end of image marker following the start of scan
+// at sos, or len(data) when it is missing. Entropy-coded data can't contain 0xFFD9, so the first
+// occurrence is the end of the primary image; anything after it (e.g. MPF sub-images) is trailing data.
+func jpegScanEnd(data []byte, sos int) int {
+	eoi := bytes.Index(data[sos:], []byte{0xFF, jpegMarkerEOI})
+	if eoi < 0 {
+		return len(data)
+	}
+	return sos + eoi + 2
+}
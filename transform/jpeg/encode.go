@@ -0,0 +1,282 @@
+package jpeg
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"io"
+)
+
+// DefaultQuality is the default quality encoding parameter.
+const DefaultQuality = 75
+
+// Subsampling is the resolution of the chroma components relative to the luma component.
+type Subsampling int
+
+const (
+	// Subsampling420 halves the chroma resolution in both directions, as image/jpeg does.
+	Subsampling420 Subsampling = iota
+	// Subsampling444 keeps the chroma at full resolution, which keeps sharp colored edges.
+	Subsampling444
+)
+
+// Options are the encoding parameters.
+// Quality ranges from 1 to 100 inclusive, higher is better.
+type Options struct {
+	Quality     int
+	Progressive bool
+	Subsampling Subsampling
+}
+
+// component is a color component of the frame with its quantized blocks.
+type component struct {
+	// h and v are the sampling factors.
+	h, v int
+	q    quantIndex
+	// blocksW and blocksH are the size of the block grid, covering whole MCUs.
+	blocksW, blocksH int
+	// scanW and scanH are the blocks covering the component itself, written by non-interleaved scans.
+	scanW, scanH int
+	// blocks are the quantized coefficients in zig-zag order, row by row.
+	blocks [][blockSize]int16
+}
+
+// scan is a progressive scan of the coefficients ss to se of the given components.
+type scan struct {
+	comps  []int
+	ss, se int
+}
+
+// Progressive scripts use spectral selection only: the DC coefficients of all components come
+// first, then the low frequencies of the luma give a preview before the chroma and the remaining
+// luma frequencies.
+var (
+	scriptYCbCr = []scan{
+		{comps: []int{0, 1, 2}, ss: 0, se: 0},
+		{comps: []int{0}, ss: 1, se: 5},
+		{comps: []int{1}, ss: 1, se: 63},
+		{comps: []int{2}, ss: 1, se: 63},
+		{comps: []int{0}, ss: 6, se: 63},
+	}
+	scriptY = []scan{
+		{comps: []int{0}, ss: 0, se: 0},
+		{comps: []int{0}, ss: 1, se: 5},
+		{comps: []int{0}, ss: 6, se: 63},
+	}
+)
+
+// Encode writes the Image m to w in JPEG format with the given options, a baseline 4:2:0 frame
+// by default. Default parameters are used if a nil *Options is passed.
+func Encode(w io.Writer, m image.Image, o *Options) error {
+	b := m.Bounds()
+	if b.Dx() >= 1<<16 || b.Dy() >= 1<<16 {
+		return errors.New("jpeg: image is too large to encode")
+	}
+	var e encoder
+	if ww, ok := w.(writer); ok {
+		e.w = ww
+	} else {
+		e.w = bufio.NewWriter(w)
+	}
+	options := Options{Quality: DefaultQuality}
+	if o != nil {
+		options = *o
+	}
+	e.initQuant(options.Quality)
+
+	comps := newComponents(m, options.Subsampling)
+	marker := uint8(sof0Marker)
+	if options.Progressive {
+		marker = sof2Marker
+	}
+
+	// Write the Start Of Image marker.
+	e.buf[0] = 0xff
+	e.buf[1] = soiMarker
+	e.write(e.buf[:2])
+	e.writeDQT()
+	e.writeSOF(marker, b.Size(), comps)
+	e.writeDHT(len(comps))
+	if options.Progressive {
+		// Progressive scans need every block before the first one is written.
+		for i := range comps {
+			comps[i].blocks = make([][blockSize]int16, comps[i].blocksW*comps[i].blocksH)
+		}
+		e.transformBlocks(m, comps, func(c, bx, by int, coef *[blockSize]int16) {
+			comps[c].blocks[by*comps[c].blocksW+bx] = *coef
+		})
+		script := scriptYCbCr
+		if len(comps) == 1 {
+			script = scriptY
+		}
+		for _, s := range script {
+			e.writeScan(comps, s)
+		}
+	} else {
+		e.writeSOSHeader(comps, scan{comps: []int{0, 1, 2}[:len(comps)], ss: 0, se: blockSize - 1})
+		prevDC := make([]int32, len(comps))
+		e.transformBlocks(m, comps, func(c, _, _ int, coef *[blockSize]int16) {
+			prevDC[c] = e.writeBlock(coef, comps[c].q, prevDC[c], 0, blockSize-1)
+		})
+		e.padScan()
+	}
+	// Write the End Of Image marker.
+	e.buf[0] = 0xff
+	e.buf[1] = eoiMarker
+	e.write(e.buf[:2])
+	e.flush()
+	return e.err
+}
+
+// initQuant scales the quantization tables to the quality, clipped to [1, 100].
+func (e *encoder) initQuant(quality int) {
+	quality = min(max(quality, 1), 100)
+	// Convert from a quality rating to a scaling factor.
+	var scale int
+	if quality < 50 {
+		scale = 5000 / quality
+	} else {
+		scale = 200 - quality*2
+	}
+	for i := range e.quant {
+		for j := range e.quant[i] {
+			x := (int(unscaledQuant[i][j])*scale + 50) / 100
+			e.quant[i][j] = uint8(min(max(x, 1), 255))
+		}
+	}
+}
+
+// newComponents returns the components of the frame: a single luma component for gray images,
+// otherwise a luma and two chroma components sampled as requested.
+func newComponents(m image.Image, subsampling Subsampling) []component {
+	comps := []component{{h: 1, v: 1, q: quantIndexLuminance}}
+	if _, ok := m.(*image.Gray); !ok {
+		if subsampling == Subsampling420 {
+			comps[0].h, comps[0].v = 2, 2
+		}
+		comps = append(comps,
+			component{h: 1, v: 1, q: quantIndexChrominance},
+			component{h: 1, v: 1, q: quantIndexChrominance},
+		)
+	}
+
+	size := m.Bounds().Size()
+	hMax, vMax := comps[0].h, comps[0].v
+	mcusX, mcusY := ceilDiv(size.X, 8*hMax), ceilDiv(size.Y, 8*vMax)
+	for i := range comps {
+		c := &comps[i]
+		c.blocksW, c.blocksH = mcusX*c.h, mcusY*c.v
+		c.scanW = ceilDiv(ceilDiv(size.X*c.h, hMax), 8)
+		c.scanH = ceilDiv(ceilDiv(size.Y*c.v, vMax), 8)
+	}
+	return comps
+}
+
+// transformBlocks quantizes the blocks of m MCU by MCU, and passes each of them to fn with its
+// component and its position in the block grid, in the order of an interleaved scan.
+func (e *encoder) transformBlocks(m image.Image, comps []component, fn func(c, bx, by int, coef *[blockSize]int16)) {
+	var (
+		// Scratch buffers to hold the YCbCr values.
+		// The blocks are in natural (not zig-zag) order.
+		b      block
+		cb, cr [4]block
+		coef   [blockSize]int16
+	)
+	bounds := m.Bounds()
+	hMax, vMax := comps[0].h, comps[0].v
+	for my, y := 0, bounds.Min.Y; y < bounds.Max.Y; my, y = my+1, y+8*vMax {
+		for mx, x := 0, bounds.Min.X; x < bounds.Max.X; mx, x = mx+1, x+8*hMax {
+			if gray, ok := m.(*image.Gray); ok {
+				grayToY(gray, image.Pt(x, y), &b)
+				e.quantize(&coef, &b, comps[0].q)
+				fn(0, mx, my, &coef)
+				continue
+			}
+			for i := 0; i < hMax*vMax; i++ {
+				xOff, yOff := (i%hMax)*8, (i/hMax)*8
+				imageToYCbCr(m, image.Pt(x+xOff, y+yOff), &b, &cb[i], &cr[i])
+				e.quantize(&coef, &b, comps[0].q)
+				fn(0, mx*hMax+i%hMax, my*vMax+i/hMax, &coef)
+			}
+			for ci, src := range []*[4]block{&cb, &cr} {
+				b = src[0]
+				if hMax == 2 {
+					scale(&b, src)
+				}
+				e.quantize(&coef, &b, comps[ci+1].q)
+				fn(ci+1, mx, my, &coef)
+			}
+		}
+	}
+}
+
+// imageToYCbCr converts the 8x8 region of m whose top-left corner is p to its YCbCr values, with
+// the fast paths of image.RGBA and image.YCbCr images.
+func imageToYCbCr(m image.Image, p image.Point, yBlock, cbBlock, crBlock *block) {
+	switch m := m.(type) {
+	case *image.RGBA:
+		rgbaToYCbCr(m, p, yBlock, cbBlock, crBlock)
+	case *image.YCbCr:
+		yCbCrToYCbCr(m, p, yBlock, cbBlock, crBlock)
+	default:
+		toYCbCr(m, p, yBlock, cbBlock, crBlock)
+	}
+}
+
+// writeSOSHeader writes the Start Of Scan marker of the scan. Luma uses the tables 0 and chroma
+// the tables 1, and successive approximation is not used.
+func (e *encoder) writeSOSHeader(comps []component, s scan) {
+	e.writeMarkerHeader(sosMarker, 6+2*len(s.comps))
+	e.buf[0] = uint8(len(s.comps))
+	for i, c := range s.comps {
+		e.buf[2*i+1] = uint8(c + 1)
+		e.buf[2*i+2] = uint8(comps[c].q)<<4 | uint8(comps[c].q)
+	}
+	n := 2*len(s.comps) + 1
+	e.buf[n] = uint8(s.ss)
+	e.buf[n+1] = uint8(s.se)
+	e.buf[n+2] = 0x00
+	e.write(e.buf[:n+3])
+}
+
+// writeScan writes a progressive scan from the stored blocks. A scan of several components is
+// interleaved MCU by MCU, a scan of one component only covers the blocks of that component.
+func (e *encoder) writeScan(comps []component, s scan) {
+	e.writeSOSHeader(comps, s)
+	prevDC := make([]int32, len(comps))
+	if len(s.comps) > 1 {
+		mcusX, mcusY := comps[0].blocksW/comps[0].h, comps[0].blocksH/comps[0].v
+		for my := 0; my < mcusY; my++ {
+			for mx := 0; mx < mcusX; mx++ {
+				for _, ci := range s.comps {
+					c := &comps[ci]
+					for j := 0; j < c.v; j++ {
+						for i := 0; i < c.h; i++ {
+							coef := &c.blocks[(my*c.v+j)*c.blocksW+mx*c.h+i]
+							prevDC[ci] = e.writeBlock(coef, c.q, prevDC[ci], s.ss, s.se)
+						}
+					}
+				}
+			}
+		}
+	} else {
+		ci := s.comps[0]
+		c := &comps[ci]
+		for by := 0; by < c.scanH; by++ {
+			for bx := 0; bx < c.scanW; bx++ {
+				prevDC[ci] = e.writeBlock(&c.blocks[by*c.blocksW+bx], c.q, prevDC[ci], s.ss, s.se)
+			}
+		}
+	}
+	e.padScan()
+}
+
+// padScan pads the last byte of the scan with 1's, so that the next scan starts on a byte.
+func (e *encoder) padScan() {
+	e.emit(0x7f, 7)
+	e.bits, e.nBits = 0, 0
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,199 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdjpeg "image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stripes returns an image of one pixel wide red and blue columns over a vertical gradient, whose
+// colors are lost by a 4:2:0 chroma subsampling.
+func stripes(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 220, G: uint8(y * 255 / h), B: 30, A: 255}
+			if x%2 == 1 {
+				c.R, c.B = 30, 220
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func gray(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	return img
+}
+
+// frameHeader returns the start of frame marker of a JPEG stream and the sampling factors of its components.
+func frameHeader(t *testing.T, data []byte) (byte, []byte) {
+	for i := 2; i+4 < len(data); {
+		assert.Equal(t, byte(0xff), data[i])
+		marker, length := data[i+1], int(data[i+2])<<8|int(data[i+3])
+		if marker == sof0Marker || marker == sof2Marker {
+			var sampling []byte
+			for c := 0; c < int(data[i+9]); c++ {
+				sampling = append(sampling, data[i+11+3*c])
+			}
+			return marker, sampling
+		}
+		i += 2 + length
+	}
+	t.Fatal("no start of frame marker")
+	return 0, nil
+}
+
+// meanError returns the mean absolute difference of the color channels of two images.
+func meanError(a, b image.Image) float64 {
+	var sum, n float64
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				sum += float64(max(d, -d))
+				n++
+			}
+		}
+	}
+	return sum / n
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name         string
+		img          image.Image
+		opts         *Options
+		wantMarker   byte
+		wantSampling []byte
+	}{
+		{
+			name:         "successDefault",
+			img:          stripes(40, 30),
+			wantMarker:   sof0Marker,
+			wantSampling: []byte{0x22, 0x11, 0x11},
+		},
+		{
+			name:         "successProgressive",
+			img:          stripes(40, 30),
+			opts:         &Options{Quality: 90, Progressive: true},
+			wantMarker:   sof2Marker,
+			wantSampling: []byte{0x22, 0x11, 0x11},
+		},
+		{
+			name:         "successSubsampling444",
+			img:          stripes(40, 30),
+			opts:         &Options{Quality: 90, Subsampling: Subsampling444},
+			wantMarker:   sof0Marker,
+			wantSampling: []byte{0x11, 0x11, 0x11},
+		},
+		{
+			name:         "successProgressiveSubsampling444",
+			img:          stripes(17, 9),
+			opts:         &Options{Quality: 90, Progressive: true, Subsampling: Subsampling444},
+			wantMarker:   sof2Marker,
+			wantSampling: []byte{0x11, 0x11, 0x11},
+		},
+		{
+			name:         "successProgressiveOddSize",
+			img:          stripes(1, 33),
+			opts:         &Options{Quality: 90, Progressive: true},
+			wantMarker:   sof2Marker,
+			wantSampling: []byte{0x22, 0x11, 0x11},
+		},
+		{
+			name:         "successProgressiveGray",
+			img:          gray(21, 13),
+			opts:         &Options{Quality: 90, Progressive: true, Subsampling: Subsampling444},
+			wantMarker:   sof2Marker,
+			wantSampling: []byte{0x11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			assert.NoError(t, Encode(w, tt.img, tt.opts))
+
+			marker, sampling := frameHeader(t, w.Bytes())
+			assert.Equal(t, tt.wantMarker, marker)
+			assert.Equal(t, tt.wantSampling, sampling)
+
+			got, errDecode := stdjpeg.Decode(bytes.NewReader(w.Bytes()))
+			assert.NoError(t, errDecode)
+			assert.Equal(t, tt.img.Bounds(), got.Bounds())
+		})
+	}
+}
+
+func TestEncode_BaselineMatchesStandardLibrary(t *testing.T) {
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 35, 19), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 3)
+	}
+	tests := []struct {
+		name string
+		img  image.Image
+		opts *Options
+	}{
+		{name: "rgba", img: stripes(40, 30), opts: &Options{Quality: 90}},
+		{name: "gray", img: gray(21, 13), opts: &Options{Quality: 50}},
+		{name: "ycbcr", img: ycbcr, opts: &Options{Quality: 20}},
+		{name: "nilOptions", img: stripes(9, 9)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &bytes.Buffer{}
+			var stdOpts *stdjpeg.Options
+			if tt.opts != nil {
+				stdOpts = &stdjpeg.Options{Quality: tt.opts.Quality}
+			}
+			assert.NoError(t, stdjpeg.Encode(want, tt.img, stdOpts))
+
+			got := &bytes.Buffer{}
+			assert.NoError(t, Encode(got, tt.img, tt.opts))
+			assert.Equal(t, want.Bytes(), got.Bytes())
+		})
+	}
+}
+
+func TestEncode_ProgressiveMatchesBaseline(t *testing.T) {
+	for _, subsampling := range []Subsampling{Subsampling420, Subsampling444} {
+		img := stripes(50, 27)
+		baseline, progressive := &bytes.Buffer{}, &bytes.Buffer{}
+		assert.NoError(t, Encode(baseline, img, &Options{Quality: 80, Subsampling: subsampling}))
+		assert.NoError(t, Encode(progressive, img, &Options{Quality: 80, Subsampling: subsampling, Progressive: true}))
+
+		want, errDecode := stdjpeg.Decode(baseline)
+		assert.NoError(t, errDecode)
+		got, errDecode := stdjpeg.Decode(progressive)
+		assert.NoError(t, errDecode)
+		// The decoder may lay out the planes differently, only the pixels are compared
+		assert.Equal(t, want.Bounds(), got.Bounds())
+		assert.Zero(t, meanError(want, got), "subsampling %d", subsampling)
+	}
+}
+
+func TestEncode_Subsampling444KeepsColors(t *testing.T) {
+	img := stripes(32, 32)
+	encoded420, encoded444 := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.NoError(t, Encode(encoded420, img, &Options{Quality: 90}))
+	assert.NoError(t, Encode(encoded444, img, &Options{Quality: 90, Subsampling: Subsampling444}))
+
+	decoded420, errDecode := stdjpeg.Decode(encoded420)
+	assert.NoError(t, errDecode)
+	decoded444, errDecode := stdjpeg.Decode(encoded444)
+	assert.NoError(t, errDecode)
+
+	assert.Greater(t, meanError(img, decoded420), 40.0)
+	assert.Less(t, meanError(img, decoded444), 5.0)
+}
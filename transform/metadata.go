@@ -0,0 +1,526 @@
+package transform
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"slices"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	exifArtistTag    = 0x013B
+	exifCopyrightTag = 0x8298
+	exifTypeShort    = 3
+	exifTypeASCII    = 2
+	// iptcResourceID is the Photoshop image resource holding the IPTC-IIM datasets.
+	iptcResourceID = 0x0404
+	// jpegSegmentMaxPayload is the largest payload a JPEG marker segment can hold.
+	jpegSegmentMaxPayload = 65535 - 2
+	// xmpMaxSize bounds the size of a decompressed PNG XMP packet.
+	xmpMaxSize = 4 << 20
+)
+
+var (
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+	xmpPNGKeyword   = "XML:com.adobe.xmp"
+
+	// iptcCopyrightDatasets lists the IPTC record and dataset numbers kept by the copyright policy:
+	// coded character set, record version, by-line, by-line title, credit, source and copyright notice.
+	iptcCopyrightDatasets = [][2]byte{{1, 90}, {2, 0}, {2, 80}, {2, 85}, {2, 110}, {2, 115}, {2, 116}}
+	// pngCopyrightKeywords lists the PNG text keywords kept by the copyright policy.
+	pngCopyrightKeywords = []string{"Author", "Copyright"}
+	// pngMetadataChunks lists the PNG chunks removed when stripping metadata.
+	pngMetadataChunks = []string{"eXIf", "tEXt", "zTXt", "iTXt", "tIME"}
+	pngTextChunks     = []string{"tEXt", "zTXt", "iTXt"}
+	pngIEND           = []byte("IEND\xaeB`\x82")
+)
+
+// Metadata holds the raw metadata blocks of an image.
+type Metadata struct {
+	// EXIF is a TIFF structure, without the "Exif\0\0" header used in JPEG files
+	EXIF []byte
+	XMP  []byte
+	// IPTC is the list of IPTC-IIM datasets, only read from JPEG files
+	IPTC []byte
+}
+
+func (m Metadata) isEmpty() bool {
+	return len(m.EXIF) == 0 && len(m.XMP) == 0 && len(m.IPTC) == 0
+}
+
+// ReadMetadata returns copies of the EXIF, XMP and IPTC blocks of a JPEG, PNG or WebP file.
+func ReadMetadata(data []byte, format string) Metadata {
+	var metadata Metadata
+	switch format {
+	case types.TypeJPEG:
+		walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+			switch {
+			case marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifHeader) && metadata.EXIF == nil:
+				metadata.EXIF = bytes.Clone(payload[len(exifHeader):])
+			case marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, xmpHeader) && metadata.XMP == nil:
+				metadata.XMP = bytes.Clone(payload[len(xmpHeader):])
+			case marker == jpegMarkerAPP13 && bytes.HasPrefix(payload, photoshopHeader) && metadata.IPTC == nil:
+				metadata.IPTC = bytes.Clone(readPhotoshopResource(payload[len(photoshopHeader):], iptcResourceID))
+			}
+			return true
+		})
+	case types.TypePNG:
+		walkPNGChunks(data, func(_ int, chunkType string, payload []byte) bool {
+			switch chunkType {
+			case "eXIf":
+				metadata.EXIF = bytes.Clone(payload)
+			case "iTXt":
+				if pngTextKeyword(payload) == xmpPNGKeyword {
+					metadata.XMP = readPNGInternationalText(payload)
+				}
+			}
+			return true
+		})
+	case types.TypeWEBP:
+		chunks, errParse := parseWebPChunks(data)
+		if errParse != nil {
+			return metadata
+		}
+		for _, chunk := range chunks {
+			switch chunk.fourCC {
+			case "EXIF":
+				metadata.EXIF = bytes.Clone(bytes.TrimPrefix(chunk.data, exifHeader))
+			case "XMP ":
+				metadata.XMP = bytes.Clone(chunk.data)
+			}
+		}
+	}
+	return metadata
+}
+
+// Filter returns the metadata allowed by the policy. The none and copyright policies only keep the
+// EXIF orientation, plus the artist and copyright for the latter; the orientation is dropped as well
+// when oriented is true, meaning it was already applied to the pixels.
+func (m Metadata) Filter(policy string, oriented bool) Metadata {
+	orientation := 1
+	if !oriented {
+		orientation = exifOrientation(m.EXIF)
+	}
+	switch policy {
+	case types.TypeMetadataKeep:
+		filtered := Metadata{EXIF: m.EXIF, XMP: m.XMP, IPTC: m.IPTC}
+		if oriented {
+			filtered.EXIF = resetExifOrientation(m.EXIF)
+		}
+		return filtered
+	case types.TypeMetadataCopyright:
+		return Metadata{
+			EXIF: buildExif(orientation, exifASCII(m.EXIF, exifArtistTag), exifASCII(m.EXIF, exifCopyrightTag)),
+			IPTC: filterIPTC(m.IPTC, iptcCopyrightDatasets),
+		}
+	}
+	return Metadata{EXIF: buildExif(orientation, "", "")}
+}
+
+// StripMetadata removes the metadata of a JPEG or PNG file without re-encoding the pixels, keeping
+// what the policy allows. The ICC profile and the JPEG JFIF and Adobe segments are kept, as they
+// are needed to render the image. Other formats are returned unchanged.
+func StripMetadata(data []byte, format string, policy string) ([]byte, error) {
+	if policy == "" || policy == types.TypeMetadataKeep {
+		return data, nil
+	}
+	metadata := ReadMetadata(data, format).Filter(policy, false)
+
+	var stripped []byte
+	var errStrip error
+	switch format {
+	case types.TypeJPEG:
+		stripped, errStrip = stripJPEGMetadata(data)
+	case types.TypePNG:
+		stripped, errStrip = stripPNGMetadata(data, policy)
+	default:
+		return data, nil
+	}
+	if errStrip != nil {
+		return nil, errStrip
+	}
+	return embedMetadata(stripped, format, metadata, nil)
+}
+
+// stripJPEGMetadata drops the metadata segments and any data trailing the primary image, such
+// as MPF sub-images which carry their own EXIF.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	segments, sos := splitJPEG(data)
+	if sos < 0 {
+		return nil, errors.New("invalid jpeg")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	for _, segment := range segments {
+		if !isJPEGMetadataSegment(segment) {
+			out = append(out, segment.raw...)
+		}
+	}
+	return append(out, data[sos:jpegScanEnd(data, sos)]...), nil
+}
+
+func isJPEGMetadataSegment(segment jpegSegment) bool {
+	switch {
+	case segment.marker == jpegMarkerAPP2:
+		return !bytes.HasPrefix(segment.payload, jpegICCHeader)
+	case segment.marker == jpegMarkerAPP0, segment.marker == jpegMarkerAPP14:
+		return false
+	}
+	return segment.marker == jpegMarkerCOM || (segment.marker >= jpegMarkerAPP1 && segment.marker <= jpegMarkerAPP15)
+}
+
+// stripPNGMetadata drops the EXIF, text and time chunks. The copyright policy keeps the text chunks
+// holding the author and copyright.
+func stripPNGMetadata(data []byte, policy string) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid png")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	walkPNGChunks(data, func(pos int, chunkType string, payload []byte) bool {
+		keep := !slices.Contains(pngMetadataChunks, chunkType)
+		if policy == types.TypeMetadataCopyright && slices.Contains(pngTextChunks, chunkType) {
+			keep = slices.Contains(pngCopyrightKeywords, pngTextKeyword(payload))
+		}
+		if keep {
+			out = append(out, data[pos:pos+12+len(payload)]...)
+		}
+		return true
+	})
+	if !bytes.HasSuffix(out, pngIEND) {
+		return nil, errors.New("truncated png")
+	}
+	return out, nil
+}
+
+// embedMetadata returns the encoded image with the metadata added. JPEG gets EXIF, XMP and IPTC,
+// PNG and WebP only EXIF and XMP. AVIF and GIF outputs are returned unchanged.
+func embedMetadata(data []byte, format string, metadata Metadata, img image.Image) ([]byte, error) {
+	if metadata.isEmpty() {
+		return data, nil
+	}
+	switch format {
+	case types.TypeJPEG:
+		return embedJPEGMetadata(data, metadata)
+	case types.TypePNG:
+		return embedPNGMetadata(data, metadata)
+	case types.TypeWEBP:
+		var chunks []webpChunk
+		if len(metadata.EXIF) > 0 {
+			chunks = append(chunks, webpChunk{fourCC: "EXIF", data: metadata.EXIF})
+		}
+		if len(metadata.XMP) > 0 {
+			chunks = append(chunks, webpChunk{fourCC: "XMP ", data: metadata.XMP})
+		}
+		if len(chunks) == 0 {
+			return data, nil
+		}
+		return setWebPChunks(data, img, chunks...)
+	}
+	return data, nil
+}
+
+// embedJPEGMetadata inserts the APP1 and APP13 segments after the SOI marker and the JFIF segment.
+// Blocks too large for a single segment are dropped.
+func embedJPEGMetadata(data []byte, metadata Metadata) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, errors.New("invalid jpeg")
+	}
+	insert := 2
+	if len(data) >= 6 && data[2] == 0xFF && data[3] == jpegMarkerAPP0 {
+		insert = min(len(data), 4+int(binary.BigEndian.Uint16(data[4:])))
+	}
+
+	var segments []byte
+	appendSegment := func(marker byte, header, payload []byte) {
+		if len(payload) == 0 || len(header)+len(payload) > jpegSegmentMaxPayload {
+			return
+		}
+		segments = append(segments, 0xFF, marker)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(2+len(header)+len(payload)))
+		segments = append(segments, header...)
+		segments = append(segments, payload...)
+	}
+	appendSegment(jpegMarkerAPP1, exifHeader, metadata.EXIF)
+	appendSegment(jpegMarkerAPP1, xmpHeader, metadata.XMP)
+	if len(metadata.IPTC) > 0 {
+		appendSegment(jpegMarkerAPP13, photoshopHeader, photoshopResource(iptcResourceID, metadata.IPTC))
+	}
+
+	out := make([]byte, 0, len(data)+len(segments))
+	out = append(out, data[:insert]...)
+	out = append(out, segments...)
+	return append(out, data[insert:]...), nil
+}
+
+// embedPNGMetadata inserts the eXIf and XMP iTXt chunks before the image data.
+func embedPNGMetadata(data []byte, metadata Metadata) ([]byte, error) {
+	idat := -1
+	walkPNGChunks(data, func(pos int, chunkType string, _ []byte) bool {
+		if chunkType == "IDAT" {
+			idat = pos
+			return false
+		}
+		return true
+	})
+	if idat < 0 {
+		return nil, errors.New("invalid png")
+	}
+
+	var chunks []byte
+	if len(metadata.EXIF) > 0 {
+		chunks = appendPNGChunk(chunks, "eXIf", metadata.EXIF)
+	}
+	if len(metadata.XMP) > 0 {
+		// keyword, null separator, uncompressed, empty language tag and translated keyword
+		payload := append([]byte(xmpPNGKeyword), 0, 0, 0, 0, 0)
+		chunks = appendPNGChunk(chunks, "iTXt", append(payload, metadata.XMP...))
+	}
+
+	out := make([]byte, 0, len(data)+len(chunks))
+	out = append(out, data[:idat]...)
+	out = append(out, chunks...)
+	return append(out, data[idat:]...), nil
+}
+
+// walkPNGChunks calls fn for every chunk with its offset in the file. The walk stops when fn
+// returns false, after the IEND chunk or on a malformed chunk.
+func walkPNGChunks(data []byte, fn func(pos int, chunkType string, payload []byte) bool) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return
+	}
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if size < 0 || pos+12+size > len(data) || !fn(pos, chunkType, data[pos+8:pos+8+size]) || chunkType == "IEND" {
+			return
+		}
+		pos += 12 + size
+	}
+}
+
+func pngTextKeyword(payload []byte) string {
+	end := bytes.IndexByte(payload, 0)
+	if end < 0 {
+		return ""
+	}
+	return string(payload[:end])
+}
+
+// readPNGInternationalText returns the text of an iTXt chunk, decompressing it when needed.
+func readPNGInternationalText(payload []byte) []byte {
+	// keyword, null separator, compression flag and method
+	keyword := bytes.IndexByte(payload, 0)
+	if keyword < 0 || keyword+3 > len(payload) {
+		return nil
+	}
+	compressed := payload[keyword+1] == 1
+	rest := payload[keyword+3:]
+	// language tag and translated keyword, both null terminated
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil
+		}
+		rest = rest[end+1:]
+	}
+	if !compressed {
+		return bytes.Clone(rest)
+	}
+	reader, errZlib := zlib.NewReader(bytes.NewReader(rest))
+	if errZlib != nil {
+		return nil
+	}
+	defer reader.Close()
+	text, errRead := io.ReadAll(io.LimitReader(reader, xmpMaxSize))
+	if errRead != nil {
+		return nil
+	}
+	return text
+}
+
+// readPhotoshopResource returns the data of an image resource from a list of 8BIM blocks.
+func readPhotoshopResource(data []byte, id uint16) []byte {
+	pos := 0
+	for pos+12 <= len(data) && string(data[pos:pos+4]) == "8BIM" {
+		resourceID := binary.BigEndian.Uint16(data[pos+4:])
+		// pascal string name, padded to an even size
+		nameSize := int(data[pos+6]) + 1
+		nameSize += nameSize % 2
+		sizePos := pos + 6 + nameSize
+		if sizePos+4 > len(data) {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(data[sizePos:]))
+		if size < 0 || sizePos+4+size > len(data) {
+			return nil
+		}
+		if resourceID == id {
+			return data[sizePos+4 : sizePos+4+size]
+		}
+		pos = sizePos + 4 + size + size%2
+	}
+	return nil
+}
+
+// photoshopResource builds a single 8BIM block with an empty name.
+func photoshopResource(id uint16, data []byte) []byte {
+	out := binary.BigEndian.AppendUint16([]byte("8BIM"), id)
+	out = append(out, 0, 0)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// filterIPTC keeps the IPTC-IIM datasets whose record and dataset numbers are listed.
+func filterIPTC(data []byte, keep [][2]byte) []byte {
+	var out []byte
+	pos := 0
+	for pos+5 <= len(data) && data[pos] == 0x1C {
+		size := int(binary.BigEndian.Uint16(data[pos+3:]))
+		header := 5
+		// extended dataset: the low bits give the size of the length field that follows
+		if size&0x8000 != 0 {
+			lengthSize := size & 0x7FFF
+			if lengthSize > 4 || pos+5+lengthSize > len(data) {
+				return out
+			}
+			size = 0
+			for _, b := range data[pos+5 : pos+5+lengthSize] {
+				size = size<<8 | int(b)
+			}
+			header += lengthSize
+		}
+		end := pos + header + size
+		if size < 0 || end > len(data) {
+			return out
+		}
+		if slices.Contains(keep, [2]byte{data[pos+1], data[pos+2]}) {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out
+}
+
+// findExifEntry returns the byte order and the 12 bytes of a tag entry in the first IFD of a TIFF
+// structure, and the TIFF offset of the entry.
+func findExifEntry(tiff []byte, tag uint16) (binary.ByteOrder, []byte, int) {
+	if len(tiff) < 8 {
+		return nil, nil, 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return nil, nil, 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil, nil, 0
+		}
+		if order.Uint16(tiff[entry:]) == tag {
+			return order, tiff[entry : entry+12], entry
+		}
+	}
+	return nil, nil, 0
+}
+
+// exifASCII reads an ASCII tag in the first IFD of a TIFF structure.
+func exifASCII(tiff []byte, tag uint16) string {
+	order, entry, _ := findExifEntry(tiff, tag)
+	if entry == nil || order.Uint16(entry[2:]) != exifTypeASCII {
+		return ""
+	}
+	count := int(order.Uint32(entry[4:]))
+	value := entry[8:12]
+	if count > 4 {
+		offset := int(order.Uint32(entry[8:]))
+		if count < 0 || offset < 0 || offset+count > len(tiff) {
+			return ""
+		}
+		value = tiff[offset : offset+count]
+	}
+	value = value[:min(count, len(value))]
+	return string(bytes.TrimRight(value, "\x00"))
+}
+
+// resetExifOrientation returns a copy of the TIFF structure with the orientation set to 1.
+func resetExifOrientation(tiff []byte) []byte {
+	order, entry, pos := findExifEntry(tiff, exifOrientationTag)
+	if entry == nil {
+		return tiff
+	}
+	reset := bytes.Clone(tiff)
+	order.PutUint16(reset[pos+8:], 1)
+	return reset
+}
+
+// buildExif returns a big-endian TIFF structure holding the orientation, artist and copyright
+// tags that are set, or nil when none is.
+func buildExif(orientation int, artist, copyright string) []byte {
+	type entry struct {
+		tag   uint16
+		kind  uint16
+		value []byte
+	}
+	var entries []entry
+	if orientation > 1 {
+		entries = append(entries, entry{tag: exifOrientationTag, kind: exifTypeShort, value: binary.BigEndian.AppendUint16(nil, uint16(orientation))})
+	}
+	if artist != "" {
+		entries = append(entries, entry{tag: exifArtistTag, kind: exifTypeASCII, value: append([]byte(artist), 0)})
+	}
+	if copyright != "" {
+		entries = append(entries, entry{tag: exifCopyrightTag, kind: exifTypeASCII, value: append([]byte(copyright), 0)})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8}
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(len(entries)))
+	dataOffset := len(tiff) + len(entries)*12 + 4
+	var data []byte
+	for _, e := range entries {
+		count := len(e.value)
+		if e.kind == exifTypeShort {
+			count = len(e.value) / 2
+		}
+		tiff = binary.BigEndian.AppendUint16(tiff, e.tag)
+		tiff = binary.BigEndian.AppendUint16(tiff, e.kind)
+		tiff = binary.BigEndian.AppendUint32(tiff, uint32(count))
+		if len(e.value) <= 4 {
+			tiff = append(tiff, e.value...)
+			tiff = append(tiff, make([]byte, 4-len(e.value))...)
+			continue
+		}
+		tiff = binary.BigEndian.AppendUint32(tiff, uint32(dataOffset+len(data)))
+		data = append(data, e.value...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	// no next IFD
+	tiff = append(tiff, 0, 0, 0, 0)
+	return append(tiff, data...)
+}
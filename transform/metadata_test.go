@@ -0,0 +1,366 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const exifGPSInfoTag = 0x8825
+
+var (
+	testXMP  = []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><exif:GPSLatitude>48,51.4N</exif:GPSLatitude></x:xmpmeta>`)
+	testIPTC = slices.Concat(
+		iptcDataset(2, 0, []byte{0, 4}),
+		iptcDataset(2, 90, []byte("Paris")),
+		iptcDataset(2, 116, []byte("(c) Jane Doe")),
+	)
+)
+
+func iptcDataset(record, number byte, value []byte) []byte {
+	dataset := binary.BigEndian.AppendUint16([]byte{0x1C, record, number}, uint16(len(value)))
+	return append(dataset, value...)
+}
+
+// testExif returns a little-endian TIFF structure with a camera make, orientation, artist, copyright
+// and GPS pointer in its first IFD.
+func testExif(orientation int) []byte {
+	type entry struct {
+		tag   uint16
+		kind  uint16
+		value []byte
+	}
+	entries := []entry{
+		{tag: 0x010F, kind: exifTypeASCII, value: []byte("PhoneMaker\x00")},
+		{tag: exifOrientationTag, kind: exifTypeShort, value: binary.LittleEndian.AppendUint16(nil, uint16(orientation))},
+		{tag: exifArtistTag, kind: exifTypeASCII, value: []byte("Jane Doe\x00")},
+		{tag: exifCopyrightTag, kind: exifTypeASCII, value: []byte("(c) Jane Doe\x00")},
+		{tag: exifGPSInfoTag, kind: 4, value: binary.LittleEndian.AppendUint32(nil, 0)},
+	}
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	tiff = binary.LittleEndian.AppendUint16(tiff, uint16(len(entries)))
+	dataOffset := len(tiff) + len(entries)*12 + 4
+	var data []byte
+	for _, e := range entries {
+		count := 1
+		if e.kind == exifTypeASCII {
+			count = len(e.value)
+		}
+		tiff = binary.LittleEndian.AppendUint16(tiff, e.tag)
+		tiff = binary.LittleEndian.AppendUint16(tiff, e.kind)
+		tiff = binary.LittleEndian.AppendUint32(tiff, uint32(count))
+		if len(e.value) <= 4 {
+			tiff = append(tiff, e.value...)
+			tiff = append(tiff, make([]byte, 4-len(e.value))...)
+			continue
+		}
+		tiff = binary.LittleEndian.AppendUint32(tiff, uint32(dataOffset+len(data)))
+		data = append(data, e.value...)
+	}
+	tiff = append(tiff, 0, 0, 0, 0)
+	return append(tiff, data...)
+}
+
+// testMetadataJPEG returns a JPEG carrying an ICC profile, EXIF, XMP, IPTC, a comment and trailing data.
+func testMetadataJPEG(t *testing.T) []byte {
+	data := encodeTestImage(t, imaging.New(16, 8, color.NRGBA{R: 180, G: 120, B: 100, A: 255}), types.TypeJPEG)
+	data, err := embedJPEGICCProfile(data, []byte("profile"))
+	assert.NoError(t, err)
+	data, err = embedJPEGMetadata(data, Metadata{EXIF: testExif(6), XMP: testXMP, IPTC: testIPTC})
+	assert.NoError(t, err)
+	comment := append([]byte{0xFF, jpegMarkerCOM, 0, 9}, "shot 42"...)
+	data = slices.Concat(data[:2], comment, data[2:])
+	// MPF sub-image after the end of the primary image
+	return append(data, 0xFF, jpegMarkerSOI, 0xFF, jpegMarkerEOI)
+}
+
+func testPNGText(chunkType, keyword, text string) []byte {
+	return appendPNGChunk(nil, chunkType, []byte(keyword+"\x00"+text))
+}
+
+// testMetadataPNG returns a PNG carrying eXIf, XMP and text chunks.
+func testMetadataPNG(t *testing.T) []byte {
+	data := encodeTestImage(t, imaging.New(16, 8, color.White), types.TypePNG)
+	data, err := embedPNGMetadata(data, Metadata{EXIF: testExif(1), XMP: testXMP})
+	assert.NoError(t, err)
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	text := slices.Concat(testPNGText("tEXt", "Author", "Jane Doe"), testPNGText("tEXt", "Location", "Paris"))
+	return slices.Concat(data[:ihdrEnd], text, data[ihdrEnd:])
+}
+
+func TestReadMetadata(t *testing.T) {
+	webpData, err := embedMetadata(encodeTestImage(t, imaging.New(4, 4, color.White), types.TypeWEBP), types.TypeWEBP, Metadata{EXIF: testExif(1), XMP: testXMP}, imaging.New(4, 4, color.White))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		want   Metadata
+	}{
+		{name: "jpeg", data: testMetadataJPEG(t), format: types.TypeJPEG, want: Metadata{EXIF: testExif(6), XMP: testXMP, IPTC: testIPTC}},
+		{name: "png", data: testMetadataPNG(t), format: types.TypePNG, want: Metadata{EXIF: testExif(1), XMP: testXMP}},
+		{name: "webp", data: webpData, format: types.TypeWEBP, want: Metadata{EXIF: testExif(1), XMP: testXMP}},
+		{name: "withoutMetadata", data: encodeTestImage(t, imaging.New(4, 4, color.White), types.TypeJPEG), format: types.TypeJPEG, want: Metadata{}},
+		{name: "invalid", data: []byte("nope"), format: types.TypeWEBP, want: Metadata{}},
+		{name: "unsupportedFormat", data: []byte("GIF89a"), format: types.TypeGIF, want: Metadata{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ReadMetadata(tt.data, tt.format))
+		})
+	}
+}
+
+func TestMetadata_Filter(t *testing.T) {
+	metadata := Metadata{EXIF: testExif(6), XMP: testXMP, IPTC: testIPTC}
+	copyrightIPTC := slices.Concat(iptcDataset(2, 0, []byte{0, 4}), iptcDataset(2, 116, []byte("(c) Jane Doe")))
+
+	tests := []struct {
+		name     string
+		policy   string
+		oriented bool
+		want     Metadata
+	}{
+		{name: "keep", policy: types.TypeMetadataKeep, want: metadata},
+		{name: "keepOriented", policy: types.TypeMetadataKeep, oriented: true, want: Metadata{EXIF: testExif(1), XMP: testXMP, IPTC: testIPTC}},
+		{name: "copyright", policy: types.TypeMetadataCopyright, want: Metadata{EXIF: buildExif(6, "Jane Doe", "(c) Jane Doe"), IPTC: copyrightIPTC}},
+		{name: "copyrightOriented", policy: types.TypeMetadataCopyright, oriented: true, want: Metadata{EXIF: buildExif(1, "Jane Doe", "(c) Jane Doe"), IPTC: copyrightIPTC}},
+		{name: "none", policy: types.TypeMetadataNone, want: Metadata{EXIF: buildExif(6, "", "")}},
+		{name: "noneOriented", policy: types.TypeMetadataNone, oriented: true, want: Metadata{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, metadata.Filter(tt.policy, tt.oriented))
+		})
+	}
+}
+
+func TestStripMetadata_JPEG(t *testing.T) {
+	source := testMetadataJPEG(t)
+
+	tests := []struct {
+		name   string
+		policy string
+		want   Metadata
+	}{
+		{name: "none", policy: types.TypeMetadataNone, want: Metadata{EXIF: buildExif(6, "", "")}},
+		{name: "copyright", policy: types.TypeMetadataCopyright, want: Metadata{
+			EXIF: buildExif(6, "Jane Doe", "(c) Jane Doe"),
+			IPTC: slices.Concat(iptcDataset(2, 0, []byte{0, 4}), iptcDataset(2, 116, []byte("(c) Jane Doe"))),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := StripMetadata(bytes.Clone(source), types.TypeJPEG, tt.policy)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ReadMetadata(stripped, types.TypeJPEG))
+			assert.Equal(t, 6, ReadOrientation(stripped))
+			assert.Equal(t, []byte("profile"), ReadICCProfile(stripped, types.TypeJPEG))
+			assert.NotContains(t, string(stripped), "shot 42")
+			assert.NotContains(t, string(stripped), "PhoneMaker")
+			assert.True(t, bytes.HasSuffix(stripped, []byte{0xFF, jpegMarkerEOI}))
+			assert.Equal(t, 1, bytes.Count(stripped, []byte{0xFF, jpegMarkerSOI}))
+
+			// the scan data is copied as is
+			_, sos := splitJPEG(source)
+			_, strippedSOS := splitJPEG(stripped)
+			assert.Equal(t, source[sos:jpegScanEnd(source, sos)], stripped[strippedSOS:])
+
+			img, errDecode := jpeg.Decode(bytes.NewReader(stripped))
+			assert.NoError(t, errDecode)
+			assert.Equal(t, 16, img.Bounds().Dx())
+		})
+	}
+}
+
+func TestStripMetadata_JPEGFillBytes(t *testing.T) {
+	source := testMetadataJPEG(t)
+	// 0xFF fill bytes before the comment marker
+	source = slices.Concat(source[:2], []byte{0xFF, 0xFF, 0xFF}, source[2:])
+
+	stripped, err := StripMetadata(source, types.TypeJPEG, types.TypeMetadataNone)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(stripped), "shot 42")
+	assert.NotContains(t, string(stripped), "PhoneMaker")
+	assert.Equal(t, 6, ReadOrientation(stripped))
+	assert.Equal(t, []byte("profile"), ReadICCProfile(stripped, types.TypeJPEG))
+	_, errDecode := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, errDecode)
+}
+
+func TestStripMetadata_PNG(t *testing.T) {
+	source := testMetadataPNG(t)
+
+	t.Run("none", func(t *testing.T) {
+		stripped, err := StripMetadata(source, types.TypePNG, types.TypeMetadataNone)
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{}, ReadMetadata(stripped, types.TypePNG))
+		assert.NotContains(t, string(stripped), "Jane Doe")
+		assert.NotContains(t, string(stripped), "Paris")
+		_, errDecode := png.Decode(bytes.NewReader(stripped))
+		assert.NoError(t, errDecode)
+	})
+	t.Run("copyright", func(t *testing.T) {
+		stripped, err := StripMetadata(source, types.TypePNG, types.TypeMetadataCopyright)
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{EXIF: buildExif(1, "Jane Doe", "(c) Jane Doe")}, ReadMetadata(stripped, types.TypePNG))
+		assert.Contains(t, string(stripped), "Author\x00Jane Doe")
+		assert.NotContains(t, string(stripped), "Paris")
+		assert.NotContains(t, string(stripped), "GPSLatitude")
+		_, errDecode := png.Decode(bytes.NewReader(stripped))
+		assert.NoError(t, errDecode)
+	})
+}
+
+func TestStripMetadata_Unchanged(t *testing.T) {
+	source := testMetadataJPEG(t)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		policy string
+	}{
+		{name: "keep", data: source, format: types.TypeJPEG, policy: types.TypeMetadataKeep},
+		{name: "emptyPolicy", data: source, format: types.TypeJPEG, policy: ""},
+		{name: "gif", data: []byte("GIF89a"), format: types.TypeGIF, policy: types.TypeMetadataNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := StripMetadata(tt.data, tt.format, tt.policy)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.data, stripped)
+		})
+	}
+}
+
+func TestStripMetadata_Error(t *testing.T) {
+	_, err := StripMetadata([]byte("nope"), types.TypeJPEG, types.TypeMetadataNone)
+	assert.ErrorContains(t, err, "invalid jpeg")
+	_, err = StripMetadata([]byte("nope"), types.TypePNG, types.TypeMetadataNone)
+	assert.ErrorContains(t, err, "invalid png")
+
+	data := encodeTestImage(t, imaging.New(4, 4, color.White), types.TypePNG)
+	_, err = StripMetadata(data[:len(data)-8], types.TypePNG, types.TypeMetadataNone)
+	assert.ErrorContains(t, err, "truncated png")
+}
+
+func TestTransform_Metadata(t *testing.T) {
+	source := testMetadataJPEG(t)
+
+	tests := []struct {
+		name   string
+		source []byte
+		opts   *types.ResizeOption
+		verify func(t *testing.T, data []byte)
+	}{
+		{
+			name: "emptyPolicyDropsMetadata",
+			opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 8},
+			verify: func(t *testing.T, data []byte) {
+				assert.Equal(t, Metadata{}, ReadMetadata(data, types.TypeJPEG))
+			},
+		},
+		{
+			name: "keepResetsAppliedOrientation",
+			opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeJPEG, Width: 8, Metadata: types.TypeMetadataKeep, AutoOrient: true, Orientation: 6},
+			verify: func(t *testing.T, data []byte) {
+				assert.Equal(t, Metadata{EXIF: testExif(1), XMP: testXMP, IPTC: testIPTC}, ReadMetadata(data, types.TypeJPEG))
+				_, errDecode := jpeg.Decode(bytes.NewReader(data))
+				assert.NoError(t, errDecode)
+			},
+		},
+		{
+			name: "copyrightToWebP",
+			opts: &types.ResizeOption{OriginFormat: types.TypeJPEG, Format: types.TypeWEBP, Width: 8, Metadata: types.TypeMetadataCopyright},
+			verify: func(t *testing.T, data []byte) {
+				assert.Equal(t, Metadata{EXIF: buildExif(6, "Jane Doe", "(c) Jane Doe")}, ReadMetadata(data, types.TypeWEBP))
+			},
+		},
+		{
+			name:   "copyrightPNG",
+			source: testMetadataPNG(t),
+			opts:   &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Width: 8, Metadata: types.TypeMetadataCopyright},
+			verify: func(t *testing.T, data []byte) {
+				assert.Equal(t, Metadata{EXIF: buildExif(1, "Jane Doe", "(c) Jane Doe")}, ReadMetadata(data, types.TypePNG))
+				_, errDecode := png.Decode(bytes.NewReader(data))
+				assert.NoError(t, errDecode)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.source == nil {
+				tt.source = source
+			}
+			file := bytes.NewBuffer(bytes.Clone(tt.source))
+			assert.NoError(t, Transform(file, tt.opts))
+			tt.verify(t, file.Bytes())
+		})
+	}
+}
+
+func Test_setWebPChunks(t *testing.T) {
+	img := imaging.New(16, 8, color.White)
+	data := encodeTestImage(t, img, types.TypeWEBP)
+
+	withExif, err := setWebPChunks(data, img, webpChunk{fourCC: "EXIF", data: []byte("exif")})
+	assert.NoError(t, err)
+	withICC, err := setWebPChunks(withExif, img, webpChunk{fourCC: "ICCP", data: []byte("profile")})
+	assert.NoError(t, err)
+
+	chunks, err := parseWebPChunks(withICC)
+	assert.NoError(t, err)
+	fourCCs := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		fourCCs = append(fourCCs, chunk.fourCC)
+	}
+	assert.Equal(t, "VP8X", fourCCs[0])
+	assert.Equal(t, "ICCP", fourCCs[1])
+	assert.Equal(t, "EXIF", fourCCs[len(fourCCs)-1])
+	assert.Equal(t, byte(webpFlagICC|webpFlagEXIF), chunks[0].data[0])
+}
+
+func Test_filterIPTC(t *testing.T) {
+	extended := slices.Concat([]byte{0x1C, 2, 202, 0x80, 0x02, 0x00, 0x03}, []byte("abc"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{name: "copyrightKept", data: testIPTC, want: slices.Concat(iptcDataset(2, 0, []byte{0, 4}), iptcDataset(2, 116, []byte("(c) Jane Doe")))},
+		{name: "extendedDatasetSkipped", data: slices.Concat(extended, iptcDataset(2, 116, []byte("c"))), want: iptcDataset(2, 116, []byte("c"))},
+		{name: "truncated", data: testIPTC[:len(testIPTC)-2], want: iptcDataset(2, 0, []byte{0, 4})},
+		{name: "empty", data: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, filterIPTC(tt.data, iptcCopyrightDatasets))
+		})
+	}
+}
+
+func Test_exifASCII(t *testing.T) {
+	tiff := testExif(1)
+	assert.Equal(t, "Jane Doe", exifASCII(tiff, exifArtistTag))
+	assert.Equal(t, "(c) Jane Doe", exifASCII(tiff, exifCopyrightTag))
+	assert.Equal(t, "", exifASCII(tiff, 0x0110))
+	// not an ASCII tag
+	assert.Equal(t, "", exifASCII(tiff, exifOrientationTag))
+
+	built := buildExif(3, "Al", "(c)")
+	assert.Equal(t, 3, exifOrientation(built))
+	assert.Equal(t, "Al", exifASCII(built, exifArtistTag))
+	assert.Equal(t, "(c)", exifASCII(built, exifCopyrightTag))
+	assert.Nil(t, buildExif(1, "", ""))
+}
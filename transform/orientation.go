@@ -0,0 +1,112 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	exifOrientationTag = 0x0112
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// ReadOrientation returns the EXIF orientation (1 to 8) of a JPEG, or 1 when it is missing or invalid.
+func ReadOrientation(data []byte) int {
+	orientation := 1
+	walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			orientation = exifOrientation(payload[len(exifHeader):])
+			return false
+		}
+		return true
+	})
+	return orientation
+}
+
+// exifOrientation reads the orientation tag in the first IFD of a TIFF structure.
+func exifOrientation(tiff []byte) int {
+	order, entry, _ := findExifEntry(tiff, exifOrientationTag)
+	if entry == nil {
+		return 1
+	}
+	orientation := int(order.Uint16(entry[8:]))
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// orientedSize returns the size of a width x height image once oriented by Orient.
+func orientedSize(width, height int, opts *types.ResizeOption) image.Point {
+	transposed := opts.AutoOrient && opts.Orientation >= 5
+	if opts.Rotate == 90 || opts.Rotate == 270 {
+		transposed = !transposed
+	}
+	if transposed {
+		return image.Pt(height, width)
+	}
+	return image.Pt(width, height)
+}
+
+// ValidateRotate checks that the rotation requested in opts is a quarter turn.
+func ValidateRotate(opts *types.ResizeOption) error {
+	switch opts.Rotate {
+	case 0, 90, 180, 270:
+		return nil
+	}
+	return fmt.Errorf("invalid rotate: %d", opts.Rotate)
+}
+
+// Orient fixes the EXIF orientation when auto-orientation is enabled, then applies the
+// requested clockwise rotation and flip. The art direction hints, set on the image as displayed,
+// are turned with it.
+func Orient(img image.Image, opts *types.ResizeOption) image.Image {
+	if opts.AutoOrient {
+		img = applyExifOrientation(img, opts.Orientation)
+	}
+	opts.ArtDirection = orientArtDirection(opts.ArtDirection, img.Bounds().Size(), opts)
+
+	switch opts.Rotate {
+	case 90:
+		img = imaging.Rotate270(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate90(img)
+	}
+
+	switch opts.Flip {
+	case types.TypeFlipHorizontal:
+		img = imaging.FlipH(img)
+	case types.TypeFlipVertical:
+		img = imaging.FlipV(img)
+	case types.TypeFlipBoth:
+		img = imaging.Rotate180(img)
+	}
+	return img
+}
+
+func applyExifOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// exifJPEG wraps a TIFF structure in a minimal JPEG APP1 segment.
+func exifJPEG(tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(payload) + 2
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(size >> 8), byte(size)}
+	data = append(data, payload...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestReadOrientation(t *testing.T) {
+	fixture, errRead := os.ReadFile("../fixtures/orientation-6.jpg")
+	assert.NoError(t, errRead)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "fixture", data: fixture, want: 6},
+		{name: "bigEndian", data: exifJPEG([]byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 3, 0, 0, 0, 0, 0, 0}), want: 3},
+		{name: "littleEndian", data: exifJPEG([]byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0}), want: 8},
+		{name: "outOfRange", data: exifJPEG([]byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0}), want: 1},
+		{name: "otherTag", data: exifJPEG([]byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x10, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0, 0}), want: 1},
+		{name: "truncatedIFD", data: exifJPEG([]byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01}), want: 1},
+		{name: "invalidByteOrder", data: exifJPEG([]byte{'X', 'X', 42, 0, 8, 0, 0, 0, 0, 0}), want: 1},
+		{name: "withoutExif", data: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, want: 1},
+		{name: "notJPEG", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "empty", data: nil, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ReadOrientation(tt.data))
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2x1 image: red on the left, blue on the right
+	img := imaging.New(2, 1, color.NRGBA{R: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{B: 255, A: 255})
+
+	tests := []struct {
+		name string
+		opts *types.ResizeOption
+		want image.Image
+	}{
+		{name: "nothing", opts: &types.ResizeOption{}, want: img},
+		{name: "orientationIgnoredWithoutAutoOrient", opts: &types.ResizeOption{Orientation: 6}, want: img},
+		{name: "autoOrient6", opts: &types.ResizeOption{AutoOrient: true, Orientation: 6}, want: imaging.Rotate270(img)},
+		{name: "autoOrient8", opts: &types.ResizeOption{AutoOrient: true, Orientation: 8}, want: imaging.Rotate90(img)},
+		{name: "autoOrient2", opts: &types.ResizeOption{AutoOrient: true, Orientation: 2}, want: imaging.FlipH(img)},
+		{name: "rotate90", opts: &types.ResizeOption{Rotate: 90}, want: imaging.Rotate270(img)},
+		{name: "rotate180", opts: &types.ResizeOption{Rotate: 180}, want: imaging.Rotate180(img)},
+		{name: "rotate270", opts: &types.ResizeOption{Rotate: 270}, want: imaging.Rotate90(img)},
+		{name: "rotateInvalid", opts: &types.ResizeOption{Rotate: 45}, want: img},
+		{name: "flipH", opts: &types.ResizeOption{Flip: types.TypeFlipHorizontal}, want: imaging.FlipH(img)},
+		{name: "flipV", opts: &types.ResizeOption{Flip: types.TypeFlipVertical}, want: imaging.FlipV(img)},
+		{name: "flipHV", opts: &types.ResizeOption{Flip: types.TypeFlipBoth}, want: imaging.FlipV(imaging.FlipH(img))},
+		{name: "autoOrientThenRotate", opts: &types.ResizeOption{AutoOrient: true, Orientation: 6, Rotate: 270}, want: img},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Orient(img, tt.opts))
+			assert.Equal(t, tt.want.Bounds().Size(), orientedSize(2, 1, tt.opts))
+		})
+	}
+}
+
+func TestValidateRotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rotate  int
+		wantErr string
+	}{
+		{name: "none", rotate: 0},
+		{name: "quarterTurn", rotate: 90},
+		{name: "halfTurn", rotate: 180},
+		{name: "threeQuarterTurn", rotate: 270},
+		{name: "failedNotQuarterTurn", rotate: 45, wantErr: "invalid rotate: 45"},
+		{name: "failedFullTurn", rotate: 360, wantErr: "invalid rotate: 360"},
+		{name: "failedNegative", rotate: -90, wantErr: "invalid rotate: -90"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRotate(&types.ResizeOption{Rotate: tt.rotate})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRotate90IsClockwise(t *testing.T) {
+	img := imaging.New(2, 1, color.NRGBA{R: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{B: 255, A: 255})
+
+	got := imaging.Clone(Orient(img, &types.ResizeOption{Rotate: 90}))
+	assert.Equal(t, image.Rect(0, 0, 1, 2), got.Bounds())
+	// the left (red) pixel ends up on top after a clockwise quarter turn
+	assert.Equal(t, color.NRGBA{R: 255, A: 255}, got.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{B: 255, A: 255}, got.NRGBAAt(0, 1))
+}
@@ -0,0 +1,23 @@
+package transform
+
+import (
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+var resampleFilters = map[string]imaging.ResampleFilter{
+	types.TypeResampleNearest:    imaging.NearestNeighbor,
+	types.TypeResampleBox:        imaging.Box,
+	types.TypeResampleLinear:     imaging.Linear,
+	types.TypeResampleCatmullRom: imaging.CatmullRom,
+	types.TypeResampleMitchell:   imaging.MitchellNetravali,
+	types.TypeResampleLanczos:    imaging.Lanczos,
+}
+
+// resampleFilter returns the filter requested by opts.Resample, Lanczos when it is empty or unknown.
+func resampleFilter(opts *types.ResizeOption) imaging.ResampleFilter {
+	if filter, ok := resampleFilters[opts.Resample]; ok {
+		return filter
+	}
+	return imaging.Lanczos
+}
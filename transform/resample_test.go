@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_resampleFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		resample    string
+		wantSupport float64
+	}{
+		{name: "default", resample: "", wantSupport: imaging.Lanczos.Support},
+		{name: "unknown", resample: "bicubic", wantSupport: imaging.Lanczos.Support},
+		{name: "nearest", resample: types.TypeResampleNearest, wantSupport: imaging.NearestNeighbor.Support},
+		{name: "box", resample: types.TypeResampleBox, wantSupport: imaging.Box.Support},
+		{name: "linear", resample: types.TypeResampleLinear, wantSupport: imaging.Linear.Support},
+		{name: "catmullrom", resample: types.TypeResampleCatmullRom, wantSupport: imaging.CatmullRom.Support},
+		{name: "mitchell", resample: types.TypeResampleMitchell, wantSupport: imaging.MitchellNetravali.Support},
+		{name: "lanczos", resample: types.TypeResampleLanczos, wantSupport: imaging.Lanczos.Support},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantSupport, resampleFilter(&types.ResizeOption{Resample: tt.resample}).Support)
+		})
+	}
+}
+
+func TestTransform_Resample(t *testing.T) {
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	// 2x1 image: red on the left, blue on the right
+	source := imaging.New(2, 1, red)
+	source.SetNRGBA(1, 0, blue)
+
+	tests := []struct {
+		name       string
+		opts       *types.ResizeOption
+		wantPixels []color.NRGBA
+	}{
+		{
+			name:       "nearestKeepsHardEdges",
+			opts:       &types.ResizeOption{Width: 8, Height: 1, Fit: types.TypeResize, Resample: types.TypeResampleNearest},
+			wantPixels: []color.NRGBA{red, red, red, red, blue, blue, blue, blue},
+		},
+		{
+			name:       "nearestWithPad",
+			opts:       &types.ResizeOption{Width: 4, Height: 4, Fit: types.TypeFitPad, Resample: types.TypeResampleNearest},
+			wantPixels: []color.NRGBA{{}, {}, {}, {}, red, red, blue, blue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.OriginFormat, tt.opts.Format = types.TypePNG, types.TypePNG
+			file := bytes.NewBuffer(encodeTestImage(t, source, types.TypePNG))
+			assert.NoError(t, Transform(file, tt.opts))
+			img, _, errDecode := image.Decode(file)
+			assert.NoError(t, errDecode)
+			got := imaging.Clone(img)
+			pixels := make([]color.NRGBA, 0, len(tt.wantPixels))
+			for i := range tt.wantPixels {
+				pixels = append(pixels, got.NRGBAAt(i%got.Rect.Dx(), i/got.Rect.Dx()))
+			}
+			assert.Equal(t, tt.wantPixels, pixels)
+		})
+	}
+
+	t.Run("lanczosBlends", func(t *testing.T) {
+		file := bytes.NewBuffer(encodeTestImage(t, source, types.TypePNG))
+		assert.NoError(t, Transform(file, &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Width: 8, Height: 1, Fit: types.TypeResize}))
+		img, _, errDecode := image.Decode(file)
+		assert.NoError(t, errDecode)
+		middle := imaging.Clone(img).NRGBAAt(3, 0)
+		assert.NotEqual(t, red, middle)
+		assert.NotEqual(t, blue, middle)
+	})
+}
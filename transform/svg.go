@@ -0,0 +1,148 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/reflet-devops/go-media-resizer/config"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+)
+
+var svgURLRegexp = regexp.MustCompile(`url\(\s*['"]?([^)'"]*)`)
+
+// ValidateSVG checks that the SVG source only references its own elements, and that the size it is rendered at for
+// opts fits in the source limit. The render size is chosen by the request, so it is limited even when the source
+// limit mode is off, by the default maximum dimensions when none are configured.
+func ValidateSVG(data *bytes.Buffer, opts *types.ResizeOption, sourceLimit config.SourceLimitConfig) error {
+	if errRefs := checkSVGReferences(data.Bytes()); errRefs != nil {
+		return errRefs
+	}
+	icon, errRead := oksvg.ReadIconStream(bytes.NewReader(data.Bytes()), oksvg.IgnoreErrorMode)
+	if errRead != nil {
+		return fmt.Errorf("failed to read svg: %w", errRead)
+	}
+	width, height, errSize := svgRenderSize(icon, opts)
+	if errSize != nil {
+		return errSize
+	}
+
+	maxWidth, maxHeight := sourceLimit.MaxWidth, sourceLimit.MaxHeight
+	if maxWidth == 0 {
+		maxWidth = config.DefaultMaxSourceWidth
+	}
+	if maxHeight == 0 {
+		maxHeight = config.DefaultMaxSourceHeight
+	}
+	if width > maxWidth || height > maxHeight {
+		return fmt.Errorf("svg render dimensions %dx%d exceed maximum allowed %dx%d", width, height, maxWidth, maxHeight)
+	}
+	return nil
+}
+
+// checkSVGReferences refuses entity declarations, stylesheets and links which aren't fragments of the document itself,
+// so that rendering never reads another file or URL.
+func checkSVGReferences(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	inStyle := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read svg: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.Directive:
+			if bytes.Contains(t, []byte("ENTITY")) {
+				return errors.New("svg entity declarations are not allowed")
+			}
+		case xml.ProcInst:
+			if t.Target == "xml-stylesheet" {
+				return errors.New("svg stylesheets are not allowed")
+			}
+		case xml.StartElement:
+			inStyle = t.Name.Local == "style"
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "href" && !strings.HasPrefix(strings.TrimSpace(attr.Value), "#") {
+					return fmt.Errorf("svg external reference not allowed: %s", attr.Value)
+				}
+				if errURL := checkSVGURLs(attr.Value); errURL != nil {
+					return errURL
+				}
+			}
+		case xml.EndElement:
+			inStyle = false
+		case xml.CharData:
+			if !inStyle {
+				continue
+			}
+			if bytes.Contains(t, []byte("@import")) {
+				return errors.New("svg stylesheet imports are not allowed")
+			}
+			if errURL := checkSVGURLs(string(t)); errURL != nil {
+				return errURL
+			}
+		}
+	}
+}
+
+// checkSVGURLs refuses the url() values of styles and paints which aren't fragments of the document itself.
+func checkSVGURLs(value string) error {
+	for _, match := range svgURLRegexp.FindAllStringSubmatch(value, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			return fmt.Errorf("svg external reference not allowed: %s", match[1])
+		}
+	}
+	return nil
+}
+
+// svgRenderSize returns the size the icon is rendered at: its own size by default, scaled to cover the requested
+// width and height multiplied by the dpr, so that the resize which follows never enlarges the rendered image.
+func svgRenderSize(icon *oksvg.SvgIcon, opts *types.ResizeOption) (int, int, error) {
+	viewW, viewH := icon.ViewBox.W, icon.ViewBox.H
+	if viewW <= 0 || viewH <= 0 {
+		return 0, 0, errors.New("svg has neither a viewBox nor a width and a height")
+	}
+
+	dpr := math.Max(opts.Dpr, 1)
+	scale := 1.0
+	switch width, height := float64(opts.Width)*dpr, float64(opts.Height)*dpr; {
+	case width > 0 && height > 0:
+		scale = math.Max(width/viewW, height/viewH)
+	case width > 0:
+		scale = width / viewW
+	case height > 0:
+		scale = height / viewH
+	}
+	return max(1, int(math.Ceil(viewW*scale))), max(1, int(math.Ceil(viewH*scale))), nil
+}
+
+// RasterizeSVG renders the SVG source at the size given by svgRenderSize, on a transparent background. The source is
+// expected to be checked by ValidateSVG beforehand.
+func RasterizeSVG(data []byte, opts *types.ResizeOption) (image.Image, error) {
+	icon, errRead := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if errRead != nil {
+		return nil, fmt.Errorf("failed to read svg: %w", errRead)
+	}
+	width, height, errSize := svgRenderSize(icon, opts)
+	if errSize != nil {
+		return nil, errSize
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+	return img, nil
+}
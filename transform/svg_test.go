@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/reflet-devops/go-media-resizer/config"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// redSquare is a 40x20 document with a red square on its left half.
+const redSquare = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"><rect x="0" y="0" width="20" height="20" fill="#ff0000"/></svg>`
+
+func TestValidateSVG(t *testing.T) {
+	tests := []struct {
+		name        string
+		svg         string
+		opts        *types.ResizeOption
+		sourceLimit config.SourceLimitConfig
+		wantErr     string
+	}{
+		{
+			name: "success",
+			svg:  redSquare,
+			opts: &types.ResizeOption{Width: 400},
+		},
+		{
+			name: "successWithFragmentReferences",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><defs><linearGradient id="g"/><rect id="r" width="5" height="5"/></defs><use xlink:href="#r"/><rect width="10" height="10" fill="url(#g)"/></svg>`,
+			opts: &types.ResizeOption{},
+		},
+		{
+			name:    "failedWithExternalHref",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><image href="https://example.com/a.png" width="10" height="10"/></svg>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg external reference not allowed: https://example.com/a.png",
+		},
+		{
+			name:    "failedWithExternalXlinkHref",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><use xlink:href="file:///etc/passwd#x"/></svg>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg external reference not allowed: file:///etc/passwd#x",
+		},
+		{
+			name:    "failedWithExternalURL",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect width="10" height="10" style="fill: url('https://example.com/p.svg#g')"/></svg>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg external reference not allowed: https://example.com/p.svg#g",
+		},
+		{
+			name:    "failedWithStyleImport",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><style>@import "https://example.com/s.css";</style></svg>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg stylesheet imports are not allowed",
+		},
+		{
+			name:    "failedWithStylesheet",
+			svg:     `<?xml-stylesheet href="https://example.com/s.css"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"/>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg stylesheets are not allowed",
+		},
+		{
+			name:    "failedWithEntity",
+			svg:     `<!DOCTYPE svg [<!ENTITY x SYSTEM "file:///etc/passwd">]><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"/>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg entity declarations are not allowed",
+		},
+		{
+			name:    "failedWithoutSize",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg"><rect width="10" height="10"/></svg>`,
+			opts:    &types.ResizeOption{},
+			wantErr: "svg has neither a viewBox nor a width and a height",
+		},
+		{
+			name:    "failedWithDefaultLimit",
+			svg:     redSquare,
+			opts:    &types.ResizeOption{Width: 5000},
+			wantErr: "svg render dimensions 5000x2500 exceed maximum allowed 4096x4096",
+		},
+		{
+			name:        "failedWithSourceLimit",
+			svg:         redSquare,
+			opts:        &types.ResizeOption{Width: 200, Dpr: 2},
+			sourceLimit: config.SourceLimitConfig{Mode: config.SourceLimitModeError, MaxWidth: 300, MaxHeight: 300},
+			wantErr:     "svg render dimensions 400x200 exceed maximum allowed 300x300",
+		},
+		{
+			name:    "failedToRead",
+			svg:     `<svg`,
+			opts:    &types.ResizeOption{},
+			wantErr: "failed to read svg: XML syntax error on line 1: unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSVG(bytes.NewBufferString(tt.svg), tt.opts, tt.sourceLimit)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRasterizeSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *types.ResizeOption
+		wantSize image.Point
+	}{
+		{name: "ownSize", opts: &types.ResizeOption{}, wantSize: image.Pt(40, 20)},
+		{name: "width", opts: &types.ResizeOption{Width: 100}, wantSize: image.Pt(100, 50)},
+		{name: "height", opts: &types.ResizeOption{Height: 100}, wantSize: image.Pt(200, 100)},
+		{name: "coverWidthAndHeight", opts: &types.ResizeOption{Width: 30, Height: 30}, wantSize: image.Pt(60, 30)},
+		{name: "dpr", opts: &types.ResizeOption{Width: 100, Dpr: 2}, wantSize: image.Pt(200, 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := RasterizeSVG([]byte(redSquare), tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			// the square is drawn on the left half, the right half stays transparent
+			r, g, b, a := img.At(tt.wantSize.X/4, tt.wantSize.Y/2).RGBA()
+			assert.Equal(t, [4]uint32{0xffff, 0, 0, 0xffff}, [4]uint32{r, g, b, a})
+			_, _, _, a = img.At(tt.wantSize.X*3/4, tt.wantSize.Y/2).RGBA()
+			assert.Zero(t, a)
+		})
+	}
+
+	t.Run("failedToRead", func(t *testing.T) {
+		_, err := RasterizeSVG([]byte(`<svg`), &types.ResizeOption{})
+		assert.Error(t, err)
+	})
+}
+
+func TestTransform_SVG(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       *types.ResizeOption
+		wantFormat string
+		wantSize   image.Point
+	}{
+		{
+			name:       "toPng",
+			opts:       &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypePNG, Width: 64},
+			wantFormat: "png",
+			wantSize:   image.Pt(64, 32),
+		},
+		{
+			name:       "toJpegCover",
+			opts:       &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeJPEG, Width: 30, Height: 30, Fit: types.TypeFitCover},
+			wantFormat: "jpeg",
+			wantSize:   image.Pt(30, 30),
+		},
+		{
+			name:       "toWebpOwnSize",
+			opts:       &types.ResizeOption{OriginFormat: types.TypeSVG, Format: types.TypeWEBP},
+			wantFormat: "webp",
+			wantSize:   image.Pt(40, 20),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewBufferString(redSquare)
+			assert.NoError(t, Transform(file, tt.opts))
+
+			cfg, format, err := image.DecodeConfig(file)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, tt.wantSize, image.Pt(cfg.Width, cfg.Height))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/config"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const DefaultTextSize = 32
+
+// minTextSize is the font size below which the text is no longer readable, and skipped.
+const minTextSize = 6
+
+var (
+	DefaultTextColor = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// bundledFonts are compiled into the binary and parsed on first use.
+	bundledFonts = map[string]func() (*opentype.Font, error){
+		types.TypeFontGoRegular: sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(goregular.TTF) }),
+		types.TypeFontGoBold:    sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(gobold.TTF) }),
+		types.TypeFontGoItalic:  sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(goitalic.TTF) }),
+		types.TypeFontGoMono:    sync.OnceValues(func() (*opentype.Font, error) { return opentype.Parse(gomono.TTF) }),
+	}
+)
+
+// BundledFont returns one of the fonts compiled into the binary.
+func BundledFont(name string) (*opentype.Font, error) {
+	parse, ok := bundledFonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font %s", name)
+	}
+	return parse()
+}
+
+// ValidateText checks the text overlay requested in opts against the text configuration, and sets
+// the default font when the request has none.
+func ValidateText(opts *types.ResizeOption, textCfg config.TextConfig) error {
+	if opts.Text == "" {
+		return nil
+	}
+	if !textCfg.Enabled {
+		return errors.New("text overlay is not enabled")
+	}
+	if maxLength := textCfg.GetMaxLength(); utf8.RuneCountInString(opts.Text) > maxLength {
+		return fmt.Errorf("text exceeds %d characters", maxLength)
+	}
+	if maxSize := textCfg.GetMaxSize(); opts.TextSize < 0 || opts.TextSize > maxSize {
+		return fmt.Errorf("text size must be between 1 and %d", maxSize)
+	}
+
+	fonts := textCfg.GetFonts()
+	if opts.TextFont == "" {
+		opts.TextFont = fonts[0]
+	}
+	if !slices.Contains(fonts, opts.TextFont) {
+		return fmt.Errorf("font not allowed: %s", opts.TextFont)
+	}
+
+	if _, ok := gravityAnchors[opts.TextPosition]; opts.TextPosition != "" && !ok {
+		return fmt.Errorf("invalid text position: %s", opts.TextPosition)
+	}
+	for _, value := range []string{opts.TextColor, opts.TextShadow} {
+		if _, errColor := ParseColor(value); value != "" && errColor != nil {
+			return errColor
+		}
+	}
+	return nil
+}
+
+// DrawText renders opts.Text on a single line at its position anchor, centered by default, with a
+// margin of half the font size. The font size is reduced when the text doesn't fit inside the
+// margins, and the text is skipped when it would become too small to read. The shadow, when set, is offset by
+// a sixteenth of the font size.
+func DrawText(img image.Image, opts *types.ResizeOption) image.Image {
+	size := float64(opts.TextSize)
+	if size == 0 {
+		size = DefaultTextSize
+	}
+	bounds := img.Bounds()
+
+	face, width, height, errFace := newTextFace(opts, size)
+	if errFace != nil {
+		return img
+	}
+	// the text and its margins grow linearly with the font size
+	if scale := math.Min(float64(bounds.Dx())/(width+size), float64(bounds.Dy())/(height+size)); scale < 1 {
+		_ = face.Close()
+		size = math.Floor(size * scale)
+		if size < minTextSize {
+			return img
+		}
+		face, width, height, errFace = newTextFace(opts, size)
+		if errFace != nil {
+			return img
+		}
+	}
+	defer func() { _ = face.Close() }()
+
+	margin := math.Round(size / 2)
+	maxW, maxH := float64(bounds.Dx())-2*margin, float64(bounds.Dy())-2*margin
+	if width > maxW || height > maxH {
+		return img
+	}
+
+	fx, fy := 0.5, 0.5
+	if anchor, ok := gravityAnchors[opts.TextPosition]; ok {
+		fx, fy = anchor[0], anchor[1]
+	}
+	x := int(margin + math.Round(fx*(maxW-width)))
+	y := int(margin+math.Round(fy*(maxH-height))) + face.Metrics().Ascent.Ceil()
+
+	dst := imaging.Clone(img)
+	drawer := &font.Drawer{Dst: dst, Face: face}
+	if shadow, errShadow := ParseColor(opts.TextShadow); opts.TextShadow != "" && errShadow == nil {
+		offset := int(math.Max(1, math.Round(size/16)))
+		drawer.Src = image.NewUniform(shadow)
+		drawer.Dot = fixed.P(x+offset, y+offset)
+		drawer.DrawString(opts.Text)
+	}
+	textColor := DefaultTextColor
+	if parsed, errColor := ParseColor(opts.TextColor); opts.TextColor != "" && errColor == nil {
+		textColor = parsed
+	}
+	drawer.Src = image.NewUniform(textColor)
+	drawer.Dot = fixed.P(x, y)
+	drawer.DrawString(opts.Text)
+	return dst
+}
+
+// newTextFace returns the face of opts.Font at size pixels, with the width and line height of opts.Text.
+func newTextFace(opts *types.ResizeOption, size float64) (font.Face, float64, float64, error) {
+	face, errFace := opentype.NewFace(opts.Font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if errFace != nil {
+		return nil, 0, 0, errFace
+	}
+	metrics := face.Metrics()
+	width := float64(font.MeasureString(face, opts.Text).Ceil())
+	height := float64((metrics.Ascent + metrics.Descent).Ceil())
+	return face, width, height, nil
+}
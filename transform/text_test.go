@@ -0,0 +1,162 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/config"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// inkBounds returns the bounds of the pixels that differ from the background.
+func inkBounds(img *image.NRGBA, background color.NRGBA) image.Rectangle {
+	ink := image.Rectangle{}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.NRGBAAt(x, y) != background {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return ink
+}
+
+func TestBundledFont(t *testing.T) {
+	for _, name := range []string{types.TypeFontGoRegular, types.TypeFontGoBold, types.TypeFontGoItalic, types.TypeFontGoMono} {
+		font, err := BundledFont(name)
+		assert.NoError(t, err, name)
+		assert.Greater(t, font.NumGlyphs(), 0, name)
+	}
+
+	_, err := BundledFont("comic")
+	assert.ErrorContains(t, err, "unknown font comic")
+}
+
+func TestValidateText(t *testing.T) {
+	enabled := config.TextConfig{Enabled: true}
+
+	tests := []struct {
+		name     string
+		opts     types.ResizeOption
+		textCfg  config.TextConfig
+		wantFont string
+		wantErr  string
+	}{
+		{name: "withoutText", opts: types.ResizeOption{}, textCfg: config.TextConfig{}},
+		{name: "defaultFont", opts: types.ResizeOption{Text: "SOLD"}, textCfg: enabled, wantFont: types.TypeFontGoRegular},
+		{name: "firstAllowedFont", opts: types.ResizeOption{Text: "SOLD"}, textCfg: config.TextConfig{Enabled: true, Fonts: []string{"brand", types.TypeFontGoBold}}, wantFont: "brand"},
+		{name: "allowedFont", opts: types.ResizeOption{Text: "SOLD", TextFont: types.TypeFontGoBold}, textCfg: config.TextConfig{Enabled: true, Fonts: []string{"brand", types.TypeFontGoBold}}, wantFont: types.TypeFontGoBold},
+		{name: "colorsAndPosition", opts: types.ResizeOption{Text: "SOLD", TextColor: "#f00", TextShadow: "00000080", TextPosition: types.TypeGravityTopLeft}, textCfg: enabled, wantFont: types.TypeFontGoRegular},
+		{name: "maxLength", opts: types.ResizeOption{Text: strings.Repeat("é", config.DefaultTextMaxLength)}, textCfg: enabled, wantFont: types.TypeFontGoRegular},
+		{name: "failedDisabled", opts: types.ResizeOption{Text: "SOLD"}, textCfg: config.TextConfig{}, wantErr: "text overlay is not enabled"},
+		{name: "failedTooLong", opts: types.ResizeOption{Text: strings.Repeat("a", config.DefaultTextMaxLength+1)}, textCfg: enabled, wantErr: "text exceeds 64 characters"},
+		{name: "failedTooLongCustom", opts: types.ResizeOption{Text: "SOLD"}, textCfg: config.TextConfig{Enabled: true, MaxLength: 3}, wantErr: "text exceeds 3 characters"},
+		{name: "failedSizeTooLarge", opts: types.ResizeOption{Text: "SOLD", TextSize: 201}, textCfg: enabled, wantErr: "text size must be between 1 and 200"},
+		{name: "failedSizeTooLargeCustom", opts: types.ResizeOption{Text: "SOLD", TextSize: 50}, textCfg: config.TextConfig{Enabled: true, MaxSize: 40}, wantErr: "text size must be between 1 and 40"},
+		{name: "failedFontNotAllowed", opts: types.ResizeOption{Text: "SOLD", TextFont: types.TypeFontGoMono}, textCfg: enabled, wantErr: "font not allowed: gomono"},
+		{name: "failedPosition", opts: types.ResizeOption{Text: "SOLD", TextPosition: "middle"}, textCfg: enabled, wantErr: "invalid text position: middle"},
+		{name: "failedColor", opts: types.ResizeOption{Text: "SOLD", TextColor: "red"}, textCfg: enabled, wantErr: "invalid color: red"},
+		{name: "failedShadow", opts: types.ResizeOption{Text: "SOLD", TextShadow: "12345"}, textCfg: enabled, wantErr: "invalid color: 12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateText(&tt.opts, tt.textCfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFont, tt.opts.TextFont)
+		})
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	font, errFont := BundledFont(types.TypeFontGoBold)
+	assert.NoError(t, errFont)
+
+	tests := []struct {
+		name string
+		opts types.ResizeOption
+		// wantInk is the area the text must stay in
+		wantInk image.Rectangle
+		// size of the image, 200x100 when empty
+		size    image.Point
+		wantNil bool
+	}{
+		{
+			name:    "centered",
+			opts:    types.ResizeOption{Text: "SOLD", TextColor: "000"},
+			wantInk: image.Rect(50, 25, 150, 75),
+		},
+		{
+			name:    "topLeftWithMargin",
+			opts:    types.ResizeOption{Text: "SOLD", TextColor: "000", TextSize: 20, TextPosition: types.TypeGravityTopLeft},
+			wantInk: image.Rect(10, 10, 70, 40),
+		},
+		{
+			name:    "bottomRightWithShadow",
+			opts:    types.ResizeOption{Text: "SOLD", TextColor: "f00", TextShadow: "000", TextSize: 32, TextPosition: types.TypeGravityBottomRight},
+			wantInk: image.Rect(90, 40, 190, 90),
+		},
+		{
+			name:    "shrunkToFit",
+			opts:    types.ResizeOption{Text: "A much longer caption", TextColor: "000", TextSize: 100},
+			wantInk: image.Rect(0, 25, 200, 75),
+		},
+		{
+			name:    "imageTooSmall",
+			opts:    types.ResizeOption{Text: "SOLD", TextColor: "000", TextSize: 120},
+			size:    image.Pt(20, 10),
+			wantNil: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Font = font
+			if tt.size.Eq(image.Point{}) {
+				tt.size = image.Pt(200, 100)
+			}
+			source := imaging.New(tt.size.X, tt.size.Y, white)
+			got := DrawText(source, &tt.opts)
+			if tt.wantNil {
+				assert.Same(t, image.Image(source), got)
+				return
+			}
+			ink := inkBounds(got.(*image.NRGBA), white)
+			assert.False(t, ink.Empty())
+			assert.True(t, ink.In(tt.wantInk), "ink %v outside %v", ink, tt.wantInk)
+		})
+	}
+
+	t.Run("shadowColor", func(t *testing.T) {
+		opts := &types.ResizeOption{Text: "I", TextColor: "f00", TextShadow: "00f", TextSize: 64, Font: font}
+		got := DrawText(imaging.New(200, 100, white), opts).(*image.NRGBA)
+		colors := map[color.NRGBA]bool{}
+		for i := 0; i < len(got.Pix); i += 4 {
+			colors[color.NRGBA{R: got.Pix[i], G: got.Pix[i+1], B: got.Pix[i+2], A: got.Pix[i+3]}] = true
+		}
+		assert.True(t, colors[color.NRGBA{R: 255, A: 255}])
+		assert.True(t, colors[color.NRGBA{B: 255, A: 255}])
+	})
+}
+
+func TestTransform_Text(t *testing.T) {
+	font, errFont := BundledFont(types.TypeFontGoRegular)
+	assert.NoError(t, errFont)
+	buffer := bytes.NewBuffer(encodeTestImage(t, imaging.New(200, 100, color.White), types.TypePNG))
+	opts := &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Text: "Hello", TextColor: "000", Font: font}
+
+	assert.NoError(t, Transform(buffer, opts))
+	img, _, errDecode := image.Decode(buffer)
+	assert.NoError(t, errDecode)
+	ink := inkBounds(imaging.Clone(img), color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	assert.False(t, ink.Empty())
+	assert.True(t, ink.In(image.Rect(40, 25, 160, 75)), "ink %v", ink)
+}
@@ -0,0 +1,65 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// ValidatePage checks that the page requested in opts exists in multi-page TIFF sources.
+func ValidatePage(data *bytes.Buffer, opts *types.ResizeOption) error {
+	_, err := sourcePage(data.Bytes(), opts)
+	return err
+}
+
+// sourcePage returns the data to decode for opts: the requested page of multi-page TIFF sources, the data itself
+// otherwise.
+func sourcePage(data []byte, opts *types.ResizeOption) ([]byte, error) {
+	if opts.OriginFormat != types.TypeTIFF {
+		return data, nil
+	}
+	return tiffPage(data, opts.Page)
+}
+
+// tiffPage returns a copy of the TIFF data whose first image file directory is the one of the page, counted from 1.
+// TIFF decoders only read the first directory, so this selects the decoded page. The data is returned unchanged for
+// the first page and for data which isn't TIFF, and pages which don't exist are an error.
+func tiffPage(data []byte, page int) ([]byte, error) {
+	if page <= 1 || len(data) < 8 {
+		return data, nil
+	}
+	var order binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return data, nil
+	}
+
+	errMissing := fmt.Errorf("page %d not found", page)
+	offset := int64(order.Uint32(data[4:]))
+	// a directory linking back to a previous one would loop forever
+	seen := map[int64]bool{}
+	for i := 1; i < page; i++ {
+		if offset == 0 || seen[offset] || offset+2 > int64(len(data)) {
+			return nil, errMissing
+		}
+		seen[offset] = true
+		next := offset + 2 + 12*int64(order.Uint16(data[offset:]))
+		if next+4 > int64(len(data)) {
+			return nil, errMissing
+		}
+		offset = int64(order.Uint32(data[next:]))
+	}
+	if offset == 0 || offset+2 > int64(len(data)) {
+		return nil, errMissing
+	}
+
+	paged := bytes.Clone(data)
+	order.PutUint32(paged[4:], uint32(offset))
+	return paged, nil
+}
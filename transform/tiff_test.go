@@ -0,0 +1,167 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+)
+
+// multiPageTIFF writes uncompressed gray pages in a little-endian TIFF, each image file directory linking to the next
+// one.
+func multiPageTIFF(pages ...*image.Gray) []byte {
+	const entries = 8
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+	for i, page := range pages {
+		size := page.Bounds().Size()
+		ifdOffset := len(data)
+		pixelsOffset := ifdOffset + 2 + 12*entries + 4
+		next := 0
+		if i < len(pages)-1 {
+			next = pixelsOffset + len(page.Pix)
+		}
+		data = binary.LittleEndian.AppendUint16(data, entries)
+		for _, entry := range [entries][3]int{
+			{256, 4, size.X},        // ImageWidth
+			{257, 4, size.Y},        // ImageLength
+			{258, 3, 8},             // BitsPerSample
+			{259, 3, 1},             // Compression: none
+			{262, 3, 1},             // PhotometricInterpretation: black is zero
+			{273, 4, pixelsOffset},  // StripOffsets
+			{278, 4, size.Y},        // RowsPerStrip
+			{279, 4, len(page.Pix)}, // StripByteCounts
+		} {
+			data = binary.LittleEndian.AppendUint16(data, uint16(entry[0]))
+			data = binary.LittleEndian.AppendUint16(data, uint16(entry[1]))
+			data = binary.LittleEndian.AppendUint32(data, 1)
+			if entry[1] == 3 {
+				data = binary.LittleEndian.AppendUint16(data, uint16(entry[2]))
+				data = append(data, 0, 0)
+			} else {
+				data = binary.LittleEndian.AppendUint32(data, uint32(entry[2]))
+			}
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(next))
+		data = append(data, page.Pix...)
+	}
+	return data
+}
+
+func Test_tiffPage(t *testing.T) {
+	data := multiPageTIFF(image.NewGray(image.Rect(0, 0, 4, 2)), image.NewGray(image.Rect(0, 0, 6, 3)))
+	looping := bytes.Clone(data)
+	// the last directory links back to the first one
+	binary.LittleEndian.PutUint32(looping[len(looping)-18-4:], 8)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		page     int
+		wantSize image.Point
+		wantErr  string
+	}{
+		{name: "defaultPage", data: data, page: 0, wantSize: image.Pt(4, 2)},
+		{name: "firstPage", data: data, page: 1, wantSize: image.Pt(4, 2)},
+		{name: "secondPage", data: data, page: 2, wantSize: image.Pt(6, 3)},
+		{name: "missingPage", data: data, page: 3, wantErr: "page 3 not found"},
+		{name: "loopingDirectories", data: looping, page: 100, wantErr: "page 100 not found"},
+		{name: "truncated", data: data[:20], page: 2, wantErr: "page 2 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged, errPage := tiffPage(tt.data, tt.page)
+			if tt.wantErr != "" {
+				assert.EqualError(t, errPage, tt.wantErr)
+				return
+			}
+			assert.NoError(t, errPage)
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(paged))
+			assert.NoError(t, err)
+			assert.Equal(t, "tiff", format)
+			assert.Equal(t, tt.wantSize, image.Pt(cfg.Width, cfg.Height))
+		})
+	}
+
+	t.Run("notTIFF", func(t *testing.T) {
+		paged, err := tiffPage([]byte("GIF89a.."), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("GIF89a.."), paged)
+	})
+	t.Run("sourceUnchanged", func(t *testing.T) {
+		original := bytes.Clone(data)
+		_, _ = tiffPage(data, 2)
+		assert.Equal(t, original, data)
+	})
+}
+
+func TestValidatePage(t *testing.T) {
+	data := multiPageTIFF(image.NewGray(image.Rect(0, 0, 4, 2)), image.NewGray(image.Rect(0, 0, 6, 3)))
+
+	assert.NoError(t, ValidatePage(bytes.NewBuffer(data), &types.ResizeOption{OriginFormat: types.TypeTIFF, Page: 2}))
+	assert.EqualError(t, ValidatePage(bytes.NewBuffer(data), &types.ResizeOption{OriginFormat: types.TypeTIFF, Page: 3}), "page 3 not found")
+	// other formats ignore the page
+	assert.NoError(t, ValidatePage(bytes.NewBuffer(data), &types.ResizeOption{OriginFormat: types.TypePNG, Page: 3}))
+}
+
+func TestTransform_TIFFAndBMP(t *testing.T) {
+	bmpData := &bytes.Buffer{}
+	assert.NoError(t, bmp.Encode(bmpData, image.NewGray(image.Rect(0, 0, 40, 20))))
+	tiffData := multiPageTIFF(image.NewGray(image.Rect(0, 0, 40, 20)), image.NewGray(image.Rect(0, 0, 20, 40)))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		opts       *types.ResizeOption
+		wantFormat string
+		wantSize   image.Point
+	}{
+		{
+			name:       "tiffToJpeg",
+			data:       tiffData,
+			opts:       &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeJPEG, Width: 20},
+			wantFormat: "jpeg",
+			wantSize:   image.Pt(20, 10),
+		},
+		{
+			name:       "tiffSecondPageToPng",
+			data:       tiffData,
+			opts:       &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypePNG, Width: 10, Page: 2},
+			wantFormat: "png",
+			wantSize:   image.Pt(10, 20),
+		},
+		{
+			name:       "tiffToWebp",
+			data:       tiffData,
+			opts:       &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypeWEBP},
+			wantFormat: "webp",
+			wantSize:   image.Pt(40, 20),
+		},
+		{
+			name:       "bmpToJpeg",
+			data:       bmpData.Bytes(),
+			opts:       &types.ResizeOption{OriginFormat: types.TypeBMP, Format: types.TypeJPEG, Height: 10},
+			wantFormat: "jpeg",
+			wantSize:   image.Pt(20, 10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := bytes.NewBuffer(bytes.Clone(tt.data))
+			assert.NoError(t, Transform(file, tt.opts))
+
+			cfg, format, err := image.DecodeConfig(file)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, tt.wantSize, image.Pt(cfg.Width, cfg.Height))
+		})
+	}
+	t.Run("tiffMissingPage", func(t *testing.T) {
+		err := Transform(bytes.NewBuffer(bytes.Clone(tiffData)), &types.ResizeOption{OriginFormat: types.TypeTIFF, Format: types.TypePNG, Page: 3, Source: "catalog.tiff"})
+		assert.EqualError(t, err, "failed to decode image catalog.tiff: page 3 not found")
+	})
+}
@@ -0,0 +1,119 @@
+package transform
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// DefaultTrimTolerance is the largest channel difference with the corner color still counted as
+// border by trim=auto, when the request has no tolerance.
+const DefaultTrimTolerance = 10
+
+// Trim removes the borders requested by opts.Trim: the uniform borders with auto, or the
+// top;right;bottom;left widths in pixels. Invalid values, and trims that would leave nothing, are
+// ignored. The art direction hints are moved to stay on the same pixels.
+func Trim(img image.Image, opts *types.ResizeOption) image.Image {
+	bounds := img.Bounds()
+	rect, ok := trimRect(img, opts)
+	if !ok || rect.Empty() || rect == bounds {
+		return img
+	}
+	opts.ArtDirection = shiftArtDirection(opts.ArtDirection, rect.Min.Sub(bounds.Min), bounds.Size(), rect.Size())
+	return imaging.Crop(img, rect)
+}
+
+// trimRect returns the part of img kept by opts.Trim, in the img coordinates.
+func trimRect(img image.Image, opts *types.ResizeOption) (image.Rectangle, bool) {
+	if opts.Trim == types.TypeTrimAuto {
+		tolerance := DefaultTrimTolerance
+		if opts.TrimTolerance > 0 {
+			tolerance = opts.TrimTolerance
+		}
+		return autoTrimRect(img, tolerance), true
+	}
+
+	parts := strings.Split(opts.Trim, ";")
+	if len(parts) != 4 {
+		return image.Rectangle{}, false
+	}
+	var sides [4]int
+	for i, part := range parts {
+		side, errSide := strconv.Atoi(strings.TrimSpace(part))
+		if errSide != nil || side < 0 {
+			return image.Rectangle{}, false
+		}
+		sides[i] = side
+	}
+	bounds := img.Bounds()
+	// image.Rect would swap the sides of a trim wider or taller than img
+	if sides[3]+sides[1] >= bounds.Dx() || sides[0]+sides[2] >= bounds.Dy() {
+		return image.Rectangle{}, false
+	}
+	return image.Rect(bounds.Min.X+sides[3], bounds.Min.Y+sides[0], bounds.Max.X-sides[1], bounds.Max.Y-sides[2]), true
+}
+
+// autoTrimRect returns the bounds of img without its borders of the top-left corner color. Each
+// row or column is trimmed only when all its pixels are within tolerance of that color.
+func autoTrimRect(img image.Image, tolerance int) image.Rectangle {
+	src := imaging.Clone(img)
+	reference := src.NRGBAAt(0, 0)
+	isBorder := func(x0, y0, x1, y1 int) bool {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if !colorWithin(src.NRGBAAt(x, y), reference, tolerance) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	top, bottom, left, right := 0, height, 0, width
+	for top < bottom && isBorder(0, top, width, top+1) {
+		top++
+	}
+	// a uniform image has no content to keep
+	if top == bottom {
+		return img.Bounds()
+	}
+	for bottom > top && isBorder(0, bottom-1, width, bottom) {
+		bottom--
+	}
+	for left < right && isBorder(left, top, left+1, bottom) {
+		left++
+	}
+	for right > left && isBorder(right-1, top, right, bottom) {
+		right--
+	}
+	return image.Rect(left, top, right, bottom).Add(img.Bounds().Min)
+}
+
+// colorWithin reports whether every channel of c is within tolerance of reference. Fully
+// transparent pixels match each other whatever their color.
+func colorWithin(c, reference color.NRGBA, tolerance int) bool {
+	if c.A == 0 && reference.A == 0 {
+		return true
+	}
+	within := func(a, b uint8) bool {
+		return max(a, b)-min(a, b) <= uint8(min(tolerance, 255))
+	}
+	return within(c.R, reference.R) && within(c.G, reference.G) && within(c.B, reference.B) && within(c.A, reference.A)
+}
+
+// stabilizeTrim resolves trim=auto once, on the first frame, into explicit widths so every frame of
+// an animation is trimmed the same way.
+func stabilizeTrim(img image.Image, opts *types.ResizeOption) {
+	if opts.Trim != types.TypeTrimAuto {
+		return
+	}
+	bounds := img.Bounds()
+	rect, _ := trimRect(img, opts)
+	opts.Trim = fmt.Sprintf("%d;%d;%d;%d", rect.Min.Y-bounds.Min.Y, bounds.Max.X-rect.Max.X, bounds.Max.Y-rect.Max.Y, rect.Min.X-bounds.Min.X)
+}
@@ -0,0 +1,134 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// framed returns a 40x30 image with a 20x10 red content at (8,6), on a background which has a
+// slightly different pixel at (0,29).
+func framed(background color.NRGBA) *image.NRGBA {
+	img := imaging.New(40, 30, background)
+	img.SetNRGBA(0, 29, color.NRGBA{R: background.R - 6, G: background.G, B: background.B, A: background.A})
+	return imaging.Paste(img, imaging.New(20, 10, color.NRGBA{R: 255, A: 255}), image.Pt(8, 6))
+}
+
+func TestTrim(t *testing.T) {
+	background := color.NRGBA{R: 240, G: 240, B: 240, A: 255}
+
+	tests := []struct {
+		name   string
+		source image.Image
+		opts   types.ResizeOption
+		want   image.Rectangle
+	}{
+		{name: "auto", source: framed(background), opts: types.ResizeOption{Trim: types.TypeTrimAuto}, want: image.Rect(0, 0, 20, 10)},
+		{name: "autoToleranceTooLow", source: framed(background), opts: types.ResizeOption{Trim: types.TypeTrimAuto, TrimTolerance: 5}, want: image.Rect(0, 0, 28, 24)},
+		{name: "autoTransparent", source: framed(color.NRGBA{}), opts: types.ResizeOption{Trim: types.TypeTrimAuto}, want: image.Rect(0, 0, 20, 10)},
+		{name: "autoUniform", source: imaging.New(40, 30, background), opts: types.ResizeOption{Trim: types.TypeTrimAuto}, want: image.Rect(0, 0, 40, 30)},
+		{name: "explicit", source: framed(background), opts: types.ResizeOption{Trim: "1;2;3;4"}, want: image.Rect(0, 0, 34, 26)},
+		{name: "explicitWithSpaces", source: framed(background), opts: types.ResizeOption{Trim: "1; 2; 3; 4"}, want: image.Rect(0, 0, 34, 26)},
+		{name: "invalidCount", source: framed(background), opts: types.ResizeOption{Trim: "1;2;3"}, want: image.Rect(0, 0, 40, 30)},
+		{name: "invalidNegative", source: framed(background), opts: types.ResizeOption{Trim: "1;-2;3;4"}, want: image.Rect(0, 0, 40, 30)},
+		{name: "invalidValue", source: framed(background), opts: types.ResizeOption{Trim: "top"}, want: image.Rect(0, 0, 40, 30)},
+		{name: "tooLarge", source: framed(background), opts: types.ResizeOption{Trim: "15;0;15;0"}, want: image.Rect(0, 0, 40, 30)},
+		{name: "crossingWidth", source: framed(background), opts: types.ResizeOption{Trim: "0;30;0;30"}, want: image.Rect(0, 0, 40, 30)},
+		{name: "crossingHeight", source: framed(background), opts: types.ResizeOption{Trim: "20;0;20;0"}, want: image.Rect(0, 0, 40, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Trim(tt.source, &tt.opts)
+			assert.Equal(t, tt.want, got.Bounds())
+		})
+	}
+
+	t.Run("autoKeepsContent", func(t *testing.T) {
+		got := imaging.Clone(Trim(framed(background), &types.ResizeOption{Trim: types.TypeTrimAuto}))
+		assert.Equal(t, color.NRGBA{R: 255, A: 255}, got.NRGBAAt(0, 0))
+		assert.Equal(t, color.NRGBA{R: 255, A: 255}, got.NRGBAAt(19, 9))
+	})
+}
+
+func Test_trimRect_Crossing(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+
+	_, ok := trimRect(img, &types.ResizeOption{Trim: "0;80;0;80"})
+	assert.False(t, ok)
+	_, ok = trimRect(img, &types.ResizeOption{Trim: "25;0;25;0"})
+	assert.False(t, ok)
+	rect, ok := trimRect(img, &types.ResizeOption{Trim: "0;49;0;50"})
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(50, 0, 51, 50), rect)
+}
+
+func TestTrim_ArtDirection(t *testing.T) {
+	opts := &types.ResizeOption{
+		Trim: "10;0;0;20",
+		ArtDirection: &types.ArtDirection{
+			FocalPoint: &types.FocalPoint{X: 0.5, Y: 0.5},
+			SafeArea:   &types.Rect{X: 25, Y: 12, Width: 10, Height: 10},
+			Crops:      map[string]types.Rect{"1:1": {X: 20, Y: 10, Width: 20, Height: 20}},
+		},
+	}
+	original := opts.ArtDirection
+
+	got := Trim(imaging.New(40, 30, color.White), opts)
+	assert.Equal(t, image.Rect(0, 0, 20, 20), got.Bounds())
+	assert.Equal(t, &types.ArtDirection{
+		FocalPoint: &types.FocalPoint{X: 0, Y: 0.25},
+		SafeArea:   &types.Rect{X: 5, Y: 2, Width: 10, Height: 10},
+		Crops:      map[string]types.Rect{"1:1": {X: 0, Y: 0, Width: 20, Height: 20}},
+	}, opts.ArtDirection)
+	// the sidecar may be cached, it is never modified
+	assert.Equal(t, 25, original.SafeArea.X)
+}
+
+func Test_colorWithin(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         color.NRGBA
+		reference color.NRGBA
+		tolerance int
+		want      bool
+	}{
+		{name: "equal", c: color.NRGBA{R: 10, G: 20, B: 30, A: 255}, reference: color.NRGBA{R: 10, G: 20, B: 30, A: 255}, want: true},
+		{name: "withinTolerance", c: color.NRGBA{R: 0, G: 30, B: 30, A: 250}, reference: color.NRGBA{R: 10, G: 20, B: 30, A: 255}, tolerance: 10, want: true},
+		{name: "outsideTolerance", c: color.NRGBA{R: 21, G: 20, B: 30, A: 255}, reference: color.NRGBA{R: 10, G: 20, B: 30, A: 255}, tolerance: 10, want: false},
+		{name: "alphaOutsideTolerance", c: color.NRGBA{R: 10, G: 20, B: 30, A: 200}, reference: color.NRGBA{R: 10, G: 20, B: 30, A: 255}, tolerance: 10, want: false},
+		{name: "transparent", c: color.NRGBA{R: 255}, reference: color.NRGBA{}, want: true},
+		{name: "largeTolerance", c: color.NRGBA{}, reference: color.NRGBA{R: 255, G: 255, B: 255, A: 255}, tolerance: 1000, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, colorWithin(tt.c, tt.reference, tt.tolerance))
+		})
+	}
+}
+
+func Test_stabilizeTrim(t *testing.T) {
+	opts := &types.ResizeOption{Trim: types.TypeTrimAuto}
+	stabilizeTrim(framed(color.NRGBA{R: 240, G: 240, B: 240, A: 255}), opts)
+	assert.Equal(t, "6;12;14;8", opts.Trim)
+
+	opts = &types.ResizeOption{Trim: "1;2;3;4"}
+	stabilizeTrim(framed(color.NRGBA{}), opts)
+	assert.Equal(t, "1;2;3;4", opts.Trim)
+}
+
+func TestTransform_Trim(t *testing.T) {
+	file := bytes.NewBuffer(encodeTestImage(t, framed(color.NRGBA{R: 240, G: 240, B: 240, A: 255}), types.TypePNG))
+	opts := &types.ResizeOption{OriginFormat: types.TypePNG, Format: types.TypePNG, Trim: types.TypeTrimAuto, Width: 10}
+
+	assert.NoError(t, Transform(file, opts))
+	img, _, errDecode := image.Decode(file)
+	assert.NoError(t, errDecode)
+	// the width applies to the trimmed content
+	assert.Equal(t, image.Rect(0, 0, 10, 5), img.Bounds())
+	assertColorInDelta(t, color.NRGBA{R: 255, A: 255}, img.At(0, 0), 0)
+}
@@ -0,0 +1,46 @@
+package transform
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+// Watermark composites the overlay onto img. The overlay is scaled relative to the output width,
+// shrunk to fit inside the margins when needed, then placed at its position anchor.
+func Watermark(img image.Image, watermark *types.Watermark) image.Image {
+	bounds := img.Bounds()
+	markBounds := watermark.Image.Bounds()
+	maxW, maxH := bounds.Dx()-2*watermark.Margin, bounds.Dy()-2*watermark.Margin
+	if maxW < 1 || maxH < 1 || markBounds.Empty() {
+		return img
+	}
+
+	width, height := markBounds.Dx(), markBounds.Dy()
+	if watermark.Scale > 0 {
+		width = int(math.Round(watermark.Scale * float64(bounds.Dx())))
+		height = int(math.Round(float64(width) * float64(markBounds.Dy()) / float64(markBounds.Dx())))
+	}
+	if width > maxW || height > maxH {
+		width, height = fitProportional(width, height, maxW, maxH)
+	}
+	if width < 1 || height < 1 {
+		return img
+	}
+
+	mark := watermark.Image
+	if width != markBounds.Dx() || height != markBounds.Dy() {
+		mark = imaging.Resize(mark, width, height, imaging.Lanczos)
+	}
+
+	fx, fy := 1.0, 1.0
+	if anchor, ok := gravityAnchors[watermark.Position]; ok {
+		fx, fy = anchor[0], anchor[1]
+	}
+	x := watermark.Margin + int(math.Round(fx*float64(maxW-width)))
+	y := watermark.Margin + int(math.Round(fy*float64(maxH-height)))
+
+	return imaging.Overlay(img, mark, image.Pt(x, y), watermark.Opacity)
+}
@@ -0,0 +1,94 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatermark(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	mark := imaging.New(10, 5, red)
+
+	tests := []struct {
+		name      string
+		watermark *types.Watermark
+		// wantRect is where the watermark lands in the 100x50 output
+		wantRect image.Rectangle
+	}{
+		{
+			name:      "defaultBottomRight",
+			watermark: &types.Watermark{Image: mark, Opacity: 1},
+			wantRect:  image.Rect(90, 45, 100, 50),
+		},
+		{
+			name:      "topLeftWithMargin",
+			watermark: &types.Watermark{Image: mark, Opacity: 1, Position: types.TypeGravityTopLeft, Margin: 4},
+			wantRect:  image.Rect(4, 4, 14, 9),
+		},
+		{
+			name:      "centerScaled",
+			watermark: &types.Watermark{Image: mark, Opacity: 1, Position: types.TypeGravityCenter, Scale: 0.4},
+			wantRect:  image.Rect(30, 15, 70, 35),
+		},
+		{
+			name:      "shrunkToFitMargins",
+			watermark: &types.Watermark{Image: mark, Opacity: 1, Position: types.TypeGravityBottomRight, Margin: 10, Scale: 1},
+			wantRect:  image.Rect(30, 10, 90, 40),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Watermark(imaging.New(100, 50, white), tt.watermark).(*image.NRGBA)
+			assert.Equal(t, image.Rect(0, 0, 100, 50), got.Bounds())
+			// check inside and just outside each corner of the expected rectangle
+			inner := tt.wantRect.Inset(1)
+			assert.Equal(t, red, got.NRGBAAt(inner.Min.X, inner.Min.Y))
+			assert.Equal(t, red, got.NRGBAAt(inner.Max.X-1, inner.Max.Y-1))
+			if tt.wantRect.Min.X > 0 {
+				assert.Equal(t, white, got.NRGBAAt(tt.wantRect.Min.X-1, tt.wantRect.Min.Y+1))
+			}
+			if tt.wantRect.Max.Y < 50 {
+				assert.Equal(t, white, got.NRGBAAt(tt.wantRect.Min.X+1, tt.wantRect.Max.Y))
+			}
+		})
+	}
+
+	t.Run("opacity", func(t *testing.T) {
+		got := Watermark(imaging.New(100, 50, white), &types.Watermark{Image: mark, Opacity: 0.5}).(*image.NRGBA)
+		c := got.NRGBAAt(95, 47)
+		assert.Equal(t, uint8(255), c.R)
+		assert.InDelta(t, 128, int(c.G), 1)
+	})
+	t.Run("transparent", func(t *testing.T) {
+		got := imaging.Clone(Watermark(imaging.New(100, 50, white), &types.Watermark{Image: mark}))
+		assert.Equal(t, white, got.NRGBAAt(95, 47))
+	})
+	t.Run("tooSmallOutputUnchanged", func(t *testing.T) {
+		img := imaging.New(10, 10, white)
+		assert.Same(t, img, Watermark(img, &types.Watermark{Image: mark, Margin: 5}))
+	})
+}
+
+func TestTransform_Watermark(t *testing.T) {
+	source := encodeTestImage(t, imaging.New(64, 32, color.White), types.TypePNG)
+	opts := &types.ResizeOption{
+		OriginFormat:   types.TypePNG,
+		Format:         types.TypePNG,
+		WatermarkImage: &types.Watermark{Image: imaging.New(8, 8, color.Black), Opacity: 1, Position: types.TypeGravityTopLeft},
+	}
+	assert.True(t, opts.NeedTransform())
+
+	file := bytes.NewBuffer(source)
+	assert.NoError(t, Transform(file, opts))
+	img, errDecode := imaging.Decode(file)
+	assert.NoError(t, errDecode)
+	assert.Equal(t, color.NRGBA{A: 255}, color.NRGBAModel.Convert(img.At(2, 2)))
+	assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBAModel.Convert(img.At(20, 20)))
+}
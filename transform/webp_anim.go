@@ -0,0 +1,315 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+
+	"github.com/kolesa-team/go-webp/encoder"
+	"github.com/kolesa-team/go-webp/webp"
+	"github.com/reflet-devops/go-media-resizer/types"
+)
+
+const (
+	webpFlagAnimation = 0x02
+	webpFlagAlpha     = 0x10
+	// webpFrameNoBlend overwrites the canvas with each frame instead of alpha-blending it.
+	webpFrameNoBlend = 0x02
+	// webpFrameDispose clears the area of the frame once it was displayed.
+	webpFrameDispose = 0x01
+)
+
+type webpChunk struct {
+	fourCC string
+	data   []byte
+}
+
+// parseWebPChunks lists the chunks of a WebP RIFF container.
+func parseWebPChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid webp container")
+	}
+	var chunks []webpChunk
+	pos := 12
+	for pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || pos+8+size > len(data) {
+			return nil, errors.New("truncated webp chunk")
+		}
+		chunks = append(chunks, webpChunk{fourCC: string(data[pos : pos+4]), data: data[pos+8 : pos+8+size]})
+		pos += 8 + size + size%2
+	}
+	return chunks, nil
+}
+
+// IsAnimatedWebP reports whether data is a WebP container with the animation flag set.
+func IsAnimatedWebP(data []byte) bool {
+	chunks, err := parseWebPChunks(data)
+	if err != nil || len(chunks) == 0 || chunks[0].fourCC != "VP8X" || len(chunks[0].data) == 0 {
+		return false
+	}
+	return chunks[0].data[0]&webpFlagAnimation != 0
+}
+
+// webpAnimation holds the frames of an animated WebP composited on its canvas, with their delays in milliseconds and
+// the WebP loop count.
+type webpAnimation struct {
+	frames    []image.Image
+	delays    []int
+	loopCount int
+}
+
+// webpCanvasConfig reads the canvas size of an animated WebP from its VP8X chunk.
+func webpCanvasConfig(data []byte) (image.Config, string, error) {
+	chunks, errParse := parseWebPChunks(data)
+	if errParse != nil {
+		return image.Config{}, "", errParse
+	}
+	if len(chunks) == 0 || chunks[0].fourCC != "VP8X" || len(chunks[0].data) < 10 {
+		return image.Config{}, "", errors.New("invalid animated webp header")
+	}
+	vp8x := chunks[0].data
+	return image.Config{Width: readUint24(vp8x[4:]) + 1, Height: readUint24(vp8x[7:]) + 1}, types.TypeWEBP, nil
+}
+
+// webpFrameCount counts the ANMF chunks of an animated WebP, without decoding any frame.
+func webpFrameCount(data []byte) (int, error) {
+	chunks, errParse := parseWebPChunks(data)
+	if errParse != nil {
+		return 0, errParse
+	}
+	frames := 0
+	for _, chunk := range chunks {
+		if chunk.fourCC == "ANMF" {
+			frames++
+		}
+	}
+	return frames, nil
+}
+
+// decodeFirstWebPFrame decodes the first frame of an animated WebP, drawn on a transparent canvas of the animation
+// size.
+func decodeFirstWebPFrame(data []byte) (image.Image, error) {
+	animation, errDecode := decodeWebPAnimation(data, 1)
+	if errDecode != nil {
+		return nil, errDecode
+	}
+	return animation.frames[0], nil
+}
+
+// decodeWebPAnimation decodes up to limit frames of an animated WebP, all of them when limit is 0. Each frame is drawn
+// on the canvas left by the previous ones according to its blending and disposal methods, so every returned image is
+// a full canvas.
+func decodeWebPAnimation(data []byte, limit int) (*webpAnimation, error) {
+	chunks, errParse := parseWebPChunks(data)
+	if errParse != nil {
+		return nil, errParse
+	}
+	if len(chunks) == 0 || chunks[0].fourCC != "VP8X" || len(chunks[0].data) < 10 {
+		return nil, errors.New("invalid animated webp header")
+	}
+	vp8x := chunks[0].data
+	canvas := image.NewNRGBA(image.Rect(0, 0, readUint24(vp8x[4:])+1, readUint24(vp8x[7:])+1))
+
+	animation := &webpAnimation{}
+	for _, chunk := range chunks[1:] {
+		if chunk.fourCC == "ANIM" && len(chunk.data) >= 6 {
+			animation.loopCount = int(binary.LittleEndian.Uint16(chunk.data[4:]))
+		}
+		if chunk.fourCC != "ANMF" {
+			continue
+		}
+		if len(chunk.data) < 16 {
+			return nil, errors.New("truncated webp frame")
+		}
+		frame, errFrame := decodeWebPFrame(chunk.data)
+		if errFrame != nil {
+			return nil, errFrame
+		}
+		// frame offsets are stored divided by 2
+		offset := image.Pt(readUint24(chunk.data)*2, readUint24(chunk.data[3:])*2)
+		area := image.Rectangle{Max: frame.Bounds().Size()}.Add(offset)
+		op := draw.Over
+		if chunk.data[15]&webpFrameNoBlend != 0 {
+			op = draw.Src
+		}
+		draw.Draw(canvas, area, frame, frame.Bounds().Min, op)
+
+		composed := image.NewNRGBA(canvas.Rect)
+		copy(composed.Pix, canvas.Pix)
+		animation.frames = append(animation.frames, composed)
+		animation.delays = append(animation.delays, readUint24(chunk.data[12:]))
+		if limit > 0 && len(animation.frames) == limit {
+			break
+		}
+		if chunk.data[15]&webpFrameDispose != 0 {
+			draw.Draw(canvas, area, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+	if len(animation.frames) == 0 {
+		return nil, errors.New("animated webp has no frame")
+	}
+	return animation, nil
+}
+
+// decodeWebPFrame decodes the bitstream of an ANMF chunk. The WebP decoder only reads still images, so the bitstream
+// chunks are wrapped in a still WebP container.
+func decodeWebPFrame(anmf []byte) (image.Image, error) {
+	frameChunks, errFrame := parseWebPChunks(append([]byte("RIFF\x00\x00\x00\x00WEBP"), anmf[16:]...))
+	if errFrame != nil {
+		return nil, fmt.Errorf("failed to read webp frame: %w", errFrame)
+	}
+	width, height := readUint24(anmf[6:])+1, readUint24(anmf[9:])+1
+
+	var body []byte
+	hasAlpha := false
+	for _, frameChunk := range frameChunks {
+		switch frameChunk.fourCC {
+		case "ALPH":
+			hasAlpha = true
+			body = appendWebPChunk(body, frameChunk.fourCC, frameChunk.data)
+		case "VP8 ", "VP8L":
+			body = appendWebPChunk(body, frameChunk.fourCC, frameChunk.data)
+		}
+	}
+	still := []byte("WEBP")
+	if hasAlpha {
+		// the ALPH chunk is only read from an extended file
+		header := appendUint24([]byte{webpFlagAlpha, 0, 0, 0}, width-1)
+		still = appendWebPChunk(still, "VP8X", appendUint24(header, height-1))
+	}
+	still = append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(still)+len(body))), append(still, body...)...)
+
+	frame, errDecode := webp.Decode(bytes.NewReader(still), nil)
+	if errDecode != nil {
+		return nil, fmt.Errorf("failed to decode webp frame: %w", errDecode)
+	}
+	return frame, nil
+}
+
+// transformAnimatedWebP applies the requested options to every frame of an animated WebP and re-encodes the animation,
+// with the same delays and loop count.
+func transformAnimatedWebP(file *bytes.Buffer, opts *types.ResizeOption) error {
+	animation, errDecode := decodeWebPAnimation(file.Bytes(), 0)
+	if errDecode != nil {
+		return fmt.Errorf("failed to decode image %s: %w", opts.Source, errDecode)
+	}
+
+	stabilizeAnimation(animation.frames[0], opts)
+	processed := make([]image.Image, 0, len(animation.frames))
+	for _, frame := range animation.frames {
+		frameOpts := *opts
+		processed = append(processed, process(frame, &frameOpts))
+	}
+
+	options, errOptions := webpOptions(opts)
+	if errOptions != nil {
+		return fmt.Errorf("failed to format image %s: %w", opts.Source, errOptions)
+	}
+	file.Reset()
+	if errEncode := encodeAnimatedWebP(file, processed, animation.delays, animation.loopCount, options); errEncode != nil {
+		return fmt.Errorf("failed to format image %s: %w", opts.Source, errEncode)
+	}
+	return nil
+}
+
+func appendWebPChunk(dst []byte, fourCC string, payload []byte) []byte {
+	dst = append(dst, fourCC...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, payload...)
+	if len(payload)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func appendUint24(dst []byte, v int) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16))
+}
+
+func readUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// encodeAnimatedWebP encodes full-canvas frames of the same size as an animated WebP. Each frame is
+// encoded as a still WebP whose bitstream chunks are wrapped in an ANMF chunk.
+// Delays are in milliseconds; loopCount follows the WebP convention where 0 loops forever.
+func encodeAnimatedWebP(w io.Writer, frames []image.Image, delays []int, loopCount int, options *encoder.Options) error {
+	if len(frames) == 0 {
+		return errors.New("no frame to encode")
+	}
+	bounds := frames[0].Bounds()
+	hasAlpha := false
+
+	var body []byte
+	buffer := &bytes.Buffer{}
+	for i, frame := range frames {
+		if !frame.Bounds().Size().Eq(bounds.Size()) {
+			return fmt.Errorf("frame %d size %v differs from canvas %v", i, frame.Bounds().Size(), bounds.Size())
+		}
+		if opaque, ok := frame.(interface{ Opaque() bool }); !ok || !opaque.Opaque() {
+			hasAlpha = true
+		}
+
+		buffer.Reset()
+		if errEncode := webp.Encode(buffer, frame, options); errEncode != nil {
+			return fmt.Errorf("failed to encode frame %d: %w", i, errEncode)
+		}
+		chunks, errParse := parseWebPChunks(buffer.Bytes())
+		if errParse != nil {
+			return fmt.Errorf("failed to read frame %d: %w", i, errParse)
+		}
+
+		anmf := appendUint24(nil, 0)
+		anmf = appendUint24(anmf, 0)
+		anmf = appendUint24(anmf, bounds.Dx()-1)
+		anmf = appendUint24(anmf, bounds.Dy()-1)
+		delay := 0
+		if i < len(delays) {
+			delay = delays[i]
+		}
+		anmf = appendUint24(anmf, min(delay, 1<<24-1))
+		anmf = append(anmf, webpFrameNoBlend)
+		bitstream := false
+		for _, chunk := range chunks {
+			switch chunk.fourCC {
+			case "ALPH", "VP8 ", "VP8L":
+				anmf = appendWebPChunk(anmf, chunk.fourCC, chunk.data)
+				bitstream = true
+			}
+		}
+		if !bitstream {
+			return fmt.Errorf("failed to read frame %d: no bitstream chunk", i)
+		}
+		body = appendWebPChunk(body, "ANMF", anmf)
+	}
+
+	flags := byte(webpFlagAnimation)
+	if hasAlpha {
+		flags |= webpFlagAlpha
+	}
+	vp8x := []byte{flags, 0, 0, 0}
+	vp8x = appendUint24(vp8x, bounds.Dx()-1)
+	vp8x = appendUint24(vp8x, bounds.Dy()-1)
+
+	// background color (BGRA) then loop count
+	anim := []byte{0, 0, 0, 0}
+	anim = binary.LittleEndian.AppendUint16(anim, uint16(min(max(loopCount, 0), 1<<16-1)))
+
+	data := []byte("WEBP")
+	data = appendWebPChunk(data, "VP8X", vp8x)
+	data = appendWebPChunk(data, "ANIM", anim)
+	data = append(data, body...)
+
+	header := []byte("RIFF")
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(data)))
+	if _, errWrite := w.Write(header); errWrite != nil {
+		return errWrite
+	}
+	_, errWrite := w.Write(data)
+	return errWrite
+}
@@ -0,0 +1,289 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/kolesa-team/go-webp/encoder"
+	"github.com/kolesa-team/go-webp/webp"
+	"github.com/reflet-devops/go-media-resizer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// animatedWebP encodes an animation of the given number of 40x20 frames, alternating red and blue.
+func animatedWebP(t *testing.T, count int) *bytes.Buffer {
+	frames := make([]image.Image, 0, count)
+	for i := 0; i < count; i++ {
+		frames = append(frames, imaging.New(40, 20, []color.NRGBA{{R: 255, A: 255}, {B: 255, A: 255}}[i%2]))
+	}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, encodeAnimatedWebP(buffer, frames, nil, 0, &encoder.Options{Lossless: true}))
+	return buffer
+}
+
+func Test_parseWebPChunks(t *testing.T) {
+	data := []byte("WEBP")
+	data = appendWebPChunk(data, "VP8X", make([]byte, 10))
+	data = appendWebPChunk(data, "EXIF", []byte{1, 2, 3})
+	data = appendWebPChunk(data, "VP8L", []byte{4, 5})
+	valid := append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(data))), data...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []webpChunk
+		wantErr string
+	}{
+		{
+			name: "successWithPadding",
+			data: valid,
+			want: []webpChunk{
+				{fourCC: "VP8X", data: make([]byte, 10)},
+				{fourCC: "EXIF", data: []byte{1, 2, 3}},
+				{fourCC: "VP8L", data: []byte{4, 5}},
+			},
+		},
+		{name: "invalidHeader", data: []byte("RIFF\x00\x00\x00\x00WAVE"), wantErr: "invalid webp container"},
+		{name: "truncatedChunk", data: valid[:len(valid)-1], wantErr: "truncated webp chunk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWebPChunks(tt.data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_encodeAnimatedWebP(t *testing.T) {
+	frames := []image.Image{
+		imaging.New(20, 10, color.NRGBA{R: 255, A: 255}),
+		imaging.New(20, 10, color.NRGBA{B: 255, A: 128}),
+	}
+
+	buffer := &bytes.Buffer{}
+	err := encodeAnimatedWebP(buffer, frames, []int{70, 1500}, 2, nil)
+	assert.NoError(t, err)
+
+	data := buffer.Bytes()
+	assert.Equal(t, "RIFF", string(data[:4]))
+	assert.Equal(t, uint32(len(data)-8), binary.LittleEndian.Uint32(data[4:8]))
+
+	chunks, err := parseWebPChunks(data)
+	assert.NoError(t, err)
+	assert.Len(t, chunks, 4)
+
+	assert.Equal(t, "VP8X", chunks[0].fourCC)
+	assert.Equal(t, []byte{webpFlagAnimation | webpFlagAlpha, 0, 0, 0, 19, 0, 0, 9, 0, 0}, chunks[0].data)
+	assert.Equal(t, "ANIM", chunks[1].fourCC)
+	assert.Equal(t, []byte{0, 0, 0, 0, 2, 0}, chunks[1].data)
+
+	for i, delay := range []int{70, 1500} {
+		anmf := chunks[2+i]
+		assert.Equal(t, "ANMF", anmf.fourCC)
+		assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 19, 0, 0, 9, 0, 0}, anmf.data[:12])
+		assert.Equal(t, []byte{byte(delay), byte(delay >> 8), 0}, anmf.data[12:15])
+		assert.Equal(t, byte(webpFrameNoBlend), anmf.data[15])
+		frameChunks, errFrame := parseWebPChunks(append(binary.LittleEndian.AppendUint32([]byte("RIFF"), 0), append([]byte("WEBP"), anmf.data[16:]...)...))
+		assert.NoError(t, errFrame)
+		assert.NotEmpty(t, frameChunks)
+	}
+}
+
+func Test_encodeAnimatedWebP_Error(t *testing.T) {
+	t.Run("noFrame", func(t *testing.T) {
+		err := encodeAnimatedWebP(&bytes.Buffer{}, nil, nil, 0, nil)
+		assert.ErrorContains(t, err, "no frame to encode")
+	})
+	t.Run("frameSizeMismatch", func(t *testing.T) {
+		frames := []image.Image{imaging.New(20, 10, color.White), imaging.New(10, 10, color.White)}
+		err := encodeAnimatedWebP(&bytes.Buffer{}, frames, nil, 0, nil)
+		assert.ErrorContains(t, err, "frame 1 size")
+	})
+}
+
+func Test_decodeFirstWebPFrame(t *testing.T) {
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	encode := func(frames ...image.Image) []byte {
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, encodeAnimatedWebP(buffer, frames, nil, 0, &encoder.Options{Lossless: true}))
+		return buffer.Bytes()
+	}
+
+	t.Run("opaque", func(t *testing.T) {
+		img, err := decodeFirstWebPFrame(encode(imaging.New(20, 10, red), imaging.New(20, 10, blue)))
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+		assert.Equal(t, red, imaging.Clone(img).NRGBAAt(5, 5))
+	})
+	t.Run("withAlpha", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, encodeAnimatedWebP(buffer, []image.Image{imaging.New(20, 10, color.NRGBA{}), imaging.New(20, 10, blue)}, nil, 0, nil))
+		img, err := decodeFirstWebPFrame(buffer.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+		assert.Zero(t, imaging.Clone(img).NRGBAAt(5, 5).A)
+	})
+	t.Run("withOffset", func(t *testing.T) {
+		data := encode(imaging.New(20, 10, red), imaging.New(20, 10, blue))
+		chunks, _ := parseWebPChunks(data)
+		// a 30x20 canvas with the first frame at 4,2
+		copy(chunks[0].data[4:], []byte{29, 0, 0, 19, 0, 0})
+		copy(chunks[2].data, []byte{2, 0, 0, 1, 0, 0})
+
+		img, err := decodeFirstWebPFrame(data)
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 30, 20), img.Bounds())
+		nrgba := imaging.Clone(img)
+		assert.Equal(t, color.NRGBA{}, nrgba.NRGBAAt(2, 1))
+		assert.Equal(t, red, nrgba.NRGBAAt(4, 2))
+		assert.Equal(t, red, nrgba.NRGBAAt(23, 11))
+		assert.Equal(t, color.NRGBA{}, nrgba.NRGBAAt(24, 12))
+	})
+	t.Run("noFrame", func(t *testing.T) {
+		data := []byte("WEBP")
+		data = appendWebPChunk(data, "VP8X", []byte{webpFlagAnimation, 0, 0, 0, 9, 0, 0, 9, 0, 0})
+		_, err := decodeFirstWebPFrame(append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(data))), data...))
+		assert.EqualError(t, err, "animated webp has no frame")
+	})
+	t.Run("notExtended", func(t *testing.T) {
+		_, err := decodeFirstWebPFrame([]byte("RIFF\x04\x00\x00\x00WEBP"))
+		assert.EqualError(t, err, "invalid animated webp header")
+	})
+}
+
+func Test_decodeWebPAnimation(t *testing.T) {
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	encode := func(frames ...image.Image) []byte {
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, encodeAnimatedWebP(buffer, frames, []int{70, 1500}, 3, &encoder.Options{Lossless: true}))
+		return buffer.Bytes()
+	}
+
+	t.Run("allFrames", func(t *testing.T) {
+		got, err := decodeWebPAnimation(encode(imaging.New(20, 10, red), imaging.New(20, 10, blue)), 0)
+		assert.NoError(t, err)
+		assert.Len(t, got.frames, 2)
+		assert.Equal(t, []int{70, 1500}, got.delays)
+		assert.Equal(t, 3, got.loopCount)
+		assert.Equal(t, red, imaging.Clone(got.frames[0]).NRGBAAt(5, 5))
+		assert.Equal(t, blue, imaging.Clone(got.frames[1]).NRGBAAt(5, 5))
+	})
+	t.Run("limit", func(t *testing.T) {
+		got, err := decodeWebPAnimation(encode(imaging.New(20, 10, red), imaging.New(20, 10, blue)), 1)
+		assert.NoError(t, err)
+		assert.Len(t, got.frames, 1)
+	})
+	t.Run("blendAndDispose", func(t *testing.T) {
+		// a 20x10 red frame, then a 10x10 half transparent blue frame on its left half
+		frame := func(img image.Image, flags byte) []byte {
+			buffer := &bytes.Buffer{}
+			assert.NoError(t, webp.Encode(buffer, img, &encoder.Options{Lossless: true}))
+			chunks, _ := parseWebPChunks(buffer.Bytes())
+			anmf := appendUint24(appendUint24(nil, 0), 0)
+			anmf = appendUint24(appendUint24(anmf, img.Bounds().Dx()-1), img.Bounds().Dy()-1)
+			anmf = append(appendUint24(anmf, 100), flags)
+			for _, chunk := range chunks {
+				if chunk.fourCC == "ALPH" || chunk.fourCC == "VP8 " || chunk.fourCC == "VP8L" {
+					anmf = appendWebPChunk(anmf, chunk.fourCC, chunk.data)
+				}
+			}
+			return appendWebPChunk(nil, "ANMF", anmf)
+		}
+		build := func(firstFlags byte) []byte {
+			data := appendWebPChunk([]byte("WEBP"), "VP8X", []byte{webpFlagAnimation | webpFlagAlpha, 0, 0, 0, 19, 0, 0, 9, 0, 0})
+			data = append(data, frame(imaging.New(20, 10, red), firstFlags)...)
+			data = append(data, frame(imaging.New(10, 10, color.NRGBA{B: 255, A: 128}), 0)...)
+			return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(data))), data...)
+		}
+
+		got, err := decodeWebPAnimation(build(0), 0)
+		assert.NoError(t, err)
+		second := imaging.Clone(got.frames[1])
+		assert.Equal(t, color.NRGBA{R: 127, B: 128, A: 255}, second.NRGBAAt(5, 5))
+		assert.Equal(t, red, second.NRGBAAt(15, 5))
+
+		got, err = decodeWebPAnimation(build(webpFrameDispose), 0)
+		assert.NoError(t, err)
+		second = imaging.Clone(got.frames[1])
+		assert.Equal(t, color.NRGBA{B: 255, A: 128}, second.NRGBAAt(5, 5))
+		assert.Equal(t, color.NRGBA{}, second.NRGBAAt(15, 5))
+
+		got, err = decodeWebPAnimation(build(webpFrameNoBlend), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, color.NRGBA{R: 127, B: 128, A: 255}, imaging.Clone(got.frames[1]).NRGBAAt(5, 5))
+	})
+}
+
+func Test_webpFrameCount(t *testing.T) {
+	got, err := webpFrameCount(animatedWebP(t, 3).Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got)
+
+	_, err = webpFrameCount([]byte("GIF89a"))
+	assert.Error(t, err)
+}
+
+func TestTransform_AnimatedWebPOverlay(t *testing.T) {
+	file := animatedWebP(t, 2)
+	opts := &types.ResizeOption{
+		OriginFormat:   types.TypeWEBP,
+		Format:         types.TypeWEBP,
+		Lossless:       true,
+		WatermarkImage: &types.Watermark{Image: imaging.New(8, 8, color.White), Opacity: 1, Position: types.TypeGravityTopLeft},
+	}
+	assert.NoError(t, Transform(file, opts))
+
+	assert.True(t, IsAnimatedWebP(file.Bytes()))
+	got, err := decodeWebPAnimation(file.Bytes(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, got.frames, 2)
+	for i, want := range []color.NRGBA{{R: 255, A: 255}, {B: 255, A: 255}} {
+		frame := imaging.Clone(got.frames[i])
+		assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, frame.NRGBAAt(2, 2), "frame %d must be watermarked", i)
+		assert.Equal(t, want, frame.NRGBAAt(30, 15), "frame %d", i)
+	}
+}
+
+func TestTransform_AnimatedWebPToStill(t *testing.T) {
+	frames := []image.Image{imaging.New(40, 20, color.NRGBA{R: 255, A: 255}), imaging.New(40, 20, color.NRGBA{B: 255, A: 255})}
+	file := &bytes.Buffer{}
+	assert.NoError(t, encodeAnimatedWebP(file, frames, nil, 0, nil))
+
+	assert.NoError(t, Transform(file, &types.ResizeOption{OriginFormat: types.TypeWEBP, Format: types.TypeJPEG, Width: 20}))
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(file.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Pt(20, 10), image.Pt(cfg.Width, cfg.Height))
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	frames := []image.Image{imaging.New(10, 10, color.White), imaging.New(10, 10, color.Black)}
+	animated := &bytes.Buffer{}
+	assert.NoError(t, encodeAnimatedWebP(animated, frames, []int{10, 10}, 0, nil))
+	still := &bytes.Buffer{}
+	assert.NoError(t, webp.Encode(still, frames[0], nil))
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "animated", data: animated.Bytes(), want: true},
+		{name: "still", data: still.Bytes(), want: false},
+		{name: "notWebP", data: []byte("GIF89a"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsAnimatedWebP(tt.data))
+		})
+	}
+}
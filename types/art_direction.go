@@ -0,0 +1,37 @@
+package types
+
+import (
+	"image"
+)
+
+const (
+	ExtensionSidecar = ".json"
+)
+
+// ArtDirection holds the editor-defined cropping hints read from the sidecar stored next to an original.
+// FocalPoint is expressed in fractions of the source size, SafeArea and Crops in source pixels.
+type ArtDirection struct {
+	FocalPoint *FocalPoint     `json:"focal_point"`
+	SafeArea   *Rect           `json:"safe_area"`
+	Crops      map[string]Rect `json:"crops"`
+}
+
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+func GetSidecarPath(source string) string {
+	return source + ExtensionSidecar
+}
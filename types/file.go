@@ -9,6 +9,10 @@ const (
 	MimeTypePNG  = "image/png"
 	MimeTypeGIF  = "image/gif"
 	MimeTypeTIFF = "image/tiff"
+	MimeTypeBMP  = "image/bmp"
+	MimeTypeHEIC = "image/heic"
+	MimeTypeHEIF = "image/heif"
+	MimeTypeJXL  = "image/jxl"
 	MimeTypeSVG  = "image/svg+xml"
 
 	MimeTypeText = "text/plain"
@@ -29,6 +33,10 @@ const (
 	TypePNG  = "png"
 	TypeGIF  = "gif"
 	TypeTIFF = "tiff"
+	TypeBMP  = "bmp"
+	TypeHEIC = "heic"
+	TypeHEIF = "heif"
+	TypeJXL  = "jxl"
 	TypeSVG  = "svg"
 
 	TypeText = "plain"
@@ -50,6 +58,11 @@ const (
 	ExtensionPNG  = ".png"
 	ExtensionGIF  = ".gif"
 	ExtensionTIFF = ".tiff"
+	ExtensionTIF  = ".tif"
+	ExtensionBMP  = ".bmp"
+	ExtensionHEIC = ".heic"
+	ExtensionHEIF = ".heif"
+	ExtensionJXL  = ".jxl"
 	ExtensionSVG  = ".svg"
 
 	ExtensionText = ".txt"
@@ -71,10 +84,56 @@ const (
 	TypeFitScaleDown = "scale-down"
 	TypeFitPad       = "pad"
 	TypeResize       = "resize"
+
+	TypeResampleNearest    = "nearest"
+	TypeResampleBox        = "box"
+	TypeResampleLinear     = "linear"
+	TypeResampleCatmullRom = "catmullrom"
+	TypeResampleMitchell   = "mitchell"
+	TypeResampleLanczos    = "lanczos"
+
+	TypeFlipHorizontal = "h"
+	TypeFlipVertical   = "v"
+	TypeFlipBoth       = "hv"
+
+	TypeCompressionFast = "fast"
+	TypeCompressionBest = "best"
+	TypeCompressionNone = "none"
+
+	TypeChromaSubsampling420 = "420"
+	TypeChromaSubsampling444 = "444"
+
+	TypeColorProfileSRGB = "srgb"
+	TypeColorProfileKeep = "keep"
+
+	TypeMetadataNone      = "none"
+	TypeMetadataCopyright = "copyright"
+	TypeMetadataKeep      = "keep"
+
+	TypeBackgroundBlur = "blur"
+
+	TypeTrimAuto = "auto"
+
+	TypeFontGoRegular = "goregular"
+	TypeFontGoBold    = "gobold"
+	TypeFontGoItalic  = "goitalic"
+	TypeFontGoMono    = "gomono"
+
+	TypeGravityCenter      = "center"
+	TypeGravityAuto        = "auto"
+	TypeGravityFace        = "face"
+	TypeGravityTop         = "top"
+	TypeGravityBottom      = "bottom"
+	TypeGravityLeft        = "left"
+	TypeGravityRight       = "right"
+	TypeGravityTopLeft     = "top-left"
+	TypeGravityTopRight    = "top-right"
+	TypeGravityBottomLeft  = "bottom-left"
+	TypeGravityBottomRight = "bottom-right"
 )
 
 var (
-	TypesImages = []string{TypeAVIF, TypeWEBP, TypeJPEG, TypePNG}
+	TypesImages = []string{TypeAVIF, TypeWEBP, TypeJPEG, TypePNG, TypeTIFF, TypeBMP, TypeHEIC, TypeHEIF, TypeJXL}
 )
 
 func GetMimeType(code string) string {
@@ -91,6 +150,14 @@ func GetMimeType(code string) string {
 		return MimeTypeGIF
 	case TypeTIFF:
 		return MimeTypeTIFF
+	case TypeBMP:
+		return MimeTypeBMP
+	case TypeHEIC:
+		return MimeTypeHEIC
+	case TypeHEIF:
+		return MimeTypeHEIF
+	case TypeJXL:
+		return MimeTypeJXL
 	case TypeSVG:
 		return MimeTypeSVG
 	case TypeText:
@@ -128,8 +195,16 @@ func GetType(extension string) string {
 		return TypePNG
 	case ExtensionGIF:
 		return TypeGIF
-	case ExtensionTIFF:
+	case ExtensionTIFF, ExtensionTIF:
 		return TypeTIFF
+	case ExtensionBMP:
+		return TypeBMP
+	case ExtensionHEIC:
+		return TypeHEIC
+	case ExtensionHEIF:
+		return TypeHEIF
+	case ExtensionJXL:
+		return TypeJXL
 	case ExtensionSVG:
 		return TypeSVG
 	case ExtensionText:
@@ -42,6 +42,26 @@ func Test_GetMimeType_Success(t *testing.T) {
 			searchedType: TypeTIFF,
 			want:         MimeTypeTIFF,
 		},
+		{
+			name:         TypeBMP,
+			searchedType: TypeBMP,
+			want:         MimeTypeBMP,
+		},
+		{
+			name:         TypeHEIC,
+			searchedType: TypeHEIC,
+			want:         MimeTypeHEIC,
+		},
+		{
+			name:         TypeHEIF,
+			searchedType: TypeHEIF,
+			want:         MimeTypeHEIF,
+		},
+		{
+			name:         TypeJXL,
+			searchedType: TypeJXL,
+			want:         MimeTypeJXL,
+		},
 		{
 			name:         TypeSVG,
 			searchedType: TypeSVG,
@@ -143,6 +163,31 @@ func Test_GetType(t *testing.T) {
 			want:              TypeTIFF,
 			searchedExtension: ExtensionTIFF,
 		},
+		{
+			name:              "tif",
+			want:              TypeTIFF,
+			searchedExtension: ExtensionTIF,
+		},
+		{
+			name:              TypeBMP,
+			want:              TypeBMP,
+			searchedExtension: ExtensionBMP,
+		},
+		{
+			name:              TypeHEIC,
+			want:              TypeHEIC,
+			searchedExtension: ExtensionHEIC,
+		},
+		{
+			name:              TypeHEIF,
+			want:              TypeHEIF,
+			searchedExtension: ExtensionHEIF,
+		},
+		{
+			name:              TypeJXL,
+			want:              TypeJXL,
+			searchedExtension: ExtensionJXL,
+		},
 		{
 			name:              TypeSVG,
 			want:              TypeSVG,
@@ -0,0 +1,33 @@
+package types
+
+// FormatOption holds the encoder settings used for one output format when the request doesn't set them.
+type FormatOption struct {
+	Quality int `mapstructure:"quality" validate:"min=0,max=100"`
+	// Speed is only used by the AVIF encoder, from 0 (slowest, smallest) to 10
+	Speed int `mapstructure:"speed" validate:"min=0,max=10"`
+}
+
+// FormatDefaults maps an output format (jpeg, webp, avif, jxl) to its encoder settings.
+type FormatDefaults map[string]FormatOption
+
+// Merge returns the defaults of f overridden by every non-zero setting of override.
+func (f FormatDefaults) Merge(override FormatDefaults) FormatDefaults {
+	if len(override) == 0 {
+		return f
+	}
+	merged := make(FormatDefaults, len(f)+len(override))
+	for format, option := range f {
+		merged[format] = option
+	}
+	for format, option := range override {
+		current := merged[format]
+		if option.Quality != 0 {
+			current.Quality = option.Quality
+		}
+		if option.Speed != 0 {
+			current.Speed = option.Speed
+		}
+		merged[format] = current
+	}
+	return merged
+}
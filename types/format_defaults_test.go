@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDefaults_Merge(t *testing.T) {
+	base := FormatDefaults{TypeWEBP: {Quality: 80}, TypeAVIF: {Quality: 50, Speed: 8}}
+
+	tests := []struct {
+		name     string
+		defaults FormatDefaults
+		override FormatDefaults
+		want     FormatDefaults
+	}{
+		{
+			name:     "successNilWithNil",
+			defaults: nil,
+			override: nil,
+			want:     nil,
+		},
+		{
+			name:     "successNoOverride",
+			defaults: base,
+			override: FormatDefaults{},
+			want:     base,
+		},
+		{
+			name:     "successOverrideOnNil",
+			defaults: nil,
+			override: FormatDefaults{TypeJPEG: {Quality: 85}},
+			want:     FormatDefaults{TypeJPEG: {Quality: 85}},
+		},
+		{
+			name:     "successOverrideOnlyNonZeroSettings",
+			defaults: base,
+			override: FormatDefaults{TypeAVIF: {Quality: 40}, TypeJPEG: {Quality: 85}},
+			want:     FormatDefaults{TypeWEBP: {Quality: 80}, TypeAVIF: {Quality: 40, Speed: 8}, TypeJPEG: {Quality: 85}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.defaults.Merge(tt.override))
+		})
+	}
+
+	t.Run("successDoesNotMutateDefaults", func(t *testing.T) {
+		base.Merge(FormatDefaults{TypeWEBP: {Quality: 10}})
+		assert.Equal(t, 80, base[TypeWEBP].Quality)
+	})
+}
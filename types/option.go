@@ -2,6 +2,8 @@ package types
 
 import (
 	"strings"
+
+	"golang.org/x/image/font/opentype"
 )
 
 type Headers map[string]string
@@ -11,23 +13,61 @@ func (h Headers) Add(key, value string) {
 }
 
 type ResizeOption struct {
-	OriginFormat string `mapstructure:"origin_format"`
-	Format       string `mapstructure:"format"`
-	Width        int    `mapstructure:"width"`
-	Height       int    `mapstructure:"height"`
-	Quality      int    `mapstructure:"quality"`
-	Fit          string `mapstructure:"fit"`
-	Source       string `mapstructure:"source"`
-
+	OriginFormat  string `mapstructure:"origin_format"`
+	Format        string `mapstructure:"format"`
+	Width         int    `mapstructure:"width"`
+	Height        int    `mapstructure:"height"`
+	Quality       int    `mapstructure:"quality"`
+	Fit           string `mapstructure:"fit"`
+	Gravity       string `mapstructure:"gravity"`
+	Resample      string `mapstructure:"resample"`
+	Rotate        int    `mapstructure:"rotate"`
+	Flip          string `mapstructure:"flip"`
+	Anim          *bool  `mapstructure:"anim"`
+	Lossless      bool   `mapstructure:"lossless"`
+	NearLossless  int    `mapstructure:"near_lossless"`
+	Compression   string `mapstructure:"compression"`
+	Progressive   bool   `mapstructure:"progressive"`
+	Chroma        string `mapstructure:"chroma"`
+	ColorProfile  string `mapstructure:"color_profile"`
+	Metadata      string `mapstructure:"metadata"`
+	Watermark     *bool  `mapstructure:"watermark"`
+	Text          string `mapstructure:"text"`
+	TextFont      string `mapstructure:"text_font"`
+	TextSize      int    `mapstructure:"text_size"`
+	TextColor     string `mapstructure:"text_color"`
+	TextPosition  string `mapstructure:"text_position"`
+	TextShadow    string `mapstructure:"text_shadow"`
+	Background    string `mapstructure:"background"`
+	Crop          string `mapstructure:"crop"`
+	Trim          string `mapstructure:"trim"`
+	TrimTolerance int    `mapstructure:"trim_tolerance"`
+	Page          int    `mapstructure:"page"`
+	Source        string `mapstructure:"source"`
+
+	Dpr        float64 `mapstructure:"dpr"`
 	Blur       float64 `mapstructure:"blur"`
 	Brightness float64 `mapstructure:"brightness"`
 	Saturation float64 `mapstructure:"saturation"`
 	Contrast   float64 `mapstructure:"contrast"`
 	Sharpen    float64 `mapstructure:"sharpen"`
 	Gamma      float64 `mapstructure:"gamma"`
-
-	Headers Headers
-	Tags    []string
+	Grayscale  bool    `mapstructure:"grayscale"`
+	Sepia      float64 `mapstructure:"sepia"`
+	Hue        float64 `mapstructure:"hue"`
+	Tint       string  `mapstructure:"tint"`
+	Duotone    string  `mapstructure:"duotone"`
+	Invert     bool    `mapstructure:"invert"`
+
+	Headers        Headers
+	Tags           []string
+	ArtDirection   *ArtDirection
+	AutoOrient     bool
+	Orientation    int
+	FormatDefaults FormatDefaults
+	ICCProfile     []byte
+	WatermarkImage *Watermark
+	Font           *opentype.Font
 }
 
 func (r *ResizeOption) Reset() {
@@ -37,20 +77,62 @@ func (r *ResizeOption) Reset() {
 	r.Height = 0
 	r.Quality = 0
 	r.Fit = ""
+	r.Gravity = ""
+	r.Resample = ""
+	r.Rotate = 0
+	r.Flip = ""
+	r.Anim = nil
+	r.Lossless = false
+	r.NearLossless = 0
+	r.Compression = ""
+	r.Progressive = false
+	r.Chroma = ""
+	r.ColorProfile = ""
+	r.Metadata = ""
+	r.Watermark = nil
+	r.Text = ""
+	r.TextFont = ""
+	r.TextSize = 0
+	r.TextColor = ""
+	r.TextPosition = ""
+	r.TextShadow = ""
+	r.Background = ""
+	r.Crop = ""
+	r.Trim = ""
+	r.TrimTolerance = 0
+	r.Page = 0
 	r.Source = ""
+	r.Dpr = 0
 	r.Blur = 0
 	r.Brightness = 0
 	r.Saturation = 0
 	r.Contrast = 0
 	r.Sharpen = 0
 	r.Gamma = 0
+	r.Grayscale = false
+	r.Sepia = 0
+	r.Hue = 0
+	r.Tint = ""
+	r.Duotone = ""
+	r.Invert = false
 
 	r.Headers = nil
 	r.Tags = nil
+	r.ArtDirection = nil
+	r.AutoOrient = false
+	r.Orientation = 0
+	r.FormatDefaults = nil
+	r.ICCProfile = nil
+	r.WatermarkImage = nil
+	r.Font = nil
 }
 
+// ResetToDefaults copies the defaults into r. The slices and booleans options are copied as well, since the
+// request options are decoded over them and must not change the endpoint defaults shared by every request.
 func (r *ResizeOption) ResetToDefaults(defaults *ResizeOption) {
 	*r = *defaults
+	r.Anim = cloneBool(defaults.Anim)
+	r.Watermark = cloneBool(defaults.Watermark)
 
 	if defaults.Headers != nil {
 		for k, v := range defaults.Headers {
@@ -64,6 +146,22 @@ func (r *ResizeOption) ResetToDefaults(defaults *ResizeOption) {
 	}
 }
 
+func cloneBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	clone := *b
+	return &clone
+}
+
+// FormatQuality returns the quality requested for the output format, falling back to the format defaults.
+func (r *ResizeOption) FormatQuality() int {
+	if r.Quality != 0 {
+		return r.Quality
+	}
+	return r.FormatDefaults[r.Format].Quality
+}
+
 func (r *ResizeOption) HasTags() bool {
 	return len(r.Tags) > 0
 }
@@ -91,9 +189,26 @@ func (r *ResizeOption) NeedFormat() bool {
 	return r.OriginFormat != r.Format
 }
 func (r *ResizeOption) NeedAdjust() bool {
-	return r.Blur != 0 || r.Brightness != 0 || r.Saturation != 0 || r.Contrast != 0 || r.Sharpen != 0 || r.Gamma != 0
+	return r.Blur != 0 || r.Brightness != 0 || r.Saturation != 0 || r.Contrast != 0 || r.Sharpen != 0 || r.Gamma != 0 ||
+		r.Grayscale || r.Sepia > 0 || r.Hue != 0 || r.Tint != "" || r.Duotone != "" || r.Invert
+}
+
+// NeedOrient reports whether the image must be rotated or flipped, either explicitly or to honour
+// the EXIF orientation read from the source.
+func (r *ResizeOption) NeedOrient() bool {
+	return r.Rotate != 0 || r.Flip != "" || (r.AutoOrient && r.Orientation > 1)
+}
+
+// KeepAnimation reports whether animated sources stay animated, which is the default.
+func (r *ResizeOption) KeepAnimation() bool {
+	return r.Anim == nil || *r.Anim
+}
+
+// WatermarkEnabled reports whether the endpoint watermark applies, which is the default.
+func (r *ResizeOption) WatermarkEnabled() bool {
+	return r.Watermark == nil || *r.Watermark
 }
 
 func (r *ResizeOption) NeedTransform() bool {
-	return r.NeedResize() || r.NeedAdjust() || r.NeedFormat()
+	return r.NeedResize() || r.NeedAdjust() || r.NeedFormat() || r.NeedOrient() || (r.OriginFormat == TypeGIF && !r.KeepAnimation()) || r.WatermarkImage != nil || r.Text != "" || r.Crop != "" || r.Trim != ""
 }
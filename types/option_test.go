@@ -103,6 +103,36 @@ func TestResizeOption_NeedAdjust(t *testing.T) {
 			opts: ResizeOption{Blur: 1},
 			want: true,
 		},
+		{
+			name: "successNeedGrayscale",
+			opts: ResizeOption{Grayscale: true},
+			want: true,
+		},
+		{
+			name: "successNeedSepia",
+			opts: ResizeOption{Sepia: 0.5},
+			want: true,
+		},
+		{
+			name: "successNeedHue",
+			opts: ResizeOption{Hue: -90},
+			want: true,
+		},
+		{
+			name: "successNeedTint",
+			opts: ResizeOption{Tint: "ff0000"},
+			want: true,
+		},
+		{
+			name: "successNeedDuotone",
+			opts: ResizeOption{Duotone: "000080,ffd700"},
+			want: true,
+		},
+		{
+			name: "successNeedInvert",
+			opts: ResizeOption{Invert: true},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -112,6 +142,65 @@ func TestResizeOption_NeedAdjust(t *testing.T) {
 	}
 }
 
+func TestResizeOption_NeedOrient(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ResizeOption
+		want bool
+	}{
+		{
+			name: "successNeedFalse",
+			opts: ResizeOption{},
+			want: false,
+		},
+		{
+			name: "successNeedFalseWithNormalOrientation",
+			opts: ResizeOption{AutoOrient: true, Orientation: 1},
+			want: false,
+		},
+		{
+			name: "successNeedFalseWithAutoOrientDisabled",
+			opts: ResizeOption{Orientation: 6},
+			want: false,
+		},
+		{
+			name: "successNeedTrueWithOrientation",
+			opts: ResizeOption{AutoOrient: true, Orientation: 6},
+			want: true,
+		},
+		{
+			name: "successNeedTrueWithRotate",
+			opts: ResizeOption{Rotate: 90},
+			want: true,
+		},
+		{
+			name: "successNeedTrueWithFlip",
+			opts: ResizeOption{Flip: TypeFlipHorizontal},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.opts
+			assert.Equalf(t, tt.want, r.NeedOrient(), "NeedOrient()")
+		})
+	}
+}
+
+func TestResizeOption_KeepAnimation(t *testing.T) {
+	enabled, disabled := true, false
+	assert.True(t, (&ResizeOption{}).KeepAnimation())
+	assert.True(t, (&ResizeOption{Anim: &enabled}).KeepAnimation())
+	assert.False(t, (&ResizeOption{Anim: &disabled}).KeepAnimation())
+}
+
+func TestResizeOption_WatermarkEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	assert.True(t, (&ResizeOption{}).WatermarkEnabled())
+	assert.True(t, (&ResizeOption{Watermark: &enabled}).WatermarkEnabled())
+	assert.False(t, (&ResizeOption{Watermark: &disabled}).WatermarkEnabled())
+}
+
 func TestResizeOption_NeedTransform(t *testing.T) {
 	tests := []struct {
 		name string
@@ -142,6 +231,41 @@ func TestResizeOption_NeedTransform(t *testing.T) {
 			opts: ResizeOption{Blur: 1},
 			want: true,
 		},
+		{
+			name: "successNeedOrient",
+			opts: ResizeOption{AutoOrient: true, Orientation: 6},
+			want: true,
+		},
+		{
+			name: "successNeedStillGIF",
+			opts: ResizeOption{OriginFormat: TypeGIF, Format: TypeGIF, Anim: new(bool)},
+			want: true,
+		},
+		{
+			name: "successNeedAnimatedGIFFalse",
+			opts: ResizeOption{OriginFormat: TypeGIF, Format: TypeGIF},
+			want: false,
+		},
+		{
+			name: "successNeedWatermark",
+			opts: ResizeOption{OriginFormat: TypePNG, Format: TypePNG, WatermarkImage: &Watermark{}},
+			want: true,
+		},
+		{
+			name: "successNeedText",
+			opts: ResizeOption{OriginFormat: TypePNG, Format: TypePNG, Text: "SOLD"},
+			want: true,
+		},
+		{
+			name: "successNeedCrop",
+			opts: ResizeOption{OriginFormat: TypePNG, Format: TypePNG, Crop: "0,0,10,10"},
+			want: true,
+		},
+		{
+			name: "successNeedTrim",
+			opts: ResizeOption{OriginFormat: TypePNG, Format: TypePNG, Trim: TypeTrimAuto},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -165,7 +289,7 @@ func TestResizeOption_Reset(t *testing.T) {
 		},
 		{
 			name:   "successWithValue",
-			source: ResizeOption{Source: "foo", Width: 100, Height: 100, Format: "test", Headers: Headers{"X-Custom": "foo"}, Tags: []string{"tag1", "tag2"}},
+			source: ResizeOption{Source: "foo", Width: 100, Height: 100, Format: "test", Gravity: "top", Headers: Headers{"X-Custom": "foo"}, Tags: []string{"tag1", "tag2"}, ArtDirection: &ArtDirection{FocalPoint: &FocalPoint{X: 0.5, Y: 0.5}}},
 			want:   ResizeOption{},
 		},
 	}
@@ -205,6 +329,22 @@ func TestResizeOption_ResetToDefaults(t *testing.T) {
 	}
 }
 
+func TestResizeOption_ResetToDefaults_KeepsDefaults(t *testing.T) {
+	enabled := true
+	defaults := &ResizeOption{Anim: &enabled, Watermark: &enabled}
+
+	first := &ResizeOption{}
+	first.ResetToDefaults(defaults)
+	*first.Anim = false
+	*first.Watermark = false
+
+	second := &ResizeOption{}
+	second.ResetToDefaults(defaults)
+	assert.True(t, *second.Anim)
+	assert.True(t, *second.Watermark)
+	assert.True(t, enabled)
+}
+
 func TestHeaders_Add(t *testing.T) {
 	headers := Headers{}
 	headers.Add("foo", "bar")
@@ -248,3 +388,39 @@ func TestResizeOption_AddHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestResizeOption_FormatQuality(t *testing.T) {
+	defaults := FormatDefaults{TypeWEBP: {Quality: 80}}
+	tests := []struct {
+		name string
+		opts ResizeOption
+		want int
+	}{
+		{
+			name: "successNoQuality",
+			opts: ResizeOption{Format: TypeWEBP},
+			want: 0,
+		},
+		{
+			name: "successFromDefaults",
+			opts: ResizeOption{Format: TypeWEBP, FormatDefaults: defaults},
+			want: 80,
+		},
+		{
+			name: "successRequestOverridesDefaults",
+			opts: ResizeOption{Format: TypeWEBP, Quality: 60, FormatDefaults: defaults},
+			want: 60,
+		},
+		{
+			name: "successNoDefaultsForFormat",
+			opts: ResizeOption{Format: TypeAVIF, FormatDefaults: defaults},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.opts
+			assert.Equalf(t, tt.want, r.FormatQuality(), "FormatQuality()")
+		})
+	}
+}
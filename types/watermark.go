@@ -0,0 +1,18 @@
+package types
+
+import (
+	"image"
+)
+
+// Watermark is an overlay composited onto transformed images, loaded from the project storage.
+type Watermark struct {
+	Image image.Image
+	// Position is a gravity anchor such as bottom-right (the default) or center
+	Position string
+	// Margin is the distance in pixels kept between the watermark and the output edges
+	Margin int
+	// Opacity goes from 0, fully transparent, to 1, fully opaque
+	Opacity float64
+	// Scale is the watermark width as a fraction of the output width, 0 keeps its own size
+	Scale float64
+}